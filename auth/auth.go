@@ -122,6 +122,16 @@ func WithUserInfoRequestContext(req *http.Request) *http.Request {
 	return req.WithContext(ctx)
 }
 
+// WithUserInfoToken returns context with user info value extracted from the given auth token
+func WithUserInfoToken(ctx context.Context, token string) context.Context {
+	payload, ok := extractPayloadFromToken(token)
+	if !ok {
+		return ctx
+	}
+
+	return withUserInfoClaims(ctx, payload)
+}
+
 // UserInfoFromContext returns user info from context if it exists
 func UserInfoFromContext(ctx context.Context) (UserInfo, bool) {
 	if val := ctx.Value(userInfoKey{}); val != nil {
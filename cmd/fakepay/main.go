@@ -0,0 +1,24 @@
+// Command fakepay runs the fake payment gateway locally, point payment.base.url at it
+// and set payment.webhook.secret to the same secret.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/AlvinTendio/minder/payment/fake"
+)
+
+func main() {
+	addr := flag.String("addr", ":8090", "address to listen on")
+	baseURL := flag.String("base-url", "http://localhost:8090", "URL the gateway is reachable at, used in checkout links")
+	webhookURL := flag.String("webhook", "http://localhost:8080/minder/payments/webhook", "URL webhooks are sent to")
+	secret := flag.String("secret", "fakepay-secret", "webhook signing secret")
+	flag.Parse()
+
+	log.Println("fake payment gateway listening on", *addr)
+	if err := http.ListenAndServe(*addr, fake.NewServer(*baseURL, *webhookURL, *secret)); err != nil {
+		log.Fatal(err)
+	}
+}
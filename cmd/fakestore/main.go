@@ -0,0 +1,47 @@
+// Command fakestore runs the app store stand-in locally. Point iap.googleplay.base.url at it with
+// iap.googleplay.access.token set to the same token, and trust the root it writes to -appstore-root
+// through iap.appstore.root.path. The root changes on every start, so restart minder after it.
+// Google Play notifications carry push tokens signed with keys served at /oauth2/v3/certs,
+// point iap.googleplay.push.certs.url there.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/AlvinTendio/minder/iap/fake"
+)
+
+func main() {
+	addr := flag.String("addr", ":8091", "address to listen on")
+	bundleId := flag.String("bundle-id", "com.minder.app", "App Store bundle id")
+	packageName := flag.String("package-name", "com.minder.app", "Google Play package name")
+	accessToken := flag.String("access-token", "fakestore-token", "access token the Developer API stand-in accepts")
+	appStoreWebhook := flag.String("appstore-webhook", "http://localhost:8080/minder/iap/notifications/app-store", "URL App Store notifications are sent to")
+	googlePlayWebhook := flag.String("googleplay-webhook", "http://localhost:8080/minder/iap/notifications/google-play", "URL Google Play notifications are pushed to")
+	pushServiceAccount := flag.String("push-service-account", "fakestore@fake.iam.gserviceaccount.com", "service account Google Play push tokens are issued to")
+	appStoreRoot := flag.String("appstore-root", "fakestore_root.pem", "file the root certificate of the App Store signing chain is written to")
+	flag.Parse()
+
+	server, err := fake.NewServer(fake.Config{
+		BundleId:           *bundleId,
+		PackageName:        *packageName,
+		AccessToken:        *accessToken,
+		AppStoreWebhook:    *appStoreWebhook,
+		GooglePlayWebhook:  *googlePlayWebhook,
+		PushServiceAccount: *pushServiceAccount,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*appStoreRoot, server.RootPEM(), 0o644); err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("fake app stores listening on", *addr)
+	if err := http.ListenAndServe(*addr, server); err != nil {
+		log.Fatal(err)
+	}
+}
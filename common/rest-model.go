@@ -1,9 +1,12 @@
 package common
 
+import "net/http"
+
 // HTTPResponse common data response for handling REST data and status
 type HTTPResponse struct {
-	ResponseCode    string `json:"responseCode,omitempty"`
-	ResponseMessage string `json:"responseMessage,omitempty"`
-	Data            any    `json:"data,omitempty"`
-	HTTPStatus      int    `json:"-"`
+	ResponseCode    string      `json:"responseCode,omitempty"`
+	ResponseMessage string      `json:"responseMessage,omitempty"`
+	Data            any         `json:"data,omitempty"`
+	HTTPStatus      int         `json:"-"`
+	Header          http.Header `json:"-"`
 }
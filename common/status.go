@@ -5,6 +5,18 @@ const (
 	StatusOKResponseMessage                  = "Success"
 	StatusBadRequestErrorResponseCode        = "400"
 	StatusBadRequestErrorResponseMessage     = "Bad Request"
+	StatusUnauthorizedErrorResponseCode      = "401"
+	StatusUnauthorizedErrorResponseMessage   = "Unauthorized"
+	StatusNotFoundErrorResponseCode          = "404"
+	StatusNotFoundErrorResponseMessage       = "Not Found"
+	StatusForbiddenErrorResponseCode         = "403"
+	StatusForbiddenErrorResponseMessage      = "Forbidden"
+	StatusConflictErrorResponseCode          = "409"
+	StatusConflictErrorResponseMessage       = "Conflict"
+	StatusTooManyRequestsResponseCode        = "429"
+	StatusTooManyRequestsResponseMessage     = "Too Many Requests"
+	QuotaExceededResponseCode                = "QUOTA_EXCEEDED"
+	QuotaExceededResponseMessage             = "Daily quota exceeded"
 	StatusInternalServerErrorResponseCode    = "500"
 	StatusInternalServerErrorResponseMessage = "Internal Server Error"
 )
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"log"
 	"path"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/AlvinTendio/minder/config"
 	"github.com/AlvinTendio/minder/config/internal"
@@ -15,6 +17,8 @@ import (
 
 type (
 	Config struct {
+		// watchersMu guards watchers, Watch may be called while an update notifies them
+		watchersMu     sync.Mutex
 		watchers       []internal.Watcher
 		data           *viper.Viper
 		additionalPath []string
@@ -149,6 +153,12 @@ func (c *Config) updateConfig() {
 		case data := <-c.dataCh:
 			c.data = data
 			log.Println("Config updated :", c.data.AllKeys())
+			c.watchersMu.Lock()
+			watchers := slices.Clone(c.watchers)
+			c.watchersMu.Unlock()
+			for _, watcher := range watchers {
+				watcher.Update(c)
+			}
 
 			// Wait any signal from stop channel, if signal occur then stop the goroutine
 		case <-c.stopCh:
@@ -232,6 +242,8 @@ func (c *Config) GetMap(key string) map[string]string {
 }
 
 func (c *Config) Close() error {
+	c.watchersMu.Lock()
+	defer c.watchersMu.Unlock()
 	for _, watcher := range c.watchers {
 		watcher.Close()
 	}
@@ -241,7 +253,9 @@ func (c *Config) Close() error {
 
 func (c *Config) Watch(keys ...string) <-chan []string {
 	watcher := internal.NewWatcher(keys, c)
+	c.watchersMu.Lock()
 	c.watchers = append(c.watchers, watcher)
+	c.watchersMu.Unlock()
 	return watcher.Change()
 }
 
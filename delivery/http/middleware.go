@@ -1,15 +1,22 @@
 package http
 
 import (
+	"crypto/subtle"
 	"net/http"
 
 	"github.com/AlvinTendio/minder/auth"
+	core_config "github.com/AlvinTendio/minder/config"
 	"github.com/gorilla/handlers"
 	"github.com/rs/cors"
 )
 
 const (
 	cacheMaxAge = 86400
+
+	adminKeyHeader = "X-Admin-Key"
+	adminKeyConfig = "admin.api.key"
+
+	accessTokenQuery = "access_token"
 )
 
 // CORS wraps http handler to allow cors with default options
@@ -43,6 +50,39 @@ func Auth(handler http.Handler) http.Handler {
 	})
 }
 
+// AdminOnly wraps http handler to only let through requests carrying the configured admin key,
+// every request is rejected while no admin key is configured
+func AdminOnly(config core_config.Config, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := config.GetString(adminKeyConfig)
+		given := r.Header.Get(adminKeyHeader)
+		if key == "" || subtle.ConstantTimeCompare([]byte(key), []byte(given)) != 1 {
+			http.Error(w, "403 forbidden", http.StatusForbidden)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// Authenticated wraps http handler to only let through requests carrying a user's auth token, either
+// in the auth header or in the access_token query parameter for clients that cannot set headers
+func Authenticated(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r = auth.WithUserInfoRequestContext(r)
+		if _, ok := auth.UserInfoFromContext(r.Context()); !ok {
+			if token := r.URL.Query().Get(accessTokenQuery); token != "" {
+				r = r.WithContext(auth.WithUserInfoToken(r.Context(), token))
+			}
+		}
+
+		if userInfo, ok := auth.UserInfoFromContext(r.Context()); !ok || userInfo.ID == 0 {
+			http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	}
+}
+
 type Option func(http.Handler) http.Handler
 
 // WithRecovery adds option for handling panic recovery from downstream call
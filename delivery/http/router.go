@@ -10,6 +10,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/AlvinTendio/minder/common"
 	core_config "github.com/AlvinTendio/minder/config"
 )
 
@@ -134,6 +135,13 @@ func ResponseWrite(r *http.Request, rw http.ResponseWriter, response interface{}
 	getRequest := string(getReq)
 	log.Println(getRequest)
 	log.Println(responses)
+	if resp, ok := response.(*common.HTTPResponse); ok && resp != nil {
+		for key, values := range resp.Header {
+			for _, value := range values {
+				rw.Header().Add(key, value)
+			}
+		}
+	}
 	if rw.Header().Get("Content-Disposition") != "" {
 		rw.Header().Set("Content-Type", "application/octet-stream")
 	} else {
@@ -0,0 +1,246 @@
+// Package appstore verifies the JWS the App Store signs: transactions the app sends as receipts
+// and App Store Server Notifications V2.
+package appstore
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/AlvinTendio/minder/iap"
+)
+
+// TypeAutoRenewable is the transaction type of auto-renewable subscriptions
+const TypeAutoRenewable = "Auto-Renewable Subscription"
+
+var (
+	// OIDReceiptSigning marks the certificates Apple signs App Store transactions and notifications with
+	OIDReceiptSigning = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 11, 1}
+
+	// OIDIntermediate marks the Apple Worldwide Developer Relations intermediates issuing them
+	OIDIntermediate = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 2, 1}
+)
+
+// Transaction is the payload of a signed transaction, dates are in milliseconds since the epoch
+type Transaction struct {
+	TransactionId         string `json:"transactionId"`
+	OriginalTransactionId string `json:"originalTransactionId"`
+	BundleId              string `json:"bundleId"`
+	ProductId             string `json:"productId"`
+	PurchaseDate          int64  `json:"purchaseDate"`
+	ExpiresDate           int64  `json:"expiresDate,omitempty"`
+	RevocationDate        int64  `json:"revocationDate,omitempty"`
+	Type                  string `json:"type"`
+}
+
+// RenewalInfo is the payload of signed renewal info, AutoRenewStatus is 1 while the subscription renews
+type RenewalInfo struct {
+	OriginalTransactionId string `json:"originalTransactionId"`
+	AutoRenewProductId    string `json:"autoRenewProductId"`
+	AutoRenewStatus       int    `json:"autoRenewStatus"`
+}
+
+// NotificationPayload is the payload of a signed notification
+type NotificationPayload struct {
+	NotificationType string           `json:"notificationType"`
+	Subtype          string           `json:"subtype,omitempty"`
+	NotificationUUID string           `json:"notificationUUID"`
+	Data             NotificationData `json:"data"`
+	SignedDate       int64            `json:"signedDate"`
+}
+
+type NotificationData struct {
+	BundleId              string `json:"bundleId"`
+	SignedTransactionInfo string `json:"signedTransactionInfo,omitempty"`
+	SignedRenewalInfo     string `json:"signedRenewalInfo,omitempty"`
+}
+
+// NotificationBody is what the App Store posts to the notification URL
+type NotificationBody struct {
+	SignedPayload string `json:"signedPayload"`
+}
+
+type header struct {
+	Alg string   `json:"alg"`
+	X5c []string `json:"x5c"`
+}
+
+type verifier struct {
+	roots    *x509.CertPool
+	bundleId string
+}
+
+// NewVerifier returns a verifier accepting JWS signed by a certificate chain leading to one of roots,
+// Apple Root CA - G3 in production, for the app with bundleId
+func NewVerifier(roots *x509.CertPool, bundleId string) iap.Verifier {
+	return &verifier{roots: roots, bundleId: bundleId}
+}
+
+// VerifyReceipt verifies a signed transaction. A transaction tells nothing about renewal,
+// its subscription is taken to renew until a notification says otherwise.
+func (v *verifier) VerifyReceipt(ctx context.Context, receipt string) (*iap.Purchase, error) {
+	var transaction Transaction
+	if err := v.decode(receipt, &transaction); err != nil {
+		return nil, err
+	}
+	if transaction.BundleId != v.bundleId {
+		return nil, fmt.Errorf("%w: transaction of bundle %q", iap.ErrInvalidReceipt, transaction.BundleId)
+	}
+	if transaction.Type != TypeAutoRenewable {
+		return nil, iap.ErrNotSubscription
+	}
+	return purchase(&transaction, transaction.RevocationDate == 0), nil
+}
+
+// VerifyNotification verifies the signed payload of the body, the header carries nothing the App Store signs
+func (v *verifier) VerifyNotification(ctx context.Context, header http.Header, body []byte) (*iap.Notification, error) {
+	var envelope NotificationBody
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, fmt.Errorf("%w: %v", iap.ErrInvalidReceipt, err)
+	}
+
+	var payload NotificationPayload
+	if err := v.decode(envelope.SignedPayload, &payload); err != nil {
+		return nil, err
+	}
+	if payload.Data.BundleId != v.bundleId {
+		return nil, fmt.Errorf("%w: notification of bundle %q", iap.ErrInvalidReceipt, payload.Data.BundleId)
+	}
+
+	notification := &iap.Notification{NotificationId: payload.NotificationUUID, Type: payload.NotificationType}
+	if payload.Subtype != "" {
+		notification.Type += "/" + payload.Subtype
+	}
+	if payload.Data.SignedTransactionInfo == "" {
+		return notification, nil
+	}
+
+	var transaction Transaction
+	if err := v.decode(payload.Data.SignedTransactionInfo, &transaction); err != nil {
+		return nil, err
+	}
+	if transaction.Type != TypeAutoRenewable {
+		return notification, nil
+	}
+
+	autoRenew := transaction.RevocationDate == 0
+	if payload.Data.SignedRenewalInfo != "" {
+		var renewal RenewalInfo
+		if err := v.decode(payload.Data.SignedRenewalInfo, &renewal); err != nil {
+			return nil, err
+		}
+		autoRenew = autoRenew && renewal.AutoRenewStatus == 1
+	}
+	notification.Purchase = purchase(&transaction, autoRenew)
+
+	return notification, nil
+}
+
+func purchase(transaction *Transaction, autoRenew bool) *iap.Purchase {
+	return &iap.Purchase{
+		Store:                 iap.StoreAppStore,
+		ProductId:             transaction.ProductId,
+		OriginalTransactionId: transaction.OriginalTransactionId,
+		TransactionId:         transaction.TransactionId,
+		PurchasedAt:           time.UnixMilli(transaction.PurchaseDate),
+		ExpiresAt:             time.UnixMilli(transaction.ExpiresDate),
+		AutoRenew:             autoRenew,
+		Revoked:               transaction.RevocationDate != 0,
+	}
+}
+
+// decode verifies a compact ES256 JWS against the x5c certificate chain of its header, which must lead
+// to one of the roots through an intermediate marked by Apple, and unmarshals its payload into dst
+func (v *verifier) decode(jws string, dst any) error {
+	parts := strings.Split(jws, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("%w: malformed JWS", iap.ErrInvalidReceipt)
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return fmt.Errorf("%w: %v", iap.ErrInvalidReceipt, err)
+	}
+	var h header
+	if err := json.Unmarshal(headerJSON, &h); err != nil {
+		return fmt.Errorf("%w: %v", iap.ErrInvalidReceipt, err)
+	}
+	if h.Alg != "ES256" || len(h.X5c) == 0 {
+		return fmt.Errorf("%w: unsupported JWS header", iap.ErrInvalidReceipt)
+	}
+
+	intermediates := x509.NewCertPool()
+	var leaf *x509.Certificate
+	for i, encoded := range h.X5c {
+		der, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("%w: %v", iap.ErrInvalidReceipt, err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return fmt.Errorf("%w: %v", iap.ErrInvalidReceipt, err)
+		}
+		if i == 0 {
+			leaf = cert
+		} else {
+			intermediates.AddCert(cert)
+		}
+	}
+
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         v.roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", iap.ErrInvalidReceipt, err)
+	}
+	if !slices.ContainsFunc(chains, appleChain) {
+		return fmt.Errorf("%w: certificate chain lacks Apple's receipt signing extensions", iap.ErrInvalidReceipt)
+	}
+
+	key, ok := leaf.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("%w: signing key is not ECDSA", iap.ErrInvalidReceipt)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(signature) != 64 {
+		return fmt.Errorf("%w: malformed signature", iap.ErrInvalidReceipt)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(key, digest[:], r, s) {
+		return fmt.Errorf("%w: bad signature", iap.ErrInvalidReceipt)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return fmt.Errorf("%w: %v", iap.ErrInvalidReceipt, err)
+	}
+	if err := json.Unmarshal(payload, dst); err != nil {
+		return fmt.Errorf("%w: %v", iap.ErrInvalidReceipt, err)
+	}
+	return nil
+}
+
+// appleChain tells whether a verified chain runs from a receipt signing leaf through an Apple intermediate
+func appleChain(chain []*x509.Certificate) bool {
+	return len(chain) >= 3 && hasExtension(chain[0], OIDReceiptSigning) && hasExtension(chain[1], OIDIntermediate)
+}
+
+func hasExtension(cert *x509.Certificate, oid asn1.ObjectIdentifier) bool {
+	return slices.ContainsFunc(cert.Extensions, func(extension pkix.Extension) bool {
+		return extension.Id.Equal(oid)
+	})
+}
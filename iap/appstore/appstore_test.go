@@ -0,0 +1,164 @@
+package appstore_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlvinTendio/minder/iap"
+	"github.com/AlvinTendio/minder/iap/appstore"
+	"github.com/AlvinTendio/minder/iap/fake"
+)
+
+const (
+	testBundleId = "com.minder.app"
+
+	// testTransactionId is a renewing monthly subscription of the fake's fixtures
+	testTransactionId = "2000000000000001"
+)
+
+// signedTransaction starts a fake App Store for bundleId and returns the roots it signs under and a signed fixture transaction
+func signedTransaction(t *testing.T, bundleId string) (*x509.CertPool, string) {
+	t.Helper()
+	server, err := fake.NewServer(fake.Config{BundleId: bundleId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := httptest.NewServer(server)
+	t.Cleanup(store.Close)
+
+	resp, err := http.Get(store.URL + "/appstore/transactions/" + testTransactionId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		SignedTransaction string `json:"signedTransaction"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(server.RootPEM()) {
+		t.Fatal("fake root is not PEM")
+	}
+	return roots, body.SignedTransaction
+}
+
+func TestVerifyReceipt(t *testing.T) {
+	roots, receipt := signedTransaction(t, testBundleId)
+
+	purchase, err := appstore.NewVerifier(roots, testBundleId).VerifyReceipt(context.Background(), receipt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purchase.OriginalTransactionId != testTransactionId || purchase.Revoked || !purchase.ExpiresAt.After(time.Now()) {
+		t.Errorf("verified purchase %+v, want transaction %s within its period", purchase, testTransactionId)
+	}
+}
+
+func TestVerifyReceiptRejects(t *testing.T) {
+	roots, receipt := signedTransaction(t, testBundleId)
+	otherRoots, otherBundle := signedTransaction(t, "com.other.app")
+	unmarkedRoots, unmarked := unmarkedChain(t)
+
+	parts := strings.Split(receipt, ".")
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	signature[len(signature)-1] ^= 1
+	tampered := parts[0] + "." + parts[1] + "." + base64.RawURLEncoding.EncodeToString(signature)
+
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	payload = []byte(strings.Replace(string(payload), `"productId":"minder_premium_monthly"`, `"productId":"minder_premium_yearly"`, 1))
+	forged := parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload) + "." + parts[2]
+
+	for name, test := range map[string]struct {
+		roots   *x509.CertPool
+		receipt string
+	}{
+		"wrong bundle":       {otherRoots, otherBundle},
+		"tampered signature": {roots, tampered},
+		"tampered payload":   {roots, forged},
+		"untrusted root":     {otherRoots, receipt},
+		"no Apple markers":   {unmarkedRoots, unmarked},
+		"malformed":          {roots, "not-a-jws"},
+	} {
+		_, err := appstore.NewVerifier(test.roots, testBundleId).VerifyReceipt(context.Background(), test.receipt)
+		if !errors.Is(err, iap.ErrInvalidReceipt) {
+			t.Errorf("%s receipt verified with err %v, want %v", name, err, iap.ErrInvalidReceipt)
+		}
+	}
+}
+
+// unmarkedChain signs a valid transaction with a root, intermediate and leaf lacking Apple's extensions
+func unmarkedChain(t *testing.T) (*x509.CertPool, string) {
+	t.Helper()
+	roots := x509.NewCertPool()
+	var chain []string
+	var parent *x509.Certificate
+	var parentKey, key *ecdsa.PrivateKey
+	for i, name := range []string{"root", "intermediate", "leaf"} {
+		var err error
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template := &x509.Certificate{
+			SerialNumber:          big.NewInt(int64(i + 1)),
+			Subject:               pkix.Name{CommonName: name},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			IsCA:                  name != "leaf",
+			BasicConstraintsValid: true,
+		}
+		if parent == nil {
+			parent, parentKey = template, key
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parent, err = x509.ParseCertificate(der); err != nil {
+			t.Fatal(err)
+		}
+		parentKey = key
+		if name == "root" {
+			roots.AddCert(parent)
+		}
+		chain = append([]string{base64.StdEncoding.EncodeToString(der)}, chain...)
+	}
+
+	header, _ := json.Marshal(map[string]any{"alg": "ES256", "x5c": chain})
+	payload, _ := json.Marshal(&appstore.Transaction{
+		TransactionId:         testTransactionId,
+		OriginalTransactionId: testTransactionId,
+		BundleId:              testBundleId,
+		ProductId:             "minder_premium_monthly",
+		PurchaseDate:          time.Now().UnixMilli(),
+		ExpiresDate:           time.Now().AddDate(0, 1, 0).UnixMilli(),
+		Type:                  appstore.TypeAutoRenewable,
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return roots, unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
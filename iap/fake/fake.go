@@ -0,0 +1,563 @@
+// Package fake is a local stand-in for the app stores. It signs App Store transactions and
+// notifications with a certificate chain of its own, serves the Google Play Developer API
+// endpoints the googleplay verifier calls, and sends both stores' notifications, Google Play's
+// with a push token signed by a key of its own, so receipts can be validated offline with the
+// real verifiers.
+package fake
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"embed"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AlvinTendio/minder/iap"
+	"github.com/AlvinTendio/minder/iap/appstore"
+	"github.com/AlvinTendio/minder/iap/googleplay"
+)
+
+const (
+	EventRenew  = "renew"
+	EventCancel = "cancel"
+	EventResume = "resume"
+	EventExpire = "expire"
+	EventRefund = "refund"
+
+	webhookTimeout = 10 * time.Second
+
+	// pushKeyId names the key push tokens are signed with
+	pushKeyId = "fake-push-key"
+)
+
+// appStoreNotifications and googlePlayNotifications are the notification types the stores send for each event
+var (
+	appStoreNotifications = map[string]struct{ Type, Subtype string }{
+		EventRenew:  {"DID_RENEW", ""},
+		EventCancel: {"DID_CHANGE_RENEWAL_STATUS", "AUTO_RENEW_DISABLED"},
+		EventResume: {"DID_CHANGE_RENEWAL_STATUS", "AUTO_RENEW_ENABLED"},
+		EventExpire: {"EXPIRED", "VOLUNTARY"},
+		EventRefund: {"REFUND", ""},
+	}
+
+	googlePlayNotifications = map[string]int{
+		EventRenew:  2,
+		EventCancel: 3,
+		EventResume: 7,
+		EventExpire: 13,
+		EventRefund: googleplay.NotificationRevoked,
+	}
+)
+
+// fixtures holds the subscriptions the stand-in starts with
+//
+//go:embed fixtures
+var fixtures embed.FS
+
+type subscription struct {
+	Store         string `json:"store"`
+	Id            string `json:"id"`
+	ProductId     string `json:"productId"`
+	PeriodDays    int    `json:"periodDays"`
+	ExpiresInDays int    `json:"expiresInDays"`
+	AutoRenew     bool   `json:"autoRenew"`
+	Revoked       bool   `json:"revoked"`
+
+	renewals     int
+	purchasedAt  time.Time
+	expiresAt    time.Time
+	revokedAt    time.Time
+	acknowledged bool
+}
+
+// Config of the stand-in. Push tokens are issued to PushServiceAccount for the audience GooglePlayWebhook,
+// as Pub/Sub does by default.
+type Config struct {
+	BundleId           string
+	PackageName        string
+	AccessToken        string
+	AppStoreWebhook    string
+	GooglePlayWebhook  string
+	PushServiceAccount string
+}
+
+// Server serves:
+//
+//	GET  /appstore/transactions/{id}                 the signed transaction of a subscription, the receipt the app sends
+//	POST /appstore/transactions/{id}/notify?event=   changes the subscription and sends the App Store notification
+//	POST /googleplay/purchases/{token}/notify?event= changes the subscription and pushes the Google Play notification
+//	GET  /androidpublisher/v3/applications/{package}/purchases/subscriptionsv2/tokens/{token}
+//	POST /androidpublisher/v3/applications/{package}/purchases/subscriptions/{productId}/tokens/{token}:acknowledge
+//	GET  /oauth2/v3/certs                            the keys push tokens are signed with
+//
+// Events are renew, cancel, resume, expire and refund.
+type Server struct {
+	config  Config
+	key     *ecdsa.PrivateKey
+	chain   []string
+	rootPEM []byte
+	pushKey *rsa.PrivateKey
+	client  *http.Client
+
+	mu            sync.Mutex
+	subscriptions map[string]*subscription
+}
+
+// NewServer returns a stand-in loaded with the fixture subscriptions, their dates relative to now
+func NewServer(config Config) (*Server, error) {
+	s := &Server{
+		config:        config,
+		client:        &http.Client{Timeout: webhookTimeout},
+		subscriptions: make(map[string]*subscription),
+	}
+
+	if err := s.newChain(); err != nil {
+		return nil, err
+	}
+	var err error
+	if s.pushKey, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		return nil, err
+	}
+
+	data, err := fixtures.ReadFile("fixtures/subscriptions.json")
+	if err != nil {
+		return nil, err
+	}
+	var subscriptions []*subscription
+	if err := json.Unmarshal(data, &subscriptions); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, sub := range subscriptions {
+		sub.purchasedAt = now
+		sub.expiresAt = now.AddDate(0, 0, sub.ExpiresInDays)
+		if sub.Revoked {
+			sub.revokedAt = now
+		}
+		s.subscriptions[sub.Id] = sub
+	}
+
+	return s, nil
+}
+
+// RootPEM returns the root certificate the stand-in's App Store chain leads to. The chain is made
+// for each server, so nothing outside the process can sign with it.
+func (s *Server) RootPEM() []byte {
+	return s.rootPEM
+}
+
+// newChain makes the App Store signing chain: a root, an intermediate and the signing leaf, the leaf
+// and the intermediate carrying the extensions Apple marks its receipt certificates with
+func (s *Server) newChain() error {
+	root := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Fake Apple Root CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	intermediate := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Fake Apple Worldwide Developer Relations CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		ExtraExtensions:       []pkix.Extension{{Id: appstore.OIDIntermediate, Value: asn1.NullBytes}},
+	}
+	leaf := &x509.Certificate{
+		Subject:         pkix.Name{CommonName: "Fake Prod ECC Mac App Store and iTunes Store Receipt Signing"},
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtraExtensions: []pkix.Extension{{Id: appstore.OIDReceiptSigning, Value: asn1.NullBytes}},
+	}
+
+	var parent *x509.Certificate
+	var parentKey *ecdsa.PrivateKey
+	for i, template := range []*x509.Certificate{root, intermediate, leaf} {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return err
+		}
+		template.SerialNumber = big.NewInt(int64(i + 1))
+		template.NotBefore = time.Now().Add(-time.Hour)
+		template.NotAfter = time.Now().AddDate(1, 0, 0)
+		if parent == nil {
+			parent, parentKey = template, key
+		}
+
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+		if err != nil {
+			return err
+		}
+		if parent, err = x509.ParseCertificate(der); err != nil {
+			return err
+		}
+		parentKey = key
+
+		// x5c starts from the leaf
+		s.chain = append([]string{base64.StdEncoding.EncodeToString(der)}, s.chain...)
+		if template == root {
+			s.rootPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+		}
+	}
+	s.key = parentKey
+	return nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/oauth2/v3/certs":
+		writeJSON(w, &googleplay.JWKS{Keys: []googleplay.JWK{googleplay.PublicJWK(pushKeyId, &s.pushKey.PublicKey)}})
+	case r.Method == http.MethodGet && len(parts) == 3 && parts[0] == "appstore" && parts[1] == "transactions":
+		s.signedTransaction(w, parts[2])
+	case r.Method == http.MethodPost && len(parts) == 4 && parts[0] == "appstore" && parts[1] == "transactions" && parts[3] == "notify":
+		s.notify(w, iap.StoreAppStore, parts[2], r.URL.Query().Get("event"))
+	case r.Method == http.MethodPost && len(parts) == 4 && parts[0] == "googleplay" && parts[1] == "purchases" && parts[3] == "notify":
+		s.notify(w, iap.StoreGooglePlay, parts[2], r.URL.Query().Get("event"))
+	case len(parts) > 3 && parts[0] == "androidpublisher" && parts[3] == s.config.PackageName:
+		if r.Header.Get("Authorization") != "Bearer "+s.config.AccessToken {
+			http.Error(w, "invalid access token", http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && len(parts) == 8 && parts[5] == "subscriptionsv2":
+			s.subscriptionPurchase(w, parts[7])
+		case r.Method == http.MethodPost && len(parts) == 9 && parts[5] == "subscriptions" && strings.HasSuffix(parts[8], ":acknowledge"):
+			s.acknowledge(w, strings.TrimSuffix(parts[8], ":acknowledge"))
+		default:
+			http.NotFound(w, r)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// find returns a copy of the store's subscription, so it can be used without holding the lock
+func (s *Server) find(store, id string) (subscription, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subscriptions[id]
+	if !ok || sub.Store != store {
+		return subscription{}, false
+	}
+	return *sub, true
+}
+
+func (s *Server) signedTransaction(w http.ResponseWriter, id string) {
+	sub, ok := s.find(iap.StoreAppStore, id)
+	if !ok {
+		http.Error(w, "unknown transaction", http.StatusNotFound)
+		return
+	}
+
+	jws, err := s.sign(s.transaction(&sub))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]string{"signedTransaction": jws})
+}
+
+func (s *Server) subscriptionPurchase(w http.ResponseWriter, token string) {
+	sub, ok := s.find(iap.StoreGooglePlay, token)
+	if !ok {
+		http.Error(w, "unknown purchase token", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, purchase(&sub))
+}
+
+func (s *Server) acknowledge(w http.ResponseWriter, token string) {
+	s.mu.Lock()
+	sub, ok := s.subscriptions[token]
+	if ok {
+		sub.acknowledged = true
+	}
+	s.mu.Unlock()
+
+	if !ok || sub.Store != iap.StoreGooglePlay {
+		http.Error(w, "unknown purchase token", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// notify applies the event to the subscription and sends the store's notification about it
+func (s *Server) notify(w http.ResponseWriter, store, id, event string) {
+	now := time.Now()
+
+	s.mu.Lock()
+	sub, ok := s.subscriptions[id]
+	valid := ok && sub.Store == store
+	if valid {
+		switch event {
+		case EventRenew:
+			start := sub.expiresAt
+			if start.Before(now) {
+				start = now
+			}
+			sub.expiresAt = start.AddDate(0, 0, sub.PeriodDays)
+			sub.renewals++
+			sub.AutoRenew = true
+			// renewing a refunded subscription is the user subscribing again
+			sub.Revoked, sub.revokedAt = false, time.Time{}
+		case EventCancel:
+			sub.AutoRenew = false
+		case EventResume:
+			sub.AutoRenew = true
+		case EventExpire:
+			sub.expiresAt = now
+			sub.AutoRenew = false
+		case EventRefund:
+			sub.Revoked = true
+			sub.revokedAt = now
+			sub.AutoRenew = false
+			if store == iap.StoreGooglePlay {
+				sub.expiresAt = now
+			}
+		default:
+			valid = false
+		}
+	}
+	var snapshot subscription
+	if valid {
+		snapshot = *sub
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		http.Error(w, "unknown subscription", http.StatusNotFound)
+		return
+	}
+	if !valid {
+		http.Error(w, "invalid event "+strconv.Quote(event), http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	if store == iap.StoreAppStore {
+		err = s.sendAppStoreNotification(&snapshot, event)
+	} else {
+		err = s.sendGooglePlayNotification(&snapshot, event)
+	}
+	if err != nil {
+		log.Println("[iap:fake] send notification err", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) transaction(sub *subscription) *appstore.Transaction {
+	transaction := &appstore.Transaction{
+		TransactionId:         fmt.Sprintf("%s%03d", sub.Id, sub.renewals),
+		OriginalTransactionId: sub.Id,
+		BundleId:              s.config.BundleId,
+		ProductId:             sub.ProductId,
+		PurchaseDate:          sub.purchasedAt.UnixMilli(),
+		ExpiresDate:           sub.expiresAt.UnixMilli(),
+		Type:                  appstore.TypeAutoRenewable,
+	}
+	if sub.Revoked {
+		transaction.RevocationDate = sub.revokedAt.UnixMilli()
+	}
+	return transaction
+}
+
+func (s *Server) sendAppStoreNotification(sub *subscription, event string) error {
+	kind := appStoreNotifications[event]
+
+	transactionInfo, err := s.sign(s.transaction(sub))
+	if err != nil {
+		return err
+	}
+	autoRenewStatus := 0
+	if sub.AutoRenew {
+		autoRenewStatus = 1
+	}
+	renewalInfo, err := s.sign(&appstore.RenewalInfo{
+		OriginalTransactionId: sub.Id,
+		AutoRenewProductId:    sub.ProductId,
+		AutoRenewStatus:       autoRenewStatus,
+	})
+	if err != nil {
+		return err
+	}
+
+	payload, err := s.sign(&appstore.NotificationPayload{
+		NotificationType: kind.Type,
+		Subtype:          kind.Subtype,
+		NotificationUUID: randomId(),
+		Data: appstore.NotificationData{
+			BundleId:              s.config.BundleId,
+			SignedTransactionInfo: transactionInfo,
+			SignedRenewalInfo:     renewalInfo,
+		},
+		SignedDate: time.Now().UnixMilli(),
+	})
+	if err != nil {
+		return err
+	}
+	return s.post(s.config.AppStoreWebhook, "", &appstore.NotificationBody{SignedPayload: payload})
+}
+
+func (s *Server) sendGooglePlayNotification(sub *subscription, event string) error {
+	data, err := json.Marshal(&googleplay.DeveloperNotification{
+		PackageName:     s.config.PackageName,
+		EventTimeMillis: strconv.FormatInt(time.Now().UnixMilli(), 10),
+		SubscriptionNotification: &googleplay.SubscriptionNotification{
+			NotificationType: googlePlayNotifications[event],
+			PurchaseToken:    sub.Id,
+			SubscriptionId:   sub.ProductId,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	var push googleplay.PushBody
+	push.Message.Data = base64.StdEncoding.EncodeToString(data)
+	push.Message.MessageId = randomId()
+	push.Subscription = "projects/fake/subscriptions/minder-rtdn"
+
+	token, err := s.pushToken()
+	if err != nil {
+		return err
+	}
+	return s.post(s.config.GooglePlayWebhook, "Bearer "+token, &push)
+}
+
+// pushToken returns the OIDC token Pub/Sub sends along with a push, signed with the push key
+func (s *Server) pushToken() (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": pushKeyId, "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims, err := json.Marshal(&googleplay.PushClaims{
+		Issuer:        "https://accounts.google.com",
+		Audience:      s.config.GooglePlayWebhook,
+		Email:         s.config.PushServiceAccount,
+		EmailVerified: true,
+		IssuedAt:      now.Unix(),
+		ExpiresAt:     now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.pushKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func purchase(sub *subscription) *googleplay.SubscriptionPurchase {
+	state := googleplay.SubscriptionStateActive
+	switch {
+	case !sub.expiresAt.After(time.Now()):
+		state = "SUBSCRIPTION_STATE_EXPIRED"
+	case !sub.AutoRenew:
+		state = "SUBSCRIPTION_STATE_CANCELED"
+	}
+	acknowledgement := googleplay.AcknowledgementPending
+	if sub.acknowledged {
+		acknowledgement = "ACKNOWLEDGEMENT_STATE_ACKNOWLEDGED"
+	}
+
+	return &googleplay.SubscriptionPurchase{
+		StartTime:            sub.purchasedAt,
+		SubscriptionState:    state,
+		LatestOrderId:        fmt.Sprintf("GPA.%s..%d", sub.Id, sub.renewals),
+		AcknowledgementState: acknowledgement,
+		LineItems: []googleplay.LineItem{{
+			ProductId:        sub.ProductId,
+			ExpiryTime:       sub.expiresAt,
+			AutoRenewingPlan: &googleplay.AutoRenewingPlan{AutoRenewEnabled: sub.AutoRenew},
+		}},
+	}
+}
+
+// sign returns payload as a compact ES256 JWS carrying the stand-in's chain, the way the App Store signs
+func (s *Server) sign(payload any) (string, error) {
+	header, err := json.Marshal(map[string]any{"alg": "ES256", "x5c": s.chain})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	r, sig, err := ecdsa.Sign(rand.Reader, s.key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	sig.FillBytes(signature[32:])
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func (s *Server) post(url, authorization string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("notification url answered %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("[iap:fake] write response err", err)
+	}
+}
+
+func randomId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,268 @@
+// Package googleplay verifies Google Play subscription purchase tokens and real-time developer
+// notifications through the Google Play Developer API.
+package googleplay
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/AlvinTendio/minder/iap"
+)
+
+const (
+	DefaultBaseURL = "https://androidpublisher.googleapis.com"
+
+	SubscriptionStateActive      = "SUBSCRIPTION_STATE_ACTIVE"
+	SubscriptionStateGracePeriod = "SUBSCRIPTION_STATE_IN_GRACE_PERIOD"
+	SubscriptionStateCanceled    = "SUBSCRIPTION_STATE_CANCELED"
+	SubscriptionStateExpired     = "SUBSCRIPTION_STATE_EXPIRED"
+	AcknowledgementPending       = "ACKNOWLEDGEMENT_STATE_PENDING"
+
+	// NotificationRevoked is the notification type of a subscription revoked before its expiry, after a refund
+	NotificationRevoked = 12
+
+	requestTimeout = 10 * time.Second
+)
+
+// notificationTypes names the subscription notification types
+var notificationTypes = map[int]string{
+	1:  "SUBSCRIPTION_RECOVERED",
+	2:  "SUBSCRIPTION_RENEWED",
+	3:  "SUBSCRIPTION_CANCELED",
+	4:  "SUBSCRIPTION_PURCHASED",
+	5:  "SUBSCRIPTION_ON_HOLD",
+	6:  "SUBSCRIPTION_IN_GRACE_PERIOD",
+	7:  "SUBSCRIPTION_RESTARTED",
+	10: "SUBSCRIPTION_PAUSED",
+	12: "SUBSCRIPTION_REVOKED",
+	13: "SUBSCRIPTION_EXPIRED",
+}
+
+// SubscriptionPurchase is the part of a purchases.subscriptionsv2 resource used here
+type SubscriptionPurchase struct {
+	StartTime            time.Time  `json:"startTime"`
+	SubscriptionState    string     `json:"subscriptionState"`
+	LatestOrderId        string     `json:"latestOrderId"`
+	AcknowledgementState string     `json:"acknowledgementState"`
+	LineItems            []LineItem `json:"lineItems"`
+}
+
+type LineItem struct {
+	ProductId        string            `json:"productId"`
+	ExpiryTime       time.Time         `json:"expiryTime"`
+	AutoRenewingPlan *AutoRenewingPlan `json:"autoRenewingPlan,omitempty"`
+}
+
+type AutoRenewingPlan struct {
+	AutoRenewEnabled bool `json:"autoRenewEnabled"`
+}
+
+// DeveloperNotification is what Google Play publishes for a subscription change
+type DeveloperNotification struct {
+	PackageName              string                    `json:"packageName"`
+	EventTimeMillis          string                    `json:"eventTimeMillis"`
+	SubscriptionNotification *SubscriptionNotification `json:"subscriptionNotification,omitempty"`
+}
+
+type SubscriptionNotification struct {
+	NotificationType int    `json:"notificationType"`
+	PurchaseToken    string `json:"purchaseToken"`
+	SubscriptionId   string `json:"subscriptionId"`
+}
+
+// PushBody is what a Pub/Sub push subscription posts, Data is the base64 encoded DeveloperNotification
+type PushBody struct {
+	Message struct {
+		Data      string `json:"data"`
+		MessageId string `json:"messageId"`
+	} `json:"message"`
+	Subscription string `json:"subscription"`
+}
+
+type verifier struct {
+	baseURL     string
+	packageName string
+	tokens      TokenSource
+	push        *PushAuthenticator
+	client      *http.Client
+}
+
+// NewVerifier returns a verifier for the app packageName, calling the Developer API at baseURL
+// with access tokens from tokens and accepting notifications push authenticates
+func NewVerifier(baseURL, packageName string, tokens TokenSource, push *PushAuthenticator) iap.Verifier {
+	return &verifier{
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+		packageName: packageName,
+		tokens:      tokens,
+		push:        push,
+		client:      &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// VerifyReceipt looks the purchase token up and acknowledges the purchase if it is not yet,
+// Google Play refunds purchases left unacknowledged for three days
+func (v *verifier) VerifyReceipt(ctx context.Context, receipt string) (*iap.Purchase, error) {
+	subscription, err := v.getSubscription(ctx, receipt)
+	if err != nil {
+		return nil, err
+	}
+
+	purchase, err := toPurchase(receipt, subscription)
+	if err != nil {
+		return nil, err
+	}
+
+	if subscription.AcknowledgementState == AcknowledgementPending && subscription.SubscriptionState == SubscriptionStateActive {
+		if err := v.acknowledge(ctx, purchase.ProductId, receipt); err != nil {
+			return nil, err
+		}
+	}
+
+	return purchase, nil
+}
+
+// VerifyNotification authenticates the push, then reads the subscription's state from the Developer API
+// rather than trusting the notification. A token Google Play does not know makes the notification invalid.
+// Pub/Sub redelivers a message under a new id, so the notification is identified by its purchase token
+// and event time, hashed to keep it short.
+func (v *verifier) VerifyNotification(ctx context.Context, header http.Header, body []byte) (*iap.Notification, error) {
+	if err := v.push.Authenticate(ctx, header.Get("Authorization")); err != nil {
+		return nil, err
+	}
+
+	var push PushBody
+	if err := json.Unmarshal(body, &push); err != nil {
+		return nil, fmt.Errorf("%w: %v", iap.ErrInvalidReceipt, err)
+	}
+	data, err := base64.StdEncoding.DecodeString(push.Message.Data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", iap.ErrInvalidReceipt, err)
+	}
+	var developerNotification DeveloperNotification
+	if err := json.Unmarshal(data, &developerNotification); err != nil {
+		return nil, fmt.Errorf("%w: %v", iap.ErrInvalidReceipt, err)
+	}
+	if developerNotification.PackageName != v.packageName {
+		return nil, fmt.Errorf("%w: notification of package %q", iap.ErrInvalidReceipt, developerNotification.PackageName)
+	}
+
+	subscriptionNotification := developerNotification.SubscriptionNotification
+	if subscriptionNotification == nil {
+		return &iap.Notification{NotificationId: push.Message.MessageId}, nil
+	}
+	id := sha256.Sum256([]byte(subscriptionNotification.PurchaseToken + "/" + developerNotification.EventTimeMillis))
+	notification := &iap.Notification{NotificationId: hex.EncodeToString(id[:])}
+	notification.Type = notificationTypes[subscriptionNotification.NotificationType]
+	if notification.Type == "" {
+		notification.Type = fmt.Sprintf("SUBSCRIPTION_%d", subscriptionNotification.NotificationType)
+	}
+
+	subscription, err := v.getSubscription(ctx, subscriptionNotification.PurchaseToken)
+	if err != nil {
+		return nil, err
+	}
+	notification.Purchase, err = toPurchase(subscriptionNotification.PurchaseToken, subscription)
+	if err != nil {
+		return nil, err
+	}
+
+	return notification, nil
+}
+
+// toPurchase takes the subscription's state as the Developer API reports it. A revoked subscription is
+// expired at once and an expired one never comes back, so expired is taken as revoked. A subscription
+// neither active, in its grace period nor canceled within its period is pending, paused or on hold,
+// and entitles to nothing until Google Play reports it active again.
+func toPurchase(token string, subscription *SubscriptionPurchase) (*iap.Purchase, error) {
+	if len(subscription.LineItems) == 0 {
+		return nil, iap.ErrNotSubscription
+	}
+	item := subscription.LineItems[0]
+	if item.AutoRenewingPlan == nil {
+		return nil, iap.ErrNotSubscription
+	}
+
+	purchase := &iap.Purchase{
+		Store:                 iap.StoreGooglePlay,
+		ProductId:             item.ProductId,
+		OriginalTransactionId: token,
+		TransactionId:         subscription.LatestOrderId,
+		PurchasedAt:           subscription.StartTime,
+		ExpiresAt:             item.ExpiryTime,
+		AutoRenew:             item.AutoRenewingPlan.AutoRenewEnabled,
+	}
+	switch subscription.SubscriptionState {
+	case SubscriptionStateActive, SubscriptionStateGracePeriod:
+	case SubscriptionStateCanceled:
+		purchase.AutoRenew = false
+	case SubscriptionStateExpired:
+		purchase.Revoked = true
+		purchase.AutoRenew = false
+	default:
+		if now := time.Now(); purchase.ExpiresAt.After(now) {
+			purchase.ExpiresAt = now
+		}
+	}
+	return purchase, nil
+}
+
+func (v *verifier) getSubscription(ctx context.Context, token string) (*SubscriptionPurchase, error) {
+	endpoint := fmt.Sprintf("%s/androidpublisher/v3/applications/%s/purchases/subscriptionsv2/tokens/%s",
+		v.baseURL, url.PathEscape(v.packageName), url.PathEscape(token))
+
+	var subscription SubscriptionPurchase
+	if err := v.do(ctx, http.MethodGet, endpoint, nil, &subscription); err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+func (v *verifier) acknowledge(ctx context.Context, productId, token string) error {
+	endpoint := fmt.Sprintf("%s/androidpublisher/v3/applications/%s/purchases/subscriptions/%s/tokens/%s:acknowledge",
+		v.baseURL, url.PathEscape(v.packageName), url.PathEscape(productId), url.PathEscape(token))
+	return v.do(ctx, http.MethodPost, endpoint, []byte("{}"), nil)
+}
+
+// do calls the Developer API, a token it does not know answers ErrInvalidReceipt
+func (v *verifier) do(ctx context.Context, method, endpoint string, body []byte, dst any) error {
+	accessToken, err := v.tokens.Token(ctx)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone || resp.StatusCode == http.StatusBadRequest:
+		return fmt.Errorf("%w: developer api answered %s", iap.ErrInvalidReceipt, resp.Status)
+	case resp.StatusCode >= http.StatusMultipleChoices:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("developer api answered %s: %s", resp.Status, msg)
+	case dst == nil:
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(dst)
+}
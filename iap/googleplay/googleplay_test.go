@@ -0,0 +1,186 @@
+package googleplay_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/AlvinTendio/minder/iap"
+	"github.com/AlvinTendio/minder/iap/fake"
+	"github.com/AlvinTendio/minder/iap/googleplay"
+)
+
+const (
+	testPackageName    = "com.minder.app"
+	testAccessToken    = "test-access-token"
+	testServiceAccount = "pubsub@minder.iam.gserviceaccount.com"
+
+	// testToken is a renewing monthly subscription of the fake's fixtures
+	testToken = "fake-token-monthly"
+)
+
+// pushFixture runs the fake Google Play, whose notifications are kept as they were pushed
+type pushFixture struct {
+	store   *httptest.Server
+	webhook *httptest.Server
+
+	mu     sync.Mutex
+	header http.Header
+	body   []byte
+}
+
+func newPushFixture(t *testing.T) *pushFixture {
+	t.Helper()
+	f := &pushFixture{}
+	f.webhook = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		f.mu.Lock()
+		f.header, f.body = r.Header.Clone(), body
+		f.mu.Unlock()
+	}))
+	t.Cleanup(f.webhook.Close)
+
+	server, err := fake.NewServer(fake.Config{
+		PackageName:        testPackageName,
+		AccessToken:        testAccessToken,
+		GooglePlayWebhook:  f.webhook.URL,
+		PushServiceAccount: testServiceAccount,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.store = httptest.NewServer(server)
+	t.Cleanup(f.store.Close)
+	return f
+}
+
+func (f *pushFixture) verifier(audience, serviceAccount string) iap.Verifier {
+	push := googleplay.NewPushAuthenticator(f.store.URL+"/oauth2/v3/certs", audience, serviceAccount)
+	return googleplay.NewVerifier(f.store.URL, testPackageName, googleplay.StaticToken(testAccessToken), push)
+}
+
+// notify makes the fake apply event to the subscription and returns the push it sent
+func (f *pushFixture) notify(t *testing.T, event string) (http.Header, []byte) {
+	t.Helper()
+	resp, err := http.Post(f.store.URL+"/googleplay/purchases/"+testToken+"/notify?event="+event, "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("%s answered %d", event, resp.StatusCode)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.header, f.body
+}
+
+func TestVerifyNotificationAuthenticatesPush(t *testing.T) {
+	f := newPushFixture(t)
+	header, body := f.notify(t, fake.EventRenew)
+
+	notification, err := f.verifier(f.webhook.URL, testServiceAccount).VerifyNotification(context.Background(), header, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if notification.Purchase == nil || notification.Purchase.Revoked || !notification.Purchase.AutoRenew {
+		t.Errorf("renewal verified as %+v, want a renewing purchase", notification.Purchase)
+	}
+
+	// other claims under the signature of the valid token
+	parts := strings.Split(header.Get("Authorization"), ".")
+	claims, _ := json.Marshal(&googleplay.PushClaims{})
+	forged := http.Header{"Authorization": {parts[0] + "." + base64.RawURLEncoding.EncodeToString(claims) + "." + parts[2]}}
+
+	for name, test := range map[string]struct {
+		header                   http.Header
+		audience, serviceAccount string
+	}{
+		"unsigned":                     {http.Header{}, f.webhook.URL, testServiceAccount},
+		"forged":                       {forged, f.webhook.URL, testServiceAccount},
+		"other audience":               {header, "https://minder.example/iap/notifications/google-play", testServiceAccount},
+		"other service account":        {header, f.webhook.URL, "someone@else.iam.gserviceaccount.com"},
+		"unconfigured audience":        {header, "", testServiceAccount},
+		"unconfigured service account": {header, f.webhook.URL, ""},
+	} {
+		_, err := f.verifier(test.audience, test.serviceAccount).VerifyNotification(context.Background(), test.header, body)
+		if !errors.Is(err, iap.ErrInvalidReceipt) {
+			t.Errorf("%s push verified with err %v, want %v", name, err, iap.ErrInvalidReceipt)
+		}
+	}
+}
+
+func TestVerifyNotificationTakesSubscriptionState(t *testing.T) {
+	f := newPushFixture(t)
+	verifier := f.verifier(f.webhook.URL, testServiceAccount)
+
+	header, body := f.notify(t, fake.EventCancel)
+	canceled, err := verifier.VerifyNotification(context.Background(), header, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if canceled.Purchase.Revoked || canceled.Purchase.AutoRenew {
+		t.Errorf("cancellation verified as %+v, want a purchase within its period that does not renew", canceled.Purchase)
+	}
+
+	header, body = f.notify(t, fake.EventRefund)
+	revoked, err := verifier.VerifyNotification(context.Background(), header, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !revoked.Purchase.Revoked {
+		t.Errorf("refund verified as %+v, want a revoked purchase", revoked.Purchase)
+	}
+}
+
+func TestVerifyNotificationIdIgnoresMessageId(t *testing.T) {
+	f := newPushFixture(t)
+	verifier := f.verifier(f.webhook.URL, testServiceAccount)
+	header, body := f.notify(t, fake.EventRenew)
+
+	// Pub/Sub redelivers the same notification under a new message id
+	var push googleplay.PushBody
+	if err := json.Unmarshal(body, &push); err != nil {
+		t.Fatal(err)
+	}
+	push.Message.MessageId = "redelivered"
+	redelivered, _ := json.Marshal(&push)
+
+	first, err := verifier.VerifyNotification(context.Background(), header, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := verifier.VerifyNotification(context.Background(), header, redelivered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.NotificationId != second.NotificationId {
+		t.Errorf("redelivery has notification id %s, want %s", second.NotificationId, first.NotificationId)
+	}
+
+	// a later event about the same purchase is a new notification
+	var notification googleplay.DeveloperNotification
+	data, _ := base64.StdEncoding.DecodeString(push.Message.Data)
+	if err := json.Unmarshal(data, &notification); err != nil {
+		t.Fatal(err)
+	}
+	notification.EventTimeMillis += "1"
+	data, _ = json.Marshal(&notification)
+	push.Message.Data = base64.StdEncoding.EncodeToString(data)
+	later, _ := json.Marshal(&push)
+
+	third, err := verifier.VerifyNotification(context.Background(), header, later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.NotificationId == first.NotificationId {
+		t.Error("a later event shares the notification id of the first")
+	}
+}
@@ -0,0 +1,219 @@
+package googleplay
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AlvinTendio/minder/iap"
+)
+
+const (
+	// GoogleCertsURL serves the keys Google signs OIDC tokens with
+	GoogleCertsURL = "https://www.googleapis.com/oauth2/v3/certs"
+
+	// certsMaxAge is how long fetched keys are used, certsMinRefresh how long an unknown key id
+	// waits before the keys are fetched again
+	certsMaxAge     = time.Hour
+	certsMinRefresh = time.Minute
+
+	// clockSkew is how far the token's times may be off from ours
+	clockSkew = time.Minute
+)
+
+// googleIssuers are the issuers of Google's OIDC tokens
+var googleIssuers = []string{"https://accounts.google.com", "accounts.google.com"}
+
+// PushClaims are the claims of the OIDC token a Pub/Sub push subscription sends
+type PushClaims struct {
+	Issuer        string `json:"iss"`
+	Audience      string `json:"aud"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	IssuedAt      int64  `json:"iat"`
+	ExpiresAt     int64  `json:"exp"`
+}
+
+// JWKS is the key set of certsURL
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+type JWK struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// PushAuthenticator checks the OIDC token of the Authorization header Pub/Sub push subscriptions
+// send, which must be signed by Google for the audience and the service account of the subscription
+type PushAuthenticator struct {
+	certsURL       string
+	audience       string
+	serviceAccount string
+	client         *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+// NewPushAuthenticator returns a PushAuthenticator taking Google's keys from certsURL. Without an
+// audience or a service account it refuses every push.
+func NewPushAuthenticator(certsURL, audience, serviceAccount string) *PushAuthenticator {
+	return &PushAuthenticator{
+		certsURL:       certsURL,
+		audience:       audience,
+		serviceAccount: serviceAccount,
+		client:         &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// Authenticate returns ErrInvalidReceipt unless authorization holds a valid push token
+func (a *PushAuthenticator) Authenticate(ctx context.Context, authorization string) error {
+	if a.audience == "" || a.serviceAccount == "" {
+		return fmt.Errorf("%w: push audience or service account is not configured", iap.ErrInvalidReceipt)
+	}
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok {
+		return fmt.Errorf("%w: push without bearer token", iap.ErrInvalidReceipt)
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("%w: malformed push token", iap.ErrInvalidReceipt)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return err
+	}
+	if header.Alg != "RS256" {
+		return fmt.Errorf("%w: push token signed with %q", iap.ErrInvalidReceipt, header.Alg)
+	}
+
+	key, err := a.key(ctx, header.Kid)
+	if err != nil {
+		return err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("%w: malformed push token signature", iap.ErrInvalidReceipt)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return fmt.Errorf("%w: bad push token signature", iap.ErrInvalidReceipt)
+	}
+
+	var claims PushClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return err
+	}
+	now := time.Now()
+	switch {
+	case !slices.Contains(googleIssuers, claims.Issuer):
+		return fmt.Errorf("%w: push token issued by %q", iap.ErrInvalidReceipt, claims.Issuer)
+	case claims.Audience != a.audience:
+		return fmt.Errorf("%w: push token for audience %q", iap.ErrInvalidReceipt, claims.Audience)
+	case claims.Email != a.serviceAccount || !claims.EmailVerified:
+		return fmt.Errorf("%w: push token of %q", iap.ErrInvalidReceipt, claims.Email)
+	case !time.Unix(claims.ExpiresAt, 0).After(now.Add(-clockSkew)) || time.Unix(claims.IssuedAt, 0).After(now.Add(clockSkew)):
+		return fmt.Errorf("%w: push token expired", iap.ErrInvalidReceipt)
+	}
+	return nil
+}
+
+func decodeSegment(segment string, dst any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("%w: %v", iap.ErrInvalidReceipt, err)
+	}
+	if err := json.Unmarshal(data, dst); err != nil {
+		return fmt.Errorf("%w: %v", iap.ErrInvalidReceipt, err)
+	}
+	return nil
+}
+
+// key returns Google's key kid, fetching the keys again when they are old or kid is new to them
+func (a *PushAuthenticator) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key, ok := a.keys[kid]
+	age := time.Since(a.fetchedAt)
+	if ok && age < certsMaxAge {
+		return key, nil
+	}
+
+	if age >= certsMinRefresh {
+		keys, err := a.fetchKeys(ctx)
+		if err != nil {
+			return nil, err
+		}
+		a.keys, a.fetchedAt = keys, time.Now()
+	}
+
+	if key, ok = a.keys[kid]; !ok {
+		return nil, fmt.Errorf("%w: push token signed with unknown key %q", iap.ErrInvalidReceipt, kid)
+	}
+	return key, nil
+}
+
+func (a *PushAuthenticator) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.certsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("certs endpoint answered %s", resp.Status)
+	}
+
+	var jwks JWKS
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]*rsa.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err := errors.Join(errN, errE); err != nil || len(e) > 4 {
+			return nil, fmt.Errorf("malformed key %q in certs", jwk.Kid)
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	return keys, nil
+}
+
+// PublicJWK returns key as it is published in a JWKS
+func PublicJWK(kid string, key *rsa.PublicKey) JWK {
+	return JWK{
+		Kid: kid,
+		Kty: "RSA",
+		Alg: "RS256",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
@@ -0,0 +1,54 @@
+// Package iap validates purchases made through the mobile app stores and the notifications
+// the stores send about them.
+package iap
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+const (
+	StoreAppStore   = "app_store"
+	StoreGooglePlay = "google_play"
+)
+
+var (
+	// ErrInvalidReceipt is returned for a receipt or notification the store did not issue, or not for this app
+	ErrInvalidReceipt = errors.New("invalid store receipt")
+
+	// ErrNotSubscription is returned for a valid purchase that is not an auto-renewable subscription
+	ErrNotSubscription = errors.New("purchase is not a subscription")
+)
+
+// Purchase is the current state of a store subscription. OriginalTransactionId stays the same
+// across renewals and identifies the subscription in its store.
+type Purchase struct {
+	Store                 string
+	ProductId             string
+	OriginalTransactionId string
+	TransactionId         string
+	PurchasedAt           time.Time
+	ExpiresAt             time.Time
+	AutoRenew             bool
+	Revoked               bool
+}
+
+// Notification is a verified server-to-server notification, NotificationId repeats when the store retries it
+type Notification struct {
+	NotificationId string
+	Type           string
+	Purchase       *Purchase
+}
+
+// Verifier validates what an app store issued
+type Verifier interface {
+	// VerifyReceipt returns the subscription a receipt sent by the app proves, ErrInvalidReceipt
+	// when the store did not issue it
+	VerifyReceipt(ctx context.Context, receipt string) (*Purchase, error)
+
+	// VerifyNotification returns the notification in a server-to-server notification request, with the
+	// subscription's state after it. It returns ErrInvalidReceipt when the store did not send it.
+	VerifyNotification(ctx context.Context, header http.Header, body []byte) (*Notification, error)
+}
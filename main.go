@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"crypto/x509"
+	"database/sql"
 	"log"
 	"net/http"
 	"os"
@@ -12,7 +14,14 @@ import (
 	core_config "github.com/AlvinTendio/minder/config"
 	viper_cfg "github.com/AlvinTendio/minder/config/viper"
 	common_http "github.com/AlvinTendio/minder/delivery/http"
+	"github.com/AlvinTendio/minder/iap"
+	"github.com/AlvinTendio/minder/iap/appstore"
+	"github.com/AlvinTendio/minder/iap/googleplay"
 	"github.com/AlvinTendio/minder/mysql"
+	"github.com/AlvinTendio/minder/payment"
+	"github.com/AlvinTendio/minder/stream"
+	stream_memory "github.com/AlvinTendio/minder/stream/memory"
+	stream_mysql "github.com/AlvinTendio/minder/stream/mysql"
 	_ "github.com/go-sql-driver/mysql"
 
 	minder_delivery "github.com/AlvinTendio/minder/minder/delivery/http"
@@ -54,7 +63,7 @@ func main() {
 	}
 
 	dbConn, err := mysql.DB(dbConfig,
-		mysql.WithMysql(dbServerName, true, "Asia/Jakarta"),
+		mysql.WithMysql(dbServerName, true, "UTC"),
 		mysql.WithConnection(int(maxOpen), int(maxIdle),
 			time.Duration(maxLifetime)*time.Minute, 0))
 	if err != nil {
@@ -79,8 +88,34 @@ func main() {
 
 	// minder
 	minderRepo := minder_repo.NewMinderRepositoryImpl(dbConn)
-	minderUsecase := minder_usecase.NewMinderUsecaseImpl(minderRepo)
-	minder_delivery.NewMinderHandler(minderUsecase)
+	chatRepo := minder_repo.NewChatRepositoryImpl(dbConn)
+	pushRepo := minder_repo.NewPushRepositoryImpl(dbConn)
+	subscriptionRepo := minder_repo.NewSubscriptionRepositoryImpl(dbConn)
+	paymentRepo := minder_repo.NewPaymentRepositoryImpl(dbConn)
+	entitlementRepo := minder_repo.NewEntitlementRepositoryImpl(dbConn)
+	promoRepo := minder_repo.NewPromoRepositoryImpl(dbConn)
+	storeVerifiers := getStoreVerifiers(config)
+	paymentProvider := payment.NewGatewayProvider(config.GetString("payment.base.url"), config.GetString("payment.server.key"),
+		config.GetString("payment.webhook.secret"))
+	clock := minder_usecase.NewSystemClock()
+	minderRanker := minder_usecase.NewWeightedRanker(clock, config)
+	entitlements := minder_usecase.NewEntitlementService(minderRepo, subscriptionRepo, entitlementRepo, clock, config)
+	minderQuota := minder_usecase.NewQuotaService(minderRepo, entitlements, clock, config)
+	subscriptionWorker := minder_usecase.NewSubscriptionExpiryWorker(subscriptionRepo, clock, config)
+	go subscriptionWorker.Run(ctx)
+	desirabilityWorker := minder_usecase.NewDesirabilityWorker(minderRepo, config)
+	go desirabilityWorker.Run(ctx)
+	pushDispatcher := minder_usecase.NewPushDispatcher(minderRepo, pushRepo, getPushSender(config), clock, config)
+	go pushDispatcher.Run(ctx)
+	streamHub := stream.NewHub(getStreamPubSub(dbConn, config))
+	go func() {
+		if err := streamHub.Run(ctx); err != nil {
+			log.Println(err)
+		}
+	}()
+	minderUsecase := minder_usecase.NewMinderUsecaseImpl(minderRepo, chatRepo, pushRepo, subscriptionRepo, paymentRepo, paymentProvider,
+		entitlementRepo, promoRepo, storeVerifiers, minderRanker, minderQuota, entitlements, desirabilityWorker, streamHub, clock, config)
+	minder_delivery.NewMinderHandler(minderUsecase, config)
 
 	go func() {
 		if err := serveHTTP(ctx, addr, config); err != nil {
@@ -96,6 +131,87 @@ func main() {
 	log.Println("All server stopped!")
 }
 
+// getStreamPubSub picks the stream backend from stream.backend: "mysql" fans events out to every
+// instance sharing the database, anything else keeps them inside this instance
+func getStreamPubSub(db *sql.DB, config core_config.Config) stream.PubSub {
+	if config.GetString("stream.backend") != "mysql" {
+		return stream_memory.NewPubSub()
+	}
+
+	pollInterval := config.GetInt("stream.poll.interval.ms")
+	if pollInterval <= 0 {
+		pollInterval = 500
+	}
+	retention := config.GetInt("stream.retention.minutes")
+	if retention <= 0 {
+		retention = 10
+	}
+	return stream_mysql.NewPubSub(db, time.Duration(pollInterval)*time.Millisecond, time.Duration(retention)*time.Minute)
+}
+
+// getStoreVerifiers returns a verifier for every app store configured. The App Store needs
+// iap.appstore.bundle.id and the root certificate its JWS chain to at iap.appstore.root.path,
+// Apple Root CA - G3 in production. Google Play needs iap.googleplay.package.name and
+// authenticates with the service account key at iap.googleplay.service.account.path, or else
+// with iap.googleplay.access.token when iap.googleplay.base.url points at a stand-in.
+func getStoreVerifiers(config core_config.Config) map[string]iap.Verifier {
+	verifiers := make(map[string]iap.Verifier)
+
+	if path := config.GetString("iap.appstore.root.path"); path != "" {
+		cert, err := os.ReadFile(path)
+		if err != nil {
+			log.Println("Error reading App Store root certificate")
+			panic(err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(cert) {
+			root, err := x509.ParseCertificate(cert)
+			if err != nil {
+				log.Println("Error parsing App Store root certificate")
+				panic(err)
+			}
+			roots.AddCert(root)
+		}
+		verifiers[iap.StoreAppStore] = appstore.NewVerifier(roots, config.GetString("iap.appstore.bundle.id"))
+	}
+
+	if packageName := config.GetString("iap.googleplay.package.name"); packageName != "" {
+		var tokens googleplay.TokenSource = googleplay.StaticToken(config.GetString("iap.googleplay.access.token"))
+		if path := config.GetString("iap.googleplay.service.account.path"); path != "" {
+			key, err := os.ReadFile(path)
+			if err == nil {
+				tokens, err = googleplay.NewServiceAccountTokenSource(key)
+			}
+			if err != nil {
+				log.Println("Error loading Google Play service account")
+				panic(err)
+			}
+		}
+		baseURL := config.GetString("iap.googleplay.base.url")
+		if baseURL == "" {
+			baseURL = googleplay.DefaultBaseURL
+		}
+		certsURL := config.GetString("iap.googleplay.push.certs.url")
+		if certsURL == "" {
+			certsURL = googleplay.GoogleCertsURL
+		}
+		push := googleplay.NewPushAuthenticator(certsURL, config.GetString("iap.googleplay.push.audience"),
+			config.GetString("iap.googleplay.push.service.account"))
+		verifiers[iap.StoreGooglePlay] = googleplay.NewVerifier(baseURL, packageName, tokens, push)
+	}
+
+	return verifiers
+}
+
+// getPushSender picks the push stand-in from push.sender: "file" appends pushes to push.file.path,
+// anything else logs them
+func getPushSender(config core_config.Config) minder_usecase.PushSender {
+	if config.GetString("push.sender") == "file" {
+		return minder_usecase.NewFilePushSender(config.GetString("push.file.path"))
+	}
+	return minder_usecase.NewLogPushSender()
+}
+
 func serveHTTP(ctx context.Context, addr string,
 	config core_config.Config) error {
 	restHandler := common_http.NewRestHandlerService(config)
@@ -0,0 +1,10 @@
+CREATE TABLE Matches (
+    match_id INT AUTO_INCREMENT PRIMARY KEY,
+    user_one_id INT NOT NULL,
+    user_two_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_matches_pair (user_one_id, user_two_id),
+    KEY idx_matches_user_two (user_two_id),
+    FOREIGN KEY (user_one_id) REFERENCES Users(user_id),
+    FOREIGN KEY (user_two_id) REFERENCES Users(user_id)
+);
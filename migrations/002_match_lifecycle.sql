@@ -0,0 +1,16 @@
+ALTER TABLE Matches
+    ADD COLUMN unmatched_by INT NULL,
+    ADD COLUMN unmatched_at TIMESTAMP NULL,
+    ADD FOREIGN KEY (unmatched_by) REFERENCES Users(user_id);
+
+CREATE TABLE MatchEvents (
+    event_id INT AUTO_INCREMENT PRIMARY KEY,
+    match_id INT NOT NULL,
+    actor_user_id INT NOT NULL,
+    event_type ENUM('matched', 'unmatched') NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_match_events_match (match_id),
+    KEY idx_match_events_actor (actor_user_id, created_at),
+    FOREIGN KEY (match_id) REFERENCES Matches(match_id),
+    FOREIGN KEY (actor_user_id) REFERENCES Users(user_id)
+);
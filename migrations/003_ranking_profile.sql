@@ -0,0 +1,14 @@
+ALTER TABLE Users
+    ADD COLUMN bio TEXT NULL,
+    ADD COLUMN latitude DECIMAL(9,6) NULL,
+    ADD COLUMN longitude DECIMAL(9,6) NULL,
+    ADD COLUMN last_active_at TIMESTAMP NULL,
+    ADD KEY idx_users_last_active (last_active_at);
+
+CREATE TABLE UserInterests (
+    user_id INT NOT NULL,
+    interest VARCHAR(64) NOT NULL,
+    PRIMARY KEY (user_id, interest),
+    KEY idx_user_interests_interest (interest),
+    FOREIGN KEY (user_id) REFERENCES Users(user_id)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE Swipes
+    ADD COLUMN is_reserved BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD KEY idx_swipes_user_created (user_id, created_at);
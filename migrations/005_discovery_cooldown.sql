@@ -0,0 +1,5 @@
+ALTER TABLE Swipes
+    ADD COLUMN actioned_at TIMESTAMP NULL,
+    ADD KEY idx_swipes_exclusion (user_id, target_user_id, swipe_action, actioned_at, created_at);
+
+UPDATE Swipes SET actioned_at = created_at WHERE swipe_action IS NOT NULL;
@@ -0,0 +1,13 @@
+ALTER TABLE Swipes
+    ADD COLUMN requeued_at TIMESTAMP NULL;
+
+CREATE TABLE Rewinds (
+    rewind_id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    swipe_id INT NOT NULL,
+    previous_action ENUM('like', 'pass') NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_rewinds_user_created (user_id, created_at),
+    FOREIGN KEY (user_id) REFERENCES Users(user_id),
+    FOREIGN KEY (swipe_id) REFERENCES Swipes(swipe_id)
+);
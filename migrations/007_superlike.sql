@@ -0,0 +1,5 @@
+ALTER TABLE Swipes
+    MODIFY swipe_action ENUM('like', 'pass', 'superlike') NULL;
+
+ALTER TABLE Rewinds
+    MODIFY previous_action ENUM('like', 'pass', 'superlike') NOT NULL;
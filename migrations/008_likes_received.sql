@@ -0,0 +1,2 @@
+ALTER TABLE Swipes
+    ADD KEY idx_swipes_target_action (target_user_id, swipe_action, actioned_at);
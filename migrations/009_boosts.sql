@@ -0,0 +1,10 @@
+CREATE TABLE Boosts (
+    boost_id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    impressions INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_boosts_user_expires (user_id, expires_at),
+    FOREIGN KEY (user_id) REFERENCES Users(user_id)
+);
@@ -0,0 +1,7 @@
+CREATE TABLE UserScores (
+    user_id INT PRIMARY KEY,
+    desirability DOUBLE NOT NULL DEFAULT 1500,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    KEY idx_user_scores_desirability (desirability),
+    FOREIGN KEY (user_id) REFERENCES Users(user_id)
+);
@@ -0,0 +1,13 @@
+ALTER TABLE Users
+    ADD COLUMN trial_ends_at TIMESTAMP NULL;
+
+CREATE TABLE QuotaOverrides (
+    override_id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    action ENUM('view', 'like', 'superlike', 'rewind') NOT NULL,
+    daily_limit INT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_quota_overrides_user_action (user_id, action, expires_at),
+    FOREIGN KEY (user_id) REFERENCES Users(user_id)
+);
@@ -0,0 +1,3 @@
+-- IANA time zone the user's daily quotas reset in, NULL falls back to timezone.default
+ALTER TABLE Users
+    ADD COLUMN time_zone VARCHAR(64) NULL AFTER longitude;
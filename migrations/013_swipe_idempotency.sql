@@ -0,0 +1,4 @@
+-- clients may send their own id per swipe so retried requests are recognised
+ALTER TABLE Swipes
+    ADD COLUMN client_swipe_id VARCHAR(64) NULL,
+    ADD UNIQUE KEY uq_swipes_client (user_id, client_swipe_id);
@@ -0,0 +1,53 @@
+ALTER TABLE Users
+    ADD COLUMN suspended_until TIMESTAMP NULL,
+    ADD COLUMN banned_at TIMESTAMP NULL;
+
+-- a block hides both users from each other, whoever created it
+CREATE TABLE Blocks (
+    block_id INT AUTO_INCREMENT PRIMARY KEY,
+    blocker_id INT NOT NULL,
+    blocked_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_blocks_pair (blocker_id, blocked_id),
+    KEY idx_blocks_blocked (blocked_id),
+    FOREIGN KEY (blocker_id) REFERENCES Users(user_id),
+    FOREIGN KEY (blocked_id) REFERENCES Users(user_id)
+);
+
+CREATE TABLE Reports (
+    report_id INT AUTO_INCREMENT PRIMARY KEY,
+    reporter_id INT NOT NULL,
+    reported_id INT NOT NULL,
+    reason ENUM('spam', 'harassment', 'inappropriate_content', 'fake_profile', 'underage', 'other') NOT NULL,
+    details TEXT NULL,
+    status ENUM('open', 'resolved') NOT NULL DEFAULT 'open',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    resolved_at TIMESTAMP NULL,
+    KEY idx_reports_status (status, created_at),
+    KEY idx_reports_reported (reported_id),
+    FOREIGN KEY (reporter_id) REFERENCES Users(user_id),
+    FOREIGN KEY (reported_id) REFERENCES Users(user_id)
+);
+
+CREATE TABLE ModerationActions (
+    action_id INT AUTO_INCREMENT PRIMARY KEY,
+    report_id INT NOT NULL,
+    user_id INT NOT NULL,
+    action ENUM('dismiss', 'warn', 'suspend', 'ban') NOT NULL,
+    note TEXT NULL,
+    expires_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_moderation_actions_user (user_id, created_at),
+    FOREIGN KEY (report_id) REFERENCES Reports(report_id),
+    FOREIGN KEY (user_id) REFERENCES Users(user_id)
+);
+
+CREATE TABLE Notifications (
+    notification_id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    notification_type VARCHAR(32) NOT NULL,
+    message VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_notifications_user (user_id, created_at),
+    FOREIGN KEY (user_id) REFERENCES Users(user_id)
+);
@@ -0,0 +1,23 @@
+-- one conversation per match, created with its first message
+CREATE TABLE Conversations (
+    conversation_id INT AUTO_INCREMENT PRIMARY KEY,
+    match_id INT NOT NULL,
+    last_message_id INT NULL,
+    last_message_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_conversations_match (match_id),
+    KEY idx_conversations_last_message (last_message_at),
+    FOREIGN KEY (match_id) REFERENCES Matches(match_id)
+);
+
+CREATE TABLE Messages (
+    message_id INT AUTO_INCREMENT PRIMARY KEY,
+    conversation_id INT NOT NULL,
+    sender_id INT NOT NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_messages_conversation (conversation_id, message_id),
+    KEY idx_messages_sender_created (sender_id, created_at),
+    FOREIGN KEY (conversation_id) REFERENCES Conversations(conversation_id),
+    FOREIGN KEY (sender_id) REFERENCES Users(user_id)
+);
@@ -0,0 +1,9 @@
+-- only used with stream.backend=mysql, rows are deleted after stream.retention.minutes
+CREATE TABLE StreamEvents (
+    event_id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    event_type VARCHAR(32) NOT NULL,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_stream_events_created (created_at)
+);
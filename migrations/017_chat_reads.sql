@@ -0,0 +1,13 @@
+-- read marker of each participant, unread_count is kept up to date as messages are sent and read
+-- so unread counts never scan Messages
+CREATE TABLE ConversationReads (
+    conversation_id INT NOT NULL,
+    user_id INT NOT NULL,
+    last_read_message_id INT NOT NULL DEFAULT 0,
+    unread_count INT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (conversation_id, user_id),
+    KEY idx_conversation_reads_unread (user_id, unread_count),
+    FOREIGN KEY (conversation_id) REFERENCES Conversations(conversation_id),
+    FOREIGN KEY (user_id) REFERENCES Users(user_id)
+);
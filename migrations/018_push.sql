@@ -0,0 +1,38 @@
+-- a device token belongs to the user who registered it last
+CREATE TABLE DeviceTokens (
+    device_token_id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    platform ENUM('ios', 'android', 'web') NOT NULL,
+    token VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_device_tokens_token (token),
+    KEY idx_device_tokens_user (user_id),
+    FOREIGN KEY (user_id) REFERENCES Users(user_id)
+);
+
+-- users without a row get every push
+CREATE TABLE NotificationPreferences (
+    user_id INT PRIMARY KEY,
+    push_matches BOOLEAN NOT NULL DEFAULT TRUE,
+    push_messages BOOLEAN NOT NULL DEFAULT TRUE,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES Users(user_id)
+);
+
+-- written in the same transaction as the match or message it announces, delivered by the push dispatcher.
+-- Entries out of attempts end up dead for good.
+CREATE TABLE PushOutbox (
+    outbox_id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    push_type VARCHAR(32) NOT NULL,
+    payload TEXT NOT NULL,
+    status ENUM('pending', 'sent', 'skipped', 'dead') NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_error VARCHAR(255) NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    processed_at TIMESTAMP NULL,
+    KEY idx_push_outbox_due (status, next_attempt_at),
+    FOREIGN KEY (user_id) REFERENCES Users(user_id)
+);
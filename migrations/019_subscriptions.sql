@@ -0,0 +1,28 @@
+-- a subscription entitles its user to premium until current_period_end, or while a renewal is
+-- pending until current_period_end plus the configured grace period
+CREATE TABLE Subscriptions (
+    subscription_id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    plan ENUM('monthly', 'yearly') NOT NULL,
+    status ENUM('active', 'grace', 'expired') NOT NULL DEFAULT 'active',
+    auto_renew BOOLEAN NOT NULL DEFAULT TRUE,
+    started_at TIMESTAMP NOT NULL,
+    current_period_end TIMESTAMP NOT NULL,
+    grace_ends_at TIMESTAMP NULL,
+    canceled_at TIMESTAMP NULL,
+    expired_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    KEY idx_subscriptions_user (user_id, status),
+    KEY idx_subscriptions_status_period (status, current_period_end),
+    FOREIGN KEY (user_id) REFERENCES Users(user_id)
+);
+
+-- users upgraded before subscriptions existed keep premium for one more year without renewal
+INSERT INTO Subscriptions (user_id, plan, auto_renew, started_at, current_period_end)
+SELECT user_id, 'yearly', FALSE, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP + INTERVAL 1 YEAR
+FROM Users
+WHERE is_upgraded;
+
+ALTER TABLE Users
+    DROP COLUMN is_upgraded;
@@ -0,0 +1,30 @@
+-- one row per checkout, the subscription is only activated once the provider reports the payment
+CREATE TABLE Payments (
+    payment_id INT AUTO_INCREMENT PRIMARY KEY,
+    order_id VARCHAR(64) NOT NULL,
+    user_id INT NOT NULL,
+    plan ENUM('monthly', 'yearly') NOT NULL,
+    amount BIGINT NOT NULL,
+    currency CHAR(3) NOT NULL,
+    status ENUM('pending', 'paid', 'failed', 'refunded', 'charged_back') NOT NULL DEFAULT 'pending',
+    subscription_id INT NULL,
+    checkout_url VARCHAR(512) NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_payments_order (order_id),
+    KEY idx_payments_user (user_id, created_at),
+    FOREIGN KEY (user_id) REFERENCES Users(user_id),
+    FOREIGN KEY (subscription_id) REFERENCES Subscriptions(subscription_id)
+);
+
+-- every webhook event processed, a repeated event id is acknowledged without processing it again
+CREATE TABLE PaymentEvents (
+    event_id VARCHAR(64) PRIMARY KEY,
+    order_id VARCHAR(64) NOT NULL,
+    event_type VARCHAR(32) NOT NULL,
+    received_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE Subscriptions
+    MODIFY COLUMN status ENUM('active', 'grace', 'expired', 'revoked') NOT NULL DEFAULT 'active',
+    ADD COLUMN revoked_at TIMESTAMP NULL;
@@ -0,0 +1,12 @@
+-- a grant gives a user one of the configured entitlement packages until it expires,
+-- on top of whatever their plan or trial gives
+CREATE TABLE EntitlementGrants (
+    grant_id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    package VARCHAR(64) NOT NULL,
+    source ENUM('admin', 'promo') NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_entitlement_grants_user (user_id, expires_at),
+    FOREIGN KEY (user_id) REFERENCES Users(user_id)
+);
@@ -0,0 +1,23 @@
+-- a promo code grants an entitlement package for a number of days, every user may redeem it once
+-- until it expires or reaches its usage cap
+CREATE TABLE PromoCodes (
+    promo_code_id INT AUTO_INCREMENT PRIMARY KEY,
+    code VARCHAR(32) NOT NULL,
+    package VARCHAR(64) NOT NULL,
+    days INT NOT NULL,
+    max_redemptions INT NOT NULL,
+    redemption_count INT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_promo_codes_code (code)
+);
+
+CREATE TABLE PromoRedemptions (
+    redemption_id INT AUTO_INCREMENT PRIMARY KEY,
+    promo_code_id INT NOT NULL,
+    user_id INT NOT NULL,
+    redeemed_at TIMESTAMP NOT NULL,
+    UNIQUE KEY uq_promo_redemptions_user (promo_code_id, user_id),
+    FOREIGN KEY (promo_code_id) REFERENCES PromoCodes(promo_code_id),
+    FOREIGN KEY (user_id) REFERENCES Users(user_id)
+);
@@ -0,0 +1,15 @@
+-- subscriptions bought through an app store are kept in sync with the store, store_transaction_id
+-- is the id the store keeps across renewals: the original transaction id or the purchase token
+ALTER TABLE Subscriptions
+    ADD COLUMN store ENUM('web', 'app_store', 'google_play') NOT NULL DEFAULT 'web',
+    ADD COLUMN store_transaction_id VARCHAR(255) NULL,
+    ADD UNIQUE KEY uq_subscriptions_store_transaction (store, store_transaction_id);
+
+-- store notifications already applied, stores retry a notification until it is acknowledged
+CREATE TABLE StoreNotifications (
+    store ENUM('app_store', 'google_play') NOT NULL,
+    notification_id VARCHAR(64) NOT NULL,
+    notification_type VARCHAR(64) NOT NULL,
+    received_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (store, notification_id)
+);
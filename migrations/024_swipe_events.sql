@@ -0,0 +1,13 @@
+-- written in the same transaction as the swipe, consumed by the desirability worker.
+-- Processed events are kept, clearing processed_at replays them.
+CREATE TABLE SwipeEvents (
+    event_id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    target_user_id INT NOT NULL,
+    swipe_action ENUM('like', 'pass', 'superlike') NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    processed_at TIMESTAMP NULL,
+    KEY idx_swipe_events_pending (processed_at, event_id),
+    FOREIGN KEY (user_id) REFERENCES Users(user_id),
+    FOREIGN KEY (target_user_id) REFERENCES Users(user_id)
+);
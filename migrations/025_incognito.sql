@@ -0,0 +1,3 @@
+-- users in incognito are only shown in discovery to users they liked, while entitled to incognito
+ALTER TABLE Users
+    ADD COLUMN incognito BOOLEAN NOT NULL DEFAULT FALSE AFTER time_zone;
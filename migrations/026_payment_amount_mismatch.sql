@@ -0,0 +1,3 @@
+-- a payment reported with another amount than the order's is kept for review instead of activating the plan
+ALTER TABLE Payments
+    MODIFY COLUMN status ENUM('pending', 'paid', 'failed', 'refunded', 'charged_back', 'amount_mismatch') NOT NULL DEFAULT 'pending';
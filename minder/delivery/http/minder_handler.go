@@ -2,32 +2,85 @@ package http
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/AlvinTendio/minder/auth"
 	"github.com/AlvinTendio/minder/common"
+	core_config "github.com/AlvinTendio/minder/config"
 	common_http "github.com/AlvinTendio/minder/delivery/http"
+	"github.com/AlvinTendio/minder/iap"
 	minder_model "github.com/AlvinTendio/minder/minder/model"
 	"github.com/AlvinTendio/minder/minder/usecase"
 	validatorfmt "github.com/AlvinTendio/minder/validator-fmt"
 	validator "github.com/go-playground/validator/v10"
+	"github.com/gorilla/schema"
+)
+
+const (
+	streamHeartbeatSeconds        = "stream.heartbeat.seconds"
+	defaultStreamHeartbeatSeconds = 25
 )
 
 type MinderHandler struct {
 	MinderUsecase usecase.MinderUsecase
+	Config        core_config.Config
 }
 
-func NewMinderHandler(minderUsecase usecase.MinderUsecase) {
+func NewMinderHandler(minderUsecase usecase.MinderUsecase, config core_config.Config) {
 	h := &MinderHandler{
 		MinderUsecase: minderUsecase,
+		Config:        config,
 	}
 
 	common_http.Route(http.MethodPost, "/register", h.Register, "Register")
 	common_http.Route(http.MethodPost, "/login", h.Login, "Login")
 	common_http.Route(http.MethodPut, "/upgrade-account/([0-9]+)", h.UpgradeAccount, "Upgrade Account")
+	common_http.Route(http.MethodGet, "/subscription", h.GetSubscription, "GetSubscription")
+	common_http.Route(http.MethodPost, "/subscription/cancel", h.CancelSubscription, "CancelSubscription")
+	common_http.Route(http.MethodPost, "/payments/webhook", h.PaymentWebhook, "PaymentWebhook")
+	common_http.Route(http.MethodPost, "/iap/receipts", h.ValidateReceipt, "ValidateReceipt")
+	common_http.Route(http.MethodPost, "/iap/notifications/app-store", h.AppStoreNotification, "AppStoreNotification")
+	common_http.Route(http.MethodPost, "/iap/notifications/google-play", h.GooglePlayNotification, "GooglePlayNotification")
+	common_http.Route(http.MethodPut, "/time-zone/([0-9]+)", h.UpdateTimeZone, "UpdateTimeZone")
+	common_http.Route(http.MethodPut, "/incognito/([0-9]+)", h.UpdateIncognito, "UpdateIncognito")
 	common_http.Route(http.MethodGet, "/get-target-user/([0-9]+)", h.GetTargetUser, "GetTargetUser")
+	common_http.Route(http.MethodGet, "/deck", h.GetDeck, "GetDeck")
 	common_http.Route(http.MethodPut, "/swipe", h.Swipe, "Swipe")
+	common_http.Route(http.MethodPost, "/swipe/rewind", h.Rewind, "Rewind")
+	common_http.Route(http.MethodGet, "/matches", h.GetMatches, "GetMatches")
+	common_http.Route(http.MethodDelete, "/matches/([0-9]+)", h.Unmatch, "Unmatch")
+	common_http.Route(http.MethodGet, "/likes/received", h.GetLikesReceived, "GetLikesReceived")
+	common_http.Route(http.MethodPost, "/likes/received/([0-9]+)/like", h.LikeBack, "LikeBack")
+	common_http.Route(http.MethodPost, "/boost", h.ActivateBoost, "ActivateBoost")
+	common_http.Route(http.MethodGet, "/boost", h.GetBoost, "GetBoost")
+	common_http.Route(http.MethodGet, "/quota", h.GetQuota, "GetQuota")
+	common_http.Route(http.MethodGet, "/entitlements", h.GetEntitlements, "GetEntitlements")
+	common_http.Route(http.MethodPost, "/promo/redeem", h.RedeemPromo, "RedeemPromo")
+	common_http.Route(http.MethodPost, "/users/([0-9]+)/block", h.BlockUser, "BlockUser")
+	common_http.Route(http.MethodPost, "/users/([0-9]+)/report", h.ReportUser, "ReportUser")
+	common_http.Route(http.MethodGet, "/notifications", h.GetNotifications, "GetNotifications")
+	common_http.Route(http.MethodGet, "/conversations", common_http.Authenticated(h.GetConversations), "GetConversations")
+	common_http.Route(http.MethodGet, "/conversations/unread", common_http.Authenticated(h.GetUnreadCounts), "GetUnreadCounts")
+	common_http.Route(http.MethodPost, "/matches/([0-9]+)/messages", common_http.Authenticated(h.SendMessage), "SendMessage")
+	common_http.Route(http.MethodGet, "/matches/([0-9]+)/messages", common_http.Authenticated(h.GetMessages), "GetMessages")
+	common_http.Route(http.MethodPut, "/matches/([0-9]+)/read", common_http.Authenticated(h.MarkRead), "MarkRead")
+	common_http.Route(http.MethodPost, "/matches/([0-9]+)/typing", common_http.Authenticated(h.Typing), "Typing")
+	common_http.Route(http.MethodPost, "/devices", h.RegisterDevice, "RegisterDevice")
+	common_http.Route(http.MethodDelete, "/devices", h.RemoveDevice, "RemoveDevice")
+	common_http.Route(http.MethodGet, "/notification-preferences", h.GetNotificationPreferences, "GetNotificationPreferences")
+	common_http.Route(http.MethodPut, "/notification-preferences", h.UpdateNotificationPreferences, "UpdateNotificationPreferences")
+	common_http.Route(http.MethodGet, "/stream", common_http.Authenticated(h.Stream), "Stream")
+	common_http.Route(http.MethodPost, "/admin/quota-overrides", common_http.AdminOnly(config, h.CreateQuotaOverride), "CreateQuotaOverride")
+	common_http.Route(http.MethodPost, "/admin/entitlement-grants", common_http.AdminOnly(config, h.CreateEntitlementGrant), "CreateEntitlementGrant")
+	common_http.Route(http.MethodPost, "/admin/promo-codes", common_http.AdminOnly(config, h.CreatePromoCode), "CreatePromoCode")
+	common_http.Route(http.MethodGet, "/admin/reports", common_http.AdminOnly(config, h.GetReports), "GetReports")
+	common_http.Route(http.MethodPost, "/admin/reports/([0-9]+)/resolve", common_http.AdminOnly(config, h.ResolveReport), "ResolveReport")
+	common_http.Route(http.MethodPost, "/admin/payments/([0-9]+)/refund", common_http.AdminOnly(config, h.RefundPayment), "RefundPayment")
 }
 
 func (h *MinderHandler) Register(rw http.ResponseWriter, req *http.Request) {
@@ -96,17 +149,20 @@ func (h *MinderHandler) UpgradeAccount(rw http.ResponseWriter, req *http.Request
 
 	id := getParamUint64(rw, req)
 
-	if id == 0 {
-		resp := &common.HTTPResponse{
-			HTTPStatus:      http.StatusBadRequest,
-			ResponseCode:    common.StatusBadRequestErrorResponseCode,
-			ResponseMessage: common.StatusBadRequestErrorResponseMessage,
-		}
-		common_http.ResponseWrite(req, rw, resp, resp.HTTPStatus)
+	upgradeReq := &minder_model.UpgradeReq{}
+	err := json.NewDecoder(req.Body).Decode(upgradeReq)
+	if err != nil {
+		log.Println("Error in PUT parameters : ", err)
+	}
+
+	validate := validator.New()
+	err = validate.Struct(upgradeReq)
+	if err != nil || id == 0 {
+		writeBadRequest(rw, req)
 		return
 	}
 
-	result, err := h.MinderUsecase.UpgradeAccount(ctx, id)
+	result, err := h.MinderUsecase.UpgradeAccount(ctx, id, upgradeReq)
 	if err != nil {
 		log.Println(ctx, "[delivery:http:handler] : Exception Upgrade Account", err)
 		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
@@ -115,6 +171,110 @@ func (h *MinderHandler) UpgradeAccount(rw http.ResponseWriter, req *http.Request
 	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
 }
 
+func (h *MinderHandler) GetSubscription(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	subscriptionReq := &minder_model.SubscriptionReq{}
+	err := decodeQuery(req, subscriptionReq)
+	if err != nil {
+		log.Println("Error in GET parameters : ", err)
+	}
+
+	validate := validator.New()
+	err = validate.Struct(subscriptionReq)
+	if err != nil {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.GetSubscription(ctx, subscriptionReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Get Subscription", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) CancelSubscription(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	cancelReq := &minder_model.CancelSubscriptionReq{}
+	err := json.NewDecoder(req.Body).Decode(cancelReq)
+	if err != nil {
+		log.Println("Error in POST parameters : ", err)
+	}
+
+	validate := validator.New()
+	err = validate.Struct(cancelReq)
+	if err != nil {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.CancelSubscription(ctx, cancelReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Cancel Subscription", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) UpdateTimeZone(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	id := getParamUint64(rw, req)
+
+	timeZoneReq := &minder_model.TimeZoneReq{}
+	err := json.NewDecoder(req.Body).Decode(timeZoneReq)
+	if err != nil {
+		log.Println("Error in PUT parameters : ", err)
+	}
+
+	validate := validator.New()
+	err = validate.Struct(timeZoneReq)
+	if id == 0 || err != nil {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.UpdateTimeZone(ctx, id, timeZoneReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Update Time Zone", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) UpdateIncognito(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	id := getParamUint64(rw, req)
+
+	incognitoReq := &minder_model.IncognitoReq{}
+	err := json.NewDecoder(req.Body).Decode(incognitoReq)
+	if err != nil {
+		log.Println("Error in PUT parameters : ", err)
+	}
+
+	validate := validator.New()
+	err = validate.Struct(incognitoReq)
+	if id == 0 || err != nil {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.UpdateIncognito(ctx, id, incognitoReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Update Incognito", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
 func (h *MinderHandler) GetTargetUser(rw http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 
@@ -139,6 +299,31 @@ func (h *MinderHandler) GetTargetUser(rw http.ResponseWriter, req *http.Request)
 	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
 }
 
+func (h *MinderHandler) GetDeck(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	deckReq := &minder_model.DeckReq{}
+	err := decodeQuery(req, deckReq)
+	if err != nil {
+		log.Println("Error in GET parameters : ", err)
+	}
+
+	validate := validator.New()
+	err = validate.Struct(deckReq)
+	if err != nil {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.GetDeck(ctx, deckReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Get Deck", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
 func (h *MinderHandler) Swipe(rw http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 
@@ -162,13 +347,907 @@ func (h *MinderHandler) Swipe(rw http.ResponseWriter, req *http.Request) {
 
 	result, err := h.MinderUsecase.Swipe(ctx, swipeReq)
 	if err != nil {
-		log.Println(ctx, "[delivery:http:handler] : Exception Register", err)
+		log.Println(ctx, "[delivery:http:handler] : Exception Swipe", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) Rewind(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	rewindReq := &minder_model.RewindReq{}
+	err := json.NewDecoder(req.Body).Decode(rewindReq)
+	if err != nil {
+		log.Println("Error in POST parameters : ", err)
+	}
+
+	validate := validator.New()
+	err = validate.Struct(rewindReq)
+	if err != nil {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.Rewind(ctx, rewindReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Rewind", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) GetMatches(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	matchListReq := &minder_model.MatchListReq{}
+	err := decodeQuery(req, matchListReq)
+	if err != nil {
+		log.Println("Error in GET parameters : ", err)
+	}
+
+	validate := validator.New()
+	err = validate.Struct(matchListReq)
+	if err != nil {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.GetMatches(ctx, matchListReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Get Matches", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) Unmatch(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	id := getParamUint64(rw, req)
+
+	unmatchReq := &minder_model.UnmatchReq{}
+	err := decodeQuery(req, unmatchReq)
+	if err != nil {
+		log.Println("Error in DELETE parameters : ", err)
+	}
+
+	validate := validator.New()
+	err = validate.Struct(unmatchReq)
+	if err != nil || id == 0 {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.Unmatch(ctx, id, unmatchReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Unmatch", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) GetLikesReceived(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	likesReq := &minder_model.LikesReceivedReq{}
+	err := decodeQuery(req, likesReq)
+	if err != nil {
+		log.Println("Error in GET parameters : ", err)
+	}
+
+	validate := validator.New()
+	err = validate.Struct(likesReq)
+	if err != nil {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.GetLikesReceived(ctx, likesReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Get Likes Received", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) LikeBack(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	likerId := getParamUint64(rw, req)
+
+	likeBackReq := &minder_model.LikeBackReq{}
+	err := json.NewDecoder(req.Body).Decode(likeBackReq)
+	if err != nil {
+		log.Println("Error in POST parameters : ", err)
+	}
+
+	validate := validator.New()
+	err = validate.Struct(likeBackReq)
+	if err != nil || likerId == 0 {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.LikeBack(ctx, likerId, likeBackReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Like Back", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) ActivateBoost(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	boostReq := &minder_model.BoostReq{}
+	err := json.NewDecoder(req.Body).Decode(boostReq)
+	if err != nil {
+		log.Println("Error in POST parameters : ", err)
+	}
+
+	validate := validator.New()
+	err = validate.Struct(boostReq)
+	if err != nil {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.ActivateBoost(ctx, boostReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Activate Boost", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) GetBoost(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	boostStatusReq := &minder_model.BoostStatusReq{}
+	err := decodeQuery(req, boostStatusReq)
+	if err != nil {
+		log.Println("Error in GET parameters : ", err)
+	}
+
+	validate := validator.New()
+	err = validate.Struct(boostStatusReq)
+	if err != nil {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.GetBoost(ctx, boostStatusReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Get Boost", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) GetQuota(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	quotaReq := &minder_model.QuotaReq{}
+	err := decodeQuery(req, quotaReq)
+	if err != nil {
+		log.Println("Error in GET parameters : ", err)
+	}
+
+	validate := validator.New()
+	err = validate.Struct(quotaReq)
+	if err != nil {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.GetQuota(ctx, quotaReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Get Quota", err)
 		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
 		return
 	}
 	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
 }
 
+func (h *MinderHandler) CreateQuotaOverride(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	overrideReq := &minder_model.QuotaOverrideReq{}
+	err := json.NewDecoder(req.Body).Decode(overrideReq)
+	if err != nil {
+		log.Println("Error in POST parameters : ", err)
+	}
+
+	validate := validator.New()
+	err = validate.Struct(overrideReq)
+	if err != nil {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.CreateQuotaOverride(ctx, overrideReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Create Quota Override", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) GetEntitlements(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	entitlementsReq := &minder_model.EntitlementsReq{}
+	err := decodeQuery(req, entitlementsReq)
+	if err != nil {
+		log.Println("Error in GET parameters : ", err)
+	}
+
+	validate := validator.New()
+	err = validate.Struct(entitlementsReq)
+	if err != nil {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.GetEntitlements(ctx, entitlementsReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Get Entitlements", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) CreateEntitlementGrant(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	grantReq := &minder_model.EntitlementGrantReq{}
+	err := json.NewDecoder(req.Body).Decode(grantReq)
+	if err != nil {
+		log.Println("Error in POST parameters : ", err)
+	}
+
+	validate := validator.New()
+	err = validate.Struct(grantReq)
+	if err != nil {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.CreateEntitlementGrant(ctx, grantReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Create Entitlement Grant", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) CreatePromoCode(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	promoReq := &minder_model.PromoCodeReq{}
+	err := json.NewDecoder(req.Body).Decode(promoReq)
+	if err != nil {
+		log.Println("Error in POST parameters : ", err)
+	}
+
+	validate := validator.New()
+	err = validate.Struct(promoReq)
+	if err != nil {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.CreatePromoCode(ctx, promoReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Create Promo Code", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) RedeemPromo(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	redeemReq := &minder_model.RedeemPromoReq{}
+	err := json.NewDecoder(req.Body).Decode(redeemReq)
+	if err != nil {
+		log.Println("Error in POST parameters : ", err)
+	}
+
+	validate := validator.New()
+	err = validate.Struct(redeemReq)
+	if err != nil {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.RedeemPromo(ctx, redeemReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Redeem Promo", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) BlockUser(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	id := getParamUint64(rw, req)
+
+	blockReq := &minder_model.BlockReq{}
+	err := json.NewDecoder(req.Body).Decode(blockReq)
+	if err != nil {
+		log.Println("Error in POST parameters : ", err)
+	}
+
+	validate := validator.New()
+	err = validate.Struct(blockReq)
+	if err != nil || id == 0 {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.BlockUser(ctx, id, blockReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Block User", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) ReportUser(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	id := getParamUint64(rw, req)
+
+	reportReq := &minder_model.ReportReq{}
+	err := json.NewDecoder(req.Body).Decode(reportReq)
+	if err != nil {
+		log.Println("Error in POST parameters : ", err)
+	}
+
+	validate := validator.New()
+	err = validate.Struct(reportReq)
+	if err != nil || id == 0 {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.ReportUser(ctx, id, reportReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Report User", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) GetNotifications(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	notificationListReq := &minder_model.NotificationListReq{}
+	err := decodeQuery(req, notificationListReq)
+	if err != nil {
+		log.Println("Error in GET parameters : ", err)
+	}
+
+	validate := validator.New()
+	err = validate.Struct(notificationListReq)
+	if err != nil {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.GetNotifications(ctx, notificationListReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Get Notifications", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) GetReports(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	reportListReq := &minder_model.ReportListReq{}
+	err := decodeQuery(req, reportListReq)
+	if err != nil {
+		log.Println("Error in GET parameters : ", err)
+	}
+
+	validate := validator.New()
+	err = validate.Struct(reportListReq)
+	if err != nil {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.GetReports(ctx, reportListReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Get Reports", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) ResolveReport(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	id := getParamUint64(rw, req)
+
+	resolveReq := &minder_model.ResolveReportReq{}
+	err := json.NewDecoder(req.Body).Decode(resolveReq)
+	if err != nil {
+		log.Println("Error in POST parameters : ", err)
+	}
+
+	validate := validator.New()
+	err = validate.Struct(resolveReq)
+	if err != nil || id == 0 {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.ResolveReport(ctx, id, resolveReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Resolve Report", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) SendMessage(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	userInfo, _ := auth.UserInfoFromContext(ctx)
+
+	id := getParamUint64(rw, req)
+
+	messageReq := &minder_model.SendMessageReq{}
+	err := json.NewDecoder(req.Body).Decode(messageReq)
+	if err != nil {
+		log.Println("Error in POST parameters : ", err)
+	}
+	messageReq.UserId = int64(userInfo.ID)
+
+	validate := validator.New()
+	err = validate.Struct(messageReq)
+	if err != nil || id == 0 {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.SendMessage(ctx, id, messageReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Send Message", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) GetMessages(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	userInfo, _ := auth.UserInfoFromContext(ctx)
+
+	id := getParamUint64(rw, req)
+
+	historyReq := &minder_model.MessageHistoryReq{}
+	err := decodeQuery(req, historyReq)
+	if err != nil {
+		log.Println("Error in GET parameters : ", err)
+	}
+	historyReq.UserId = int64(userInfo.ID)
+
+	validate := validator.New()
+	err = validate.Struct(historyReq)
+	if err != nil || id == 0 {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.GetMessages(ctx, id, historyReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Get Messages", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) GetConversations(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	userInfo, _ := auth.UserInfoFromContext(ctx)
+
+	conversationListReq := &minder_model.ConversationListReq{}
+	err := decodeQuery(req, conversationListReq)
+	if err != nil {
+		log.Println("Error in GET parameters : ", err)
+	}
+	conversationListReq.UserId = int64(userInfo.ID)
+
+	validate := validator.New()
+	err = validate.Struct(conversationListReq)
+	if err != nil {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.GetConversations(ctx, conversationListReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Get Conversations", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) MarkRead(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	userInfo, _ := auth.UserInfoFromContext(ctx)
+
+	id := getParamUint64(rw, req)
+
+	readReq := &minder_model.ReadReq{}
+	err := json.NewDecoder(req.Body).Decode(readReq)
+	if err != nil {
+		log.Println("Error in POST parameters : ", err)
+	}
+	readReq.UserId = int64(userInfo.ID)
+
+	validate := validator.New()
+	err = validate.Struct(readReq)
+	if err != nil || id == 0 {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.MarkRead(ctx, id, readReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Mark Read", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) Typing(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	userInfo, _ := auth.UserInfoFromContext(ctx)
+
+	id := getParamUint64(rw, req)
+
+	typingReq := &minder_model.TypingReq{}
+	err := json.NewDecoder(req.Body).Decode(typingReq)
+	if err != nil {
+		log.Println("Error in POST parameters : ", err)
+	}
+	typingReq.UserId = int64(userInfo.ID)
+
+	validate := validator.New()
+	err = validate.Struct(typingReq)
+	if err != nil || id == 0 {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.Typing(ctx, id, typingReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Typing", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) GetUnreadCounts(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	userInfo, _ := auth.UserInfoFromContext(ctx)
+
+	unreadReq := &minder_model.UnreadReq{}
+	err := decodeQuery(req, unreadReq)
+	if err != nil {
+		log.Println("Error in GET parameters : ", err)
+	}
+	unreadReq.UserId = int64(userInfo.ID)
+
+	validate := validator.New()
+	err = validate.Struct(unreadReq)
+	if err != nil {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.GetUnreadCounts(ctx, unreadReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Get Unread Counts", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) RegisterDevice(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	deviceReq := &minder_model.DeviceTokenReq{}
+	err := json.NewDecoder(req.Body).Decode(deviceReq)
+	if err != nil {
+		log.Println("Error in POST parameters : ", err)
+	}
+
+	validate := validator.New()
+	err = validate.Struct(deviceReq)
+	if err != nil {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.RegisterDevice(ctx, deviceReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Register Device", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) RemoveDevice(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	deviceReq := &minder_model.RemoveDeviceTokenReq{}
+	err := decodeQuery(req, deviceReq)
+	if err != nil {
+		log.Println("Error in DELETE parameters : ", err)
+	}
+
+	validate := validator.New()
+	err = validate.Struct(deviceReq)
+	if err != nil {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.RemoveDevice(ctx, deviceReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Remove Device", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) GetNotificationPreferences(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	preferencesReq := &minder_model.NotificationPreferencesReq{}
+	err := decodeQuery(req, preferencesReq)
+	if err != nil {
+		log.Println("Error in GET parameters : ", err)
+	}
+
+	validate := validator.New()
+	err = validate.Struct(preferencesReq)
+	if err != nil {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.GetNotificationPreferences(ctx, preferencesReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Get Notification Preferences", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) UpdateNotificationPreferences(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	preferencesReq := &minder_model.UpdateNotificationPreferencesReq{}
+	err := json.NewDecoder(req.Body).Decode(preferencesReq)
+	if err != nil {
+		log.Println("Error in PUT parameters : ", err)
+	}
+
+	validate := validator.New()
+	err = validate.Struct(preferencesReq)
+	if err != nil {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.UpdateNotificationPreferences(ctx, preferencesReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Update Notification Preferences", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+// PaymentWebhook passes the raw body on untouched, the payment provider signs the exact bytes it sent
+func (h *MinderHandler) PaymentWebhook(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		log.Println("Error in POST parameters : ", err)
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.HandlePaymentWebhook(ctx, req.Header, body)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Payment Webhook", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) RefundPayment(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	id := getParamUint64(rw, req)
+	if id == 0 {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.RefundPayment(ctx, id)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Refund Payment", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) ValidateReceipt(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	receiptReq := &minder_model.ReceiptReq{}
+	err := json.NewDecoder(req.Body).Decode(receiptReq)
+	if err != nil {
+		log.Println("Error in POST parameters : ", err)
+	}
+
+	validate := validator.New()
+	err = validate.Struct(receiptReq)
+	if err != nil {
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.ValidateReceipt(ctx, receiptReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Validate Receipt", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+func (h *MinderHandler) AppStoreNotification(rw http.ResponseWriter, req *http.Request) {
+	h.storeNotification(rw, req, iap.StoreAppStore)
+}
+
+func (h *MinderHandler) GooglePlayNotification(rw http.ResponseWriter, req *http.Request) {
+	h.storeNotification(rw, req, iap.StoreGooglePlay)
+}
+
+// storeNotification passes the raw request on, its signature or push token is verified against the store
+func (h *MinderHandler) storeNotification(rw http.ResponseWriter, req *http.Request, store string) {
+	ctx := req.Context()
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		log.Println("Error in POST parameters : ", err)
+		writeBadRequest(rw, req)
+		return
+	}
+
+	result, err := h.MinderUsecase.HandleStoreNotification(ctx, store, req.Header, body)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Store Notification", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+}
+
+// Stream keeps the request open as a Server-Sent Events stream of the authenticated user's events,
+// with a comment line every stream.heartbeat.seconds so proxies do not close an idle stream
+func (h *MinderHandler) Stream(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	userInfo, _ := auth.UserInfoFromContext(ctx)
+	streamReq := &minder_model.StreamReq{UserId: int64(userInfo.ID)}
+
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		log.Println(ctx, "[delivery:http:handler] : Exception Stream, response writer cannot flush")
+		common_http.ResponseWrite(req, rw, nil, http.StatusInternalServerError)
+		return
+	}
+
+	events, result, err := h.MinderUsecase.Stream(ctx, streamReq)
+	if err != nil {
+		log.Println(ctx, "[delivery:http:handler] : Exception Stream", err)
+		common_http.ResponseWrite(req, rw, result, http.StatusInternalServerError)
+		return
+	}
+	if result != nil {
+		common_http.ResponseWrite(req, rw, result, result.HTTPStatus)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.Header().Set("Connection", "keep-alive")
+	rw.Header().Set("X-Accel-Buffering", "no")
+	rw.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	interval := h.Config.GetInt(streamHeartbeatSeconds)
+	if interval <= 0 {
+		interval = defaultStreamHeartbeatSeconds
+	}
+	heartbeat := time.NewTicker(time.Duration(interval) * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			_, err = fmt.Fprintf(rw, "event: %s\ndata: %s\n\n", event.Type, event.Data)
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(rw, ": heartbeat\n\n")
+		}
+		if err != nil {
+			log.Println(ctx, "[delivery:http:handler] : Exception Stream write", err)
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func decodeQuery(req *http.Request, dst interface{}) error {
+	decoder := schema.NewDecoder()
+	decoder.IgnoreUnknownKeys(true)
+	return decoder.Decode(dst, req.URL.Query())
+}
+
+func writeBadRequest(rw http.ResponseWriter, req *http.Request) {
+	resp := &common.HTTPResponse{
+		HTTPStatus:      http.StatusBadRequest,
+		ResponseCode:    common.StatusBadRequestErrorResponseCode,
+		ResponseMessage: common.StatusBadRequestErrorResponseMessage,
+	}
+	common_http.ResponseWrite(req, rw, resp, resp.HTTPStatus)
+}
+
 func getParamUint64(rw http.ResponseWriter, req *http.Request) uint64 {
 	id, err := strconv.ParseUint(common_http.Param(req, 0), 0, 64)
 	if err != nil {
@@ -1,14 +1,106 @@
 package model
 
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	SwipeActionLike      = "like"
+	SwipeActionPass      = "pass"
+	SwipeActionSuperLike = "superlike"
+
+	TierFree    = "free"
+	TierPremium = "premium"
+	TierTrial   = "trial"
+
+	QuotaActionView      = "view"
+	QuotaActionLike      = "like"
+	QuotaActionSuperLike = "superlike"
+	QuotaActionRewind    = "rewind"
+
+	// QuotaUnlimited is the quota limit for actions without a daily cap
+	QuotaUnlimited = -1
+
+	MatchEventMatched   = "matched"
+	MatchEventUnmatched = "unmatched"
+
+	ReportStatusOpen     = "open"
+	ReportStatusResolved = "resolved"
+
+	ModerationDismiss = "dismiss"
+	ModerationWarn    = "warn"
+	ModerationSuspend = "suspend"
+	ModerationBan     = "ban"
+
+	NotificationReportResolved = "report_resolved"
+	NotificationModeration     = "moderation"
+
+	StreamEventMatch        = "match"
+	StreamEventMessage      = "message"
+	StreamEventLikeReceived = "like_received"
+	StreamEventQuotaReset   = "quota_reset"
+	StreamEventTyping       = "typing"
+	StreamEventRead         = "read"
+
+	PushTypeMatch   = "match"
+	PushTypeMessage = "message"
+
+	PushStatusPending = "pending"
+	PushStatusSent    = "sent"
+	PushStatusSkipped = "skipped"
+	PushStatusDead    = "dead"
+
+	SubscriptionPlanMonthly = "monthly"
+	SubscriptionPlanYearly  = "yearly"
+
+	SubscriptionStatusActive  = "active"
+	SubscriptionStatusGrace   = "grace"
+	SubscriptionStatusExpired = "expired"
+	SubscriptionStatusRevoked = "revoked"
+
+	// SubscriptionStoreWeb is the store of subscriptions paid through the payment provider
+	SubscriptionStoreWeb = "web"
+
+	PaymentStatusPending     = "pending"
+	PaymentStatusPaid        = "paid"
+	PaymentStatusFailed      = "failed"
+	PaymentStatusRefunded    = "refunded"
+	PaymentStatusChargedBack = "charged_back"
+	// PaymentStatusAmountMismatch is a payment the provider reported with another amount than ordered
+	PaymentStatusAmountMismatch = "amount_mismatch"
+
+	// the quota actions double as features limited per day, these are features without a limit
+	FeatureSeeLikes  = "see_likes"
+	FeatureBoost     = "boost"
+	FeatureIncognito = "incognito"
+
+	GrantSourceAdmin = "admin"
+	GrantSourcePromo = "promo"
+)
+
 type RegisterReq struct {
-	Username       string `json:"username"  schema:"username" validate:"required"`
-	Email          string `json:"email"  schema:"email" validate:"required"`
-	PhoneNumber    string `json:"phoneNumber" schema:"phoneNumber" validate:"required"`
-	Password       string `json:"password"  schema:"password" validate:"required"`
-	FullName       string `json:"fullName"  schema:"fullName" validate:"required"`
-	Gender         string `json:"gender"  schema:"gender" validate:"required,oneof=male female"`
-	DateOfBirth    string `json:"dateOfBirth"  schema:"dateOfBirth" validate:"required,omitempty,dateformat"`
-	ProfilePicture string `json:"profilePicture"  schema:"profilePicture" validate:"required"`
+	Username       string   `json:"username"  schema:"username" validate:"required"`
+	Email          string   `json:"email"  schema:"email" validate:"required"`
+	PhoneNumber    string   `json:"phoneNumber" schema:"phoneNumber" validate:"required"`
+	Password       string   `json:"password"  schema:"password" validate:"required"`
+	FullName       string   `json:"fullName"  schema:"fullName" validate:"required"`
+	Gender         string   `json:"gender"  schema:"gender" validate:"required,oneof=male female"`
+	DateOfBirth    string   `json:"dateOfBirth"  schema:"dateOfBirth" validate:"required,omitempty,dateformat"`
+	ProfilePicture string   `json:"profilePicture"  schema:"profilePicture" validate:"required"`
+	Bio            string   `json:"bio" schema:"bio" validate:"omitempty,max=500"`
+	Latitude       *float64 `json:"latitude" schema:"latitude" validate:"omitempty,min=-90,max=90"`
+	Longitude      *float64 `json:"longitude" schema:"longitude" validate:"omitempty,min=-180,max=180"`
+	Interests      []string `json:"interests" schema:"interests" validate:"omitempty,max=20,dive,required,max=64,excludesall=0x2C"`
+	TimeZone       string   `json:"timeZone" schema:"timeZone" validate:"omitempty,timezone"`
+}
+
+type TimeZoneReq struct {
+	TimeZone string `json:"timeZone" schema:"timeZone" validate:"required,timezone"`
+}
+
+type IncognitoReq struct {
+	Enabled *bool `json:"enabled" schema:"enabled" validate:"required"`
 }
 
 type LoginReq struct {
@@ -19,7 +111,9 @@ type LoginReq struct {
 type SwipeReq struct {
 	Id       int64  `json:"id" schema:"id" validate:"required"`
 	TargetId int64  `json:"targetId" schema:"targetId" validate:"required"`
-	Action   string `json:"action" schema:"action" validate:"required,oneof=like pass"`
+	Action   string `json:"action" schema:"action" validate:"required,oneof=like pass superlike"`
+	// ClientSwipeId lets a client retry a swipe safely, reusing it for a different swipe is a conflict
+	ClientSwipeId string `json:"clientSwipeId" schema:"clientSwipeId" validate:"omitempty,max=64"`
 }
 
 type UserData struct {
@@ -32,6 +126,8 @@ type UserData struct {
 	DateOfBirth    string `json:"dateOfBirth"`
 	IsUpgraded     bool   `json:"isUpgraded"`
 	ProfilePicture string `json:"profilePicture"`
+	// Restricted is set while the user is suspended or banned
+	Restricted bool `json:"-"`
 }
 
 type TargetUserData struct {
@@ -43,4 +139,514 @@ type TargetUserData struct {
 	Gender         string `json:"gender"`
 	DateOfBirth    string `json:"dateOfBirth"`
 	ProfilePicture string `json:"profilePicture"`
+	SuperLiked     bool   `json:"superLiked,omitempty"`
+}
+
+type SwipeRes struct {
+	Matched bool  `json:"matched"`
+	MatchId int64 `json:"matchId,omitempty"`
+}
+
+type MatchListReq struct {
+	UserId int64 `json:"userId" schema:"userId" validate:"required"`
+	Page   int   `json:"page" schema:"page" validate:"omitempty,min=1"`
+	Size   int   `json:"size" schema:"size" validate:"omitempty,min=1,max=100"`
+}
+
+type MatchData struct {
+	MatchId   int64           `json:"matchId"`
+	MatchedAt time.Time       `json:"matchedAt"`
+	User      *TargetUserData `json:"user"`
+}
+
+type Pagination struct {
+	Page  int   `json:"page"`
+	Size  int   `json:"size"`
+	Total int64 `json:"total"`
+}
+
+type MatchListRes struct {
+	Matches    []*MatchData `json:"matches"`
+	Pagination Pagination   `json:"pagination"`
+}
+
+type UnmatchReq struct {
+	UserId int64 `json:"userId" schema:"userId" validate:"required"`
+}
+
+// RankingProfile carries the profile signals used to rank discovery candidates.
+// Only TargetUserData is ever returned to clients.
+type RankingProfile struct {
+	TargetUserData
+	Bio          string
+	Latitude     *float64
+	Longitude    *float64
+	LastActiveAt *time.Time
+	Interests    []string
+	Boosted      bool
+	// Incognito is set for candidates in incognito who did not like the viewer
+	Incognito bool
+}
+
+type DeckReq struct {
+	UserId int64  `json:"userId" schema:"userId" validate:"required"`
+	Size   int    `json:"size" schema:"size" validate:"omitempty,min=1,max=20"`
+	Cursor string `json:"cursor" schema:"cursor"`
+}
+
+type DeckCard struct {
+	SwipeId int64 `json:"-"`
+	TargetUserData
+}
+
+type DeckRes struct {
+	Cards      []*DeckCard `json:"cards"`
+	NextCursor string      `json:"nextCursor,omitempty"`
+}
+
+// DiscoveryRules decides which users discovery picks candidates from.
+// Liked and matched users never show up again, passed users return once PassedSince
+// is after the pass and unanswered views return once ViewedSince is after the view.
+// Candidates whose desirability is within ScoreBand of the viewer's are picked first.
+type DiscoveryRules struct {
+	PassedSince time.Time
+	ViewedSince time.Time
+	ScoreBand   float64
+}
+
+// DayRange is one local day of a user as the instants [Start, End)
+type DayRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// SwipeEvent is written with every swipe that changes its action
+type SwipeEvent struct {
+	EventId  int64
+	UserId   int64
+	TargetId int64
+	Action   string
+}
+
+type RewindReq struct {
+	Id int64 `json:"id" schema:"id" validate:"required"`
+}
+
+type RewindRes struct {
+	TargetId int64 `json:"targetId"`
+}
+
+type LikesReceivedReq struct {
+	UserId int64 `json:"userId" schema:"userId" validate:"required"`
+	Page   int   `json:"page" schema:"page" validate:"omitempty,min=1"`
+	Size   int   `json:"size" schema:"size" validate:"omitempty,min=1,max=100"`
+}
+
+type LikeReceivedData struct {
+	LikedAt time.Time       `json:"likedAt"`
+	User    *TargetUserData `json:"user"`
+}
+
+// LikesReceivedRes lists pending likes for upgraded users, free users only get the total
+type LikesReceivedRes struct {
+	Likes      []*LikeReceivedData `json:"likes,omitempty"`
+	Blurred    bool                `json:"blurred"`
+	Pagination Pagination          `json:"pagination"`
+}
+
+type LikeBackReq struct {
+	Id int64 `json:"id" schema:"id" validate:"required"`
+}
+
+type BoostReq struct {
+	Id int64 `json:"id" schema:"id" validate:"required"`
+}
+
+type BoostStatusReq struct {
+	UserId int64 `json:"userId" schema:"userId" validate:"required"`
+}
+
+type BoostData struct {
+	BoostId     int64     `json:"boostId"`
+	StartedAt   time.Time `json:"startedAt"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+	Impressions int64     `json:"impressions"`
+}
+
+// QuotaStatus is the daily allowance of one action for a user, Limit and Remaining
+// are QuotaUnlimited when the action has no daily cap
+type QuotaStatus struct {
+	Action    string    `json:"action"`
+	Tier      string    `json:"tier"`
+	Limit     int64     `json:"limit"`
+	Used      int64     `json:"used"`
+	Remaining int64     `json:"remaining"`
+	ResetAt   time.Time `json:"resetAt"`
+}
+
+// Exhausted reports whether the action cannot be used again today
+func (q *QuotaStatus) Exhausted() bool {
+	return q.Limit != QuotaUnlimited && q.Remaining <= 0
+}
+
+type QuotaReq struct {
+	UserId int64 `json:"userId" schema:"userId" validate:"required"`
+}
+
+type QuotaRes struct {
+	Tier    string         `json:"tier"`
+	ResetAt time.Time      `json:"resetAt"`
+	Quotas  []*QuotaStatus `json:"quotas"`
+}
+
+type QuotaOverrideReq struct {
+	UserId    int64     `json:"userId" validate:"required"`
+	Action    string    `json:"action" validate:"required,oneof=view like superlike rewind"`
+	Limit     int64     `json:"limit" validate:"min=-1"`
+	ExpiresAt time.Time `json:"expiresAt" validate:"required"`
+}
+
+type BlockReq struct {
+	UserId int64 `json:"userId" schema:"userId" validate:"required"`
+}
+
+type ReportReq struct {
+	UserId  int64  `json:"userId" schema:"userId" validate:"required"`
+	Reason  string `json:"reason" schema:"reason" validate:"required,oneof=spam harassment inappropriate_content fake_profile underage other"`
+	Details string `json:"details" schema:"details" validate:"omitempty,max=1000"`
+}
+
+type ReportListReq struct {
+	Status string `json:"status" schema:"status" validate:"omitempty,oneof=open resolved"`
+	Page   int    `json:"page" schema:"page" validate:"omitempty,min=1"`
+	Size   int    `json:"size" schema:"size" validate:"omitempty,min=1,max=100"`
+}
+
+type ReportData struct {
+	ReportId   int64      `json:"reportId"`
+	ReporterId int64      `json:"reporterId"`
+	ReportedId int64      `json:"reportedId"`
+	Reason     string     `json:"reason"`
+	Details    string     `json:"details,omitempty"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+	// ReportCount is how many reports the reported user received in total
+	ReportCount int64 `json:"reportCount"`
+}
+
+type ReportListRes struct {
+	Reports    []*ReportData `json:"reports"`
+	Pagination Pagination    `json:"pagination"`
+}
+
+type ResolveReportReq struct {
+	Action       string `json:"action" schema:"action" validate:"required,oneof=dismiss warn suspend ban"`
+	SuspendHours int64  `json:"suspendHours" schema:"suspendHours" validate:"required_if=Action suspend,omitempty,min=1,max=8760"`
+	Note         string `json:"note" schema:"note" validate:"omitempty,max=1000"`
+}
+
+type NotificationListReq struct {
+	UserId int64 `json:"userId" schema:"userId" validate:"required"`
+	Page   int   `json:"page" schema:"page" validate:"omitempty,min=1"`
+	Size   int   `json:"size" schema:"size" validate:"omitempty,min=1,max=100"`
+}
+
+type NotificationData struct {
+	NotificationId int64     `json:"notificationId"`
+	Type           string    `json:"type"`
+	Message        string    `json:"message"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+type NotificationListRes struct {
+	Notifications []*NotificationData `json:"notifications"`
+	Pagination    Pagination          `json:"pagination"`
+}
+
+type SendMessageReq struct {
+	UserId int64  `json:"userId" schema:"userId" validate:"required"`
+	Body   string `json:"body" schema:"body" validate:"required"`
+}
+
+type MessageData struct {
+	MessageId int64     `json:"messageId"`
+	SenderId  int64     `json:"senderId"`
+	Body      string    `json:"body"`
+	SentAt    time.Time `json:"sentAt"`
+}
+
+type MessageHistoryReq struct {
+	UserId int64  `json:"userId" schema:"userId" validate:"required"`
+	Size   int    `json:"size" schema:"size" validate:"omitempty,min=1,max=100"`
+	Cursor string `json:"cursor" schema:"cursor"`
+}
+
+type MessageHistoryRes struct {
+	Messages   []*MessageData `json:"messages"`
+	NextCursor string         `json:"nextCursor,omitempty"`
+}
+
+type ConversationListReq struct {
+	UserId int64 `json:"userId" schema:"userId" validate:"required"`
+	Page   int   `json:"page" schema:"page" validate:"omitempty,min=1"`
+	Size   int   `json:"size" schema:"size" validate:"omitempty,min=1,max=100"`
+}
+
+type ConversationData struct {
+	ConversationId int64           `json:"conversationId"`
+	MatchId        int64           `json:"matchId"`
+	User           *TargetUserData `json:"user"`
+	LastMessage    *MessageData    `json:"lastMessage"`
+	UnreadCount    int64           `json:"unreadCount"`
+}
+
+type ConversationListRes struct {
+	Conversations []*ConversationData `json:"conversations"`
+	Pagination    Pagination          `json:"pagination"`
+}
+
+type ReadReq struct {
+	UserId    int64 `json:"userId" schema:"userId" validate:"required"`
+	MessageId int64 `json:"messageId" schema:"messageId" validate:"required"`
+}
+
+type ReadMarkerData struct {
+	MatchId           int64 `json:"matchId"`
+	LastReadMessageId int64 `json:"lastReadMessageId"`
+	UnreadCount       int64 `json:"unreadCount"`
+}
+
+type TypingReq struct {
+	UserId int64 `json:"userId" schema:"userId" validate:"required"`
+}
+
+type UnreadReq struct {
+	UserId int64 `json:"userId" schema:"userId" validate:"required"`
+}
+
+type UnreadData struct {
+	MatchId     int64 `json:"matchId"`
+	UnreadCount int64 `json:"unreadCount"`
+}
+
+type UnreadRes struct {
+	Total         int64         `json:"total"`
+	Conversations []*UnreadData `json:"conversations"`
+}
+
+// ChatMatch tells whether the users of a match may message each other
+type ChatMatch struct {
+	MatchId int64
+	PeerId  int64
+	Active  bool
+	Blocked bool
+}
+
+type StreamReq struct {
+	UserId int64 `json:"userId" schema:"userId" validate:"required"`
+}
+
+// MatchStreamEvent tells a user about a new match with the given user
+type MatchStreamEvent struct {
+	MatchId int64 `json:"matchId"`
+	UserId  int64 `json:"userId"`
+}
+
+type MessageStreamEvent struct {
+	MatchId int64        `json:"matchId"`
+	Message *MessageData `json:"message"`
+}
+
+// ReadStreamEvent tells a user how far the other user of the match has read
+type ReadStreamEvent struct {
+	MatchId           int64 `json:"matchId"`
+	UserId            int64 `json:"userId"`
+	LastReadMessageId int64 `json:"lastReadMessageId"`
+}
+
+// TypingStreamEvent tells a user the other user of the match is typing, it is never stored
+type TypingStreamEvent struct {
+	MatchId int64 `json:"matchId"`
+	UserId  int64 `json:"userId"`
+}
+
+// LikeReceivedStreamEvent only names the liker to upgraded users, like the likes list does
+type LikeReceivedStreamEvent struct {
+	UserId  int64  `json:"userId,omitempty"`
+	Action  string `json:"action"`
+	Blurred bool   `json:"blurred"`
+}
+
+type DeviceTokenReq struct {
+	UserId   int64  `json:"userId" schema:"userId" validate:"required"`
+	Platform string `json:"platform" schema:"platform" validate:"required,oneof=ios android web"`
+	Token    string `json:"token" schema:"token" validate:"required,max=255"`
+}
+
+type RemoveDeviceTokenReq struct {
+	UserId int64  `json:"userId" schema:"userId" validate:"required"`
+	Token  string `json:"token" schema:"token" validate:"required,max=255"`
+}
+
+type DeviceToken struct {
+	DeviceTokenId int64  `json:"deviceTokenId"`
+	UserId        int64  `json:"userId"`
+	Platform      string `json:"platform"`
+	Token         string `json:"token"`
+}
+
+type NotificationPreferencesReq struct {
+	UserId int64 `json:"userId" schema:"userId" validate:"required"`
+}
+
+// UpdateNotificationPreferencesReq only changes the preferences it carries
+type UpdateNotificationPreferencesReq struct {
+	UserId   int64 `json:"userId" schema:"userId" validate:"required"`
+	Matches  *bool `json:"matches" schema:"matches"`
+	Messages *bool `json:"messages" schema:"messages"`
+}
+
+type NotificationPreferences struct {
+	Matches  bool `json:"matches"`
+	Messages bool `json:"messages"`
+}
+
+// PushOutboxEntry is a push waiting in the outbox, its payload is the matching stream event
+type PushOutboxEntry struct {
+	OutboxId int64
+	UserId   int64
+	PushType string
+	Payload  json.RawMessage
+	Attempts int64
+}
+
+// Push is what a PushSender delivers to a device
+type Push struct {
+	Type  string          `json:"type"`
+	Title string          `json:"title"`
+	Body  string          `json:"body"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+type UpgradeReq struct {
+	Plan string `json:"plan" schema:"plan" validate:"required,oneof=monthly yearly"`
+}
+
+type SubscriptionReq struct {
+	UserId int64 `json:"userId" schema:"userId" validate:"required"`
+}
+
+type CancelSubscriptionReq struct {
+	UserId int64 `json:"userId" schema:"userId" validate:"required"`
+}
+
+type SubscriptionData struct {
+	SubscriptionId   int64      `json:"subscriptionId"`
+	UserId           int64      `json:"userId"`
+	Plan             string     `json:"plan"`
+	Status           string     `json:"status"`
+	Store            string     `json:"store"`
+	AutoRenew        bool       `json:"autoRenew"`
+	StartedAt        time.Time  `json:"startedAt"`
+	CurrentPeriodEnd time.Time  `json:"currentPeriodEnd"`
+	GraceEndsAt      *time.Time `json:"graceEndsAt,omitempty"`
+	CanceledAt       *time.Time `json:"canceledAt,omitempty"`
+	// Entitled tells whether the subscription currently grants premium
+	Entitled bool `json:"entitled"`
+}
+
+type PaymentData struct {
+	PaymentId      int64     `json:"paymentId"`
+	OrderId        string    `json:"orderId"`
+	UserId         int64     `json:"userId"`
+	Plan           string    `json:"plan"`
+	Amount         int64     `json:"amount"`
+	Currency       string    `json:"currency"`
+	Status         string    `json:"status"`
+	SubscriptionId *int64    `json:"subscriptionId,omitempty"`
+	CheckoutURL    string    `json:"checkoutUrl,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// CheckoutRes tells the client where the user pays for the upgrade
+type CheckoutRes struct {
+	PaymentId   int64     `json:"paymentId"`
+	OrderId     string    `json:"orderId"`
+	Plan        string    `json:"plan"`
+	Amount      int64     `json:"amount"`
+	Currency    string    `json:"currency"`
+	CheckoutURL string    `json:"checkoutUrl"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
+
+// Entitlement is a feature a user has, Limit is its daily limit or QuotaUnlimited
+type Entitlement struct {
+	Feature string `json:"feature"`
+	Limit   int64  `json:"limit"`
+}
+
+type EntitlementsReq struct {
+	UserId int64 `json:"userId" schema:"userId" validate:"required"`
+}
+
+// EntitlementsRes lists the packages a user has and the features they add up to
+type EntitlementsRes struct {
+	Tier         string         `json:"tier"`
+	Packages     []string       `json:"packages"`
+	Entitlements []*Entitlement `json:"entitlements"`
+}
+
+// Limit returns the daily limit of a feature, QuotaUnlimited when it has none and 0 when the user does not have it
+func (r *EntitlementsRes) Limit(feature string) int64 {
+	for _, entitlement := range r.Entitlements {
+		if entitlement.Feature == feature {
+			return entitlement.Limit
+		}
+	}
+	return 0
+}
+
+type EntitlementGrantReq struct {
+	UserId    int64     `json:"userId" validate:"required"`
+	Package   string    `json:"package" validate:"required"`
+	ExpiresAt time.Time `json:"expiresAt" validate:"required"`
+}
+
+// EntitlementGrant gives a user an entitlement package until it expires, outside of any plan
+type EntitlementGrant struct {
+	GrantId   int64     `json:"grantId"`
+	UserId    int64     `json:"userId"`
+	Package   string    `json:"package"`
+	Source    string    `json:"source"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type PromoCodeReq struct {
+	Code           string    `json:"code" validate:"required,alphanum,max=32"`
+	Package        string    `json:"package" validate:"required"`
+	Days           int64     `json:"days" validate:"required,min=1"`
+	MaxRedemptions int64     `json:"maxRedemptions" validate:"required,min=1"`
+	ExpiresAt      time.Time `json:"expiresAt" validate:"required"`
+}
+
+type PromoCodeData struct {
+	PromoCodeId     int64     `json:"promoCodeId"`
+	Code            string    `json:"code"`
+	Package         string    `json:"package"`
+	Days            int64     `json:"days"`
+	MaxRedemptions  int64     `json:"maxRedemptions"`
+	RedemptionCount int64     `json:"redemptionCount"`
+	ExpiresAt       time.Time `json:"expiresAt"`
+}
+
+type RedeemPromoReq struct {
+	UserId int64  `json:"userId" validate:"required"`
+	Code   string `json:"code" validate:"required,max=32"`
+}
+
+type ReceiptReq struct {
+	UserId  int64  `json:"userId" validate:"required"`
+	Store   string `json:"store" validate:"required,oneof=app_store google_play"`
+	Receipt string `json:"receipt" validate:"required"`
 }
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+)
+
+type ChatRepository interface {
+	GetChatMatch(ctx context.Context, matchId, userId uint64) (data *minder_model.ChatMatch, err error)
+	InsertMessage(ctx context.Context, matchId, senderId, recipientId uint64, body string, sentAt time.Time) (data *minder_model.MessageData, err error)
+	MarkRead(ctx context.Context, matchId, userId uint64, messageId int64) (data *minder_model.ReadMarkerData, err error)
+	GetUnreadCounts(ctx context.Context, userId uint64) (data []*minder_model.UnreadData, err error)
+	CountMessagesSince(ctx context.Context, senderId uint64, since time.Time) (total int64, err error)
+	GetMessages(ctx context.Context, matchId uint64, beforeMessageId int64, limit int) (data []*minder_model.MessageData, err error)
+	GetConversations(ctx context.Context, userId uint64, limit, offset int) (data []*minder_model.ConversationData, err error)
+	CountConversations(ctx context.Context, userId uint64) (total int64, err error)
+}
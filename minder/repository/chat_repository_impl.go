@@ -0,0 +1,382 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+)
+
+type chatRepositoryImpl struct {
+	DB *sql.DB
+}
+
+func NewChatRepositoryImpl(db *sql.DB) ChatRepository {
+	return &chatRepositoryImpl{DB: db}
+}
+
+const (
+	getChatMatch = `SELECT m.match_id, IF(m.user_one_id = ?, m.user_two_id, m.user_one_id) AS peer_id,
+				m.unmatched_at IS NULL AS active,
+				EXISTS (
+					SELECT 1
+					FROM Blocks bl
+					WHERE (bl.blocker_id = m.user_one_id AND bl.blocked_id = m.user_two_id)
+						OR (bl.blocker_id = m.user_two_id AND bl.blocked_id = m.user_one_id)
+				) AS blocked
+			FROM Matches m
+			WHERE m.match_id = ? AND (m.user_one_id = ? OR m.user_two_id = ?)`
+
+	insertConversation = `INSERT IGNORE INTO Conversations (match_id) VALUES (?)`
+
+	getConversationId = `SELECT conversation_id FROM Conversations WHERE match_id=? FOR UPDATE`
+
+	insertMessage = `INSERT INTO Messages (conversation_id, sender_id, body, created_at) VALUES (?,?,?,?)`
+
+	updateLastMessage = `UPDATE Conversations SET last_message_id=?, last_message_at=? WHERE conversation_id=?`
+
+	incrementUnread = `INSERT INTO ConversationReads (conversation_id, user_id, unread_count) VALUES (?,?,1)
+			ON DUPLICATE KEY UPDATE unread_count = unread_count + 1`
+
+	// replying reads everything sent before, so the sender's marker moves to their own message
+	markSenderRead = `INSERT INTO ConversationReads (conversation_id, user_id, last_read_message_id) VALUES (?,?,?)
+			ON DUPLICATE KEY UPDATE last_read_message_id = VALUES(last_read_message_id), unread_count = 0`
+
+	getMessageInConversation = `SELECT message_id FROM Messages WHERE conversation_id=? AND message_id=?`
+
+	getLastReadMessageId = `SELECT COALESCE(MAX(last_read_message_id), 0) FROM ConversationReads WHERE conversation_id=? AND user_id=?`
+
+	countUnreadMessages = `SELECT COUNT(1) FROM Messages WHERE conversation_id=? AND sender_id<>? AND message_id > ?`
+
+	upsertReadMarker = `INSERT INTO ConversationReads (conversation_id, user_id, last_read_message_id, unread_count) VALUES (?,?,?,?)
+			ON DUPLICATE KEY UPDATE last_read_message_id = VALUES(last_read_message_id), unread_count = VALUES(unread_count)`
+
+	countMessagesSince = `SELECT COUNT(1) FROM Messages WHERE sender_id=? AND created_at >= ?`
+
+	getMessages = `SELECT msg.message_id, msg.sender_id, msg.body, msg.created_at
+			FROM Messages msg
+			JOIN Conversations c ON c.conversation_id = msg.conversation_id
+			WHERE c.match_id = ?
+				AND (? = 0 OR msg.message_id < ?)
+			ORDER BY msg.message_id DESC
+			LIMIT ?`
+
+	// conversationCondition keeps the conversations of user ? whose match is still active and unblocked
+	conversationCondition = `(m.user_one_id = ? OR m.user_two_id = ?)
+				AND m.unmatched_at IS NULL
+				AND c.last_message_id IS NOT NULL
+				AND NOT EXISTS (
+					SELECT 1
+					FROM Blocks bl
+					WHERE (bl.blocker_id = m.user_one_id AND bl.blocked_id = m.user_two_id)
+						OR (bl.blocker_id = m.user_two_id AND bl.blocked_id = m.user_one_id)
+				)`
+
+	getConversations = `SELECT c.conversation_id, m.match_id,
+				u.user_id, u.username, u.email, u.phone_number, u.full_name, u.gender, u.date_of_birth, u.profile_picture,
+				msg.message_id, msg.sender_id, msg.body, msg.created_at, COALESCE(cr.unread_count, 0)
+			FROM Conversations c
+			JOIN Matches m ON m.match_id = c.match_id
+			JOIN Users u ON u.user_id = IF(m.user_one_id = ?, m.user_two_id, m.user_one_id)
+			JOIN Messages msg ON msg.message_id = c.last_message_id
+			LEFT JOIN ConversationReads cr ON cr.conversation_id = c.conversation_id AND cr.user_id = ?
+			WHERE ` + conversationCondition + `
+			ORDER BY c.last_message_at DESC, c.conversation_id DESC
+			LIMIT ? OFFSET ?`
+
+	countConversations = `SELECT COUNT(1)
+			FROM Conversations c
+			JOIN Matches m ON m.match_id = c.match_id
+			WHERE ` + conversationCondition
+
+	getUnreadCounts = `SELECT c.match_id, cr.unread_count
+			FROM ConversationReads cr
+			JOIN Conversations c ON c.conversation_id = cr.conversation_id
+			JOIN Matches m ON m.match_id = c.match_id
+			WHERE cr.user_id = ? AND cr.unread_count > 0
+				AND ` + conversationCondition + `
+			ORDER BY c.last_message_at DESC, c.conversation_id DESC`
+)
+
+// GetChatMatch returns the match when the user is part of it, or ErrNotFound otherwise
+func (r *chatRepositoryImpl) GetChatMatch(ctx context.Context, matchId, userId uint64) (data *minder_model.ChatMatch, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, getChatMatch)
+	if err != nil {
+		log.Println(ctx, "[repository:chat] Preparing Get Chat Match err", err)
+		return
+	}
+	defer stmt.Close()
+
+	data = &minder_model.ChatMatch{}
+	err = stmt.QueryRowContext(ctx, userId, matchId, userId, userId).Scan(&data.MatchId, &data.PeerId, &data.Active, &data.Blocked)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		log.Println(ctx, "[repository:chat] Get Chat Match err", err)
+		return nil, err
+	}
+	return
+}
+
+// InsertMessage stores the message in the match's conversation, starting the conversation on its first message,
+// counting it as unread for the recipient and queueing the recipient's push
+func (r *chatRepositoryImpl) InsertMessage(ctx context.Context, matchId, senderId, recipientId uint64, body string, sentAt time.Time) (data *minder_model.MessageData, err error) {
+	err = runInTx(ctx, r.DB, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, insertConversation, matchId); err != nil {
+			log.Println(ctx, "[repository:chat] Insert Conversation err ", err)
+			return err
+		}
+
+		var conversationId int64
+		if err := tx.QueryRowContext(ctx, getConversationId, matchId).Scan(&conversationId); err != nil {
+			log.Println(ctx, "[repository:chat] Get Conversation err ", err)
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx, insertMessage, conversationId, senderId, body, sentAt)
+		if err != nil {
+			log.Println(ctx, "[repository:chat] Insert Message err ", err)
+			return err
+		}
+		messageId, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, updateLastMessage, messageId, sentAt, conversationId); err != nil {
+			log.Println(ctx, "[repository:chat] Update Last Message err ", err)
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, incrementUnread, conversationId, recipientId); err != nil {
+			log.Println(ctx, "[repository:chat] Increment Unread err ", err)
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, markSenderRead, conversationId, senderId, messageId); err != nil {
+			log.Println(ctx, "[repository:chat] Mark Sender Read err ", err)
+			return err
+		}
+
+		data = &minder_model.MessageData{
+			MessageId: messageId,
+			SenderId:  int64(senderId),
+			Body:      body,
+			SentAt:    sentAt,
+		}
+		return writePushOutbox(ctx, tx, int64(recipientId), minder_model.PushTypeMessage, &minder_model.MessageStreamEvent{MatchId: int64(matchId), Message: data})
+	})
+	return
+}
+
+// MarkRead moves the user's read marker of the match's conversation up to messageId, recounting what is
+// left unread after it. A marker already past messageId stays where it is. ErrNotFound is returned when
+// the message is not part of the conversation.
+func (r *chatRepositoryImpl) MarkRead(ctx context.Context, matchId, userId uint64, messageId int64) (data *minder_model.ReadMarkerData, err error) {
+	err = runInTx(ctx, r.DB, func(tx *sql.Tx) error {
+		var conversationId int64
+		err := tx.QueryRowContext(ctx, getConversationId, matchId).Scan(&conversationId)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			log.Println(ctx, "[repository:chat] Get Conversation err ", err)
+			return err
+		}
+
+		err = tx.QueryRowContext(ctx, getMessageInConversation, conversationId, messageId).Scan(&messageId)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			log.Println(ctx, "[repository:chat] Get Message err ", err)
+			return err
+		}
+
+		var lastReadId int64
+		if err := tx.QueryRowContext(ctx, getLastReadMessageId, conversationId, userId).Scan(&lastReadId); err != nil {
+			log.Println(ctx, "[repository:chat] Get Last Read Message err ", err)
+			return err
+		}
+		lastReadId = max(lastReadId, messageId)
+
+		var unread int64
+		if err := tx.QueryRowContext(ctx, countUnreadMessages, conversationId, userId, lastReadId).Scan(&unread); err != nil {
+			log.Println(ctx, "[repository:chat] Count Unread Messages err ", err)
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, upsertReadMarker, conversationId, userId, lastReadId, unread); err != nil {
+			log.Println(ctx, "[repository:chat] Upsert Read Marker err ", err)
+			return err
+		}
+
+		data = &minder_model.ReadMarkerData{
+			MatchId:           int64(matchId),
+			LastReadMessageId: lastReadId,
+			UnreadCount:       unread,
+		}
+		return nil
+	})
+	return
+}
+
+// GetUnreadCounts returns the user's conversations with unread messages, most recent first
+func (r *chatRepositoryImpl) GetUnreadCounts(ctx context.Context, userId uint64) (data []*minder_model.UnreadData, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, getUnreadCounts)
+	if err != nil {
+		log.Println(ctx, "[repository:chat] Preparing Get Unread Counts err", err)
+		return
+	}
+	defer stmt.Close()
+	rows, err := stmt.QueryContext(ctx, userId, userId, userId)
+	if err != nil {
+		log.Println(ctx, "[repository:chat] Get Unread Counts err", err)
+		return
+	}
+
+	defer func() {
+		closeRows(rows)
+		if err := rows.Err(); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	data = []*minder_model.UnreadData{}
+	for rows.Next() {
+		var unread minder_model.UnreadData
+		err = rows.Scan(&unread.MatchId, &unread.UnreadCount)
+		if err != nil {
+			log.Println("[repository:chat] Error scanning row:", err)
+			return nil, err
+		}
+		data = append(data, &unread)
+	}
+
+	return
+}
+
+func (r *chatRepositoryImpl) CountMessagesSince(ctx context.Context, senderId uint64, since time.Time) (total int64, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, countMessagesSince)
+	if err != nil {
+		log.Println(ctx, "[repository:chat] Preparing Count Messages Since err", err)
+		return
+	}
+	defer stmt.Close()
+	err = stmt.QueryRowContext(ctx, senderId, since).Scan(&total)
+	if err != nil {
+		log.Println(ctx, "[repository:chat] Count Messages Since err", err)
+	}
+	return
+}
+
+// GetMessages returns the match's messages newest first, older than beforeMessageId unless it is 0
+func (r *chatRepositoryImpl) GetMessages(ctx context.Context, matchId uint64, beforeMessageId int64, limit int) (data []*minder_model.MessageData, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, getMessages)
+	if err != nil {
+		log.Println(ctx, "[repository:chat] Preparing Get Messages err", err)
+		return
+	}
+	defer stmt.Close()
+	rows, err := stmt.QueryContext(ctx, matchId, beforeMessageId, beforeMessageId, limit)
+	if err != nil {
+		log.Println(ctx, "[repository:chat] Get Messages err", err)
+		return
+	}
+
+	defer func() {
+		closeRows(rows)
+		if err := rows.Err(); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	data = []*minder_model.MessageData{}
+	for rows.Next() {
+		var message minder_model.MessageData
+		err = rows.Scan(
+			&message.MessageId,
+			&message.SenderId,
+			&message.Body,
+			&message.SentAt,
+		)
+		if err != nil {
+			log.Println("[repository:chat] Error scanning row:", err)
+			return nil, err
+		}
+		data = append(data, &message)
+	}
+
+	return
+}
+
+// GetConversations returns the user's conversations with their last message, most recent first
+func (r *chatRepositoryImpl) GetConversations(ctx context.Context, userId uint64, limit, offset int) (data []*minder_model.ConversationData, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, getConversations)
+	if err != nil {
+		log.Println(ctx, "[repository:chat] Preparing Get Conversations err", err)
+		return
+	}
+	defer stmt.Close()
+	rows, err := stmt.QueryContext(ctx, userId, userId, userId, userId, limit, offset)
+	if err != nil {
+		log.Println(ctx, "[repository:chat] Get Conversations err", err)
+		return
+	}
+
+	defer func() {
+		closeRows(rows)
+		if err := rows.Err(); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	data = []*minder_model.ConversationData{}
+	for rows.Next() {
+		conversation := &minder_model.ConversationData{
+			User:        &minder_model.TargetUserData{},
+			LastMessage: &minder_model.MessageData{},
+		}
+		err = rows.Scan(
+			&conversation.ConversationId,
+			&conversation.MatchId,
+			&conversation.User.UserId,
+			&conversation.User.Username,
+			&conversation.User.Email,
+			&conversation.User.PhoneNumber,
+			&conversation.User.FullName,
+			&conversation.User.Gender,
+			&conversation.User.DateOfBirth,
+			&conversation.User.ProfilePicture,
+			&conversation.LastMessage.MessageId,
+			&conversation.LastMessage.SenderId,
+			&conversation.LastMessage.Body,
+			&conversation.LastMessage.SentAt,
+			&conversation.UnreadCount,
+		)
+		if err != nil {
+			log.Println("[repository:chat] Error scanning row:", err)
+			return nil, err
+		}
+		data = append(data, conversation)
+	}
+
+	return
+}
+
+func (r *chatRepositoryImpl) CountConversations(ctx context.Context, userId uint64) (total int64, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, countConversations)
+	if err != nil {
+		log.Println(ctx, "[repository:chat] Preparing Count Conversations err", err)
+		return
+	}
+	defer stmt.Close()
+	err = stmt.QueryRowContext(ctx, userId, userId).Scan(&total)
+	if err != nil {
+		log.Println(ctx, "[repository:chat] Count Conversations err", err)
+	}
+	return
+}
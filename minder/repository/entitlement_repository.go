@@ -0,0 +1,13 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+)
+
+type EntitlementRepository interface {
+	InsertGrant(ctx context.Context, userId uint64, pkg, source string, expiresAt time.Time) (data int64, err error)
+	GetActiveGrants(ctx context.Context, userId uint64, now time.Time) (data []*minder_model.EntitlementGrant, err error)
+}
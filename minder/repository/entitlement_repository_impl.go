@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+)
+
+type entitlementRepositoryImpl struct {
+	DB *sql.DB
+}
+
+func NewEntitlementRepositoryImpl(db *sql.DB) EntitlementRepository {
+	return &entitlementRepositoryImpl{DB: db}
+}
+
+const (
+	insertGrant = `INSERT INTO EntitlementGrants (user_id, package, source, expires_at) VALUES (?,?,?,?)`
+
+	getActiveGrants = `SELECT grant_id, user_id, package, source, expires_at
+			FROM EntitlementGrants
+			WHERE user_id = ? AND expires_at > ?
+			ORDER BY expires_at`
+)
+
+func (r *entitlementRepositoryImpl) InsertGrant(ctx context.Context, userId uint64, pkg, source string, expiresAt time.Time) (data int64, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, insertGrant)
+	if err != nil {
+		log.Println(ctx, "[repository:entitlement] Preparing Insert Grant err", err)
+		return
+	}
+	defer stmt.Close()
+	result, err := stmt.ExecContext(ctx, userId, pkg, source, expiresAt)
+	if err != nil {
+		log.Println(ctx, "[repository:entitlement] Insert Grant err ", err)
+		return
+	}
+
+	return result.LastInsertId()
+}
+
+func (r *entitlementRepositoryImpl) GetActiveGrants(ctx context.Context, userId uint64, now time.Time) (data []*minder_model.EntitlementGrant, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, getActiveGrants)
+	if err != nil {
+		log.Println(ctx, "[repository:entitlement] Preparing Get Active Grants err", err)
+		return
+	}
+	defer stmt.Close()
+	rows, err := stmt.QueryContext(ctx, userId, now)
+	if err != nil {
+		log.Println(ctx, "[repository:entitlement] Get Active Grants err", err)
+		return
+	}
+
+	defer func() {
+		closeRows(rows)
+		if err := rows.Err(); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	data = []*minder_model.EntitlementGrant{}
+	for rows.Next() {
+		var grant minder_model.EntitlementGrant
+		err = rows.Scan(&grant.GrantId, &grant.UserId, &grant.Package, &grant.Source, &grant.ExpiresAt)
+		if err != nil {
+			log.Println("[repository:entitlement] Error scanning row:", err)
+			return nil, err
+		}
+		data = append(data, &grant)
+	}
+
+	return
+}
@@ -0,0 +1,23 @@
+package repository
+
+import "errors"
+
+var (
+	// ErrNotFound is returned when the requested row does not exist
+	ErrNotFound = errors.New("no data found")
+
+	// ErrSwipeMatched is returned when a swipe cannot change because it already created a match
+	ErrSwipeMatched = errors.New("swipe already created a match")
+
+	// ErrSwipeConflict is returned when a client swipe id was already used for a different swipe
+	ErrSwipeConflict = errors.New("client swipe id already used for another swipe")
+
+	// ErrReportResolved is returned when a moderator acts on a report that is already resolved
+	ErrReportResolved = errors.New("report already resolved")
+
+	// ErrPromoCodeExists is returned when a promo code is created with a code already in use
+	ErrPromoCodeExists = errors.New("promo code already exists")
+
+	// ErrPromoRedeemed is returned when a user redeems a promo code they already redeemed
+	ErrPromoRedeemed = errors.New("promo code already redeemed by the user")
+)
@@ -2,17 +2,56 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	minder_model "github.com/AlvinTendio/minder/minder/model"
 )
 
 type MinderRepository interface {
-	Register(ctx context.Context, req *minder_model.RegisterReq) (data int64, err error)
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+	LockUsers(ctx context.Context, ids ...uint64) error
+	Register(ctx context.Context, req *minder_model.RegisterReq, trialEndsAt time.Time) (data int64, err error)
 	Login(ctx context.Context, req *minder_model.LoginReq) (data *minder_model.UserData, err error)
-	UpgradeAccount(ctx context.Context, id uint64) (data int64, err error)
-	GetUserUpgradeStatus(ctx context.Context, id uint64) (data bool, err error)
-	GetUserViewCount(ctx context.Context, id uint64) (total *int64, err error)
-	GetTargetUser(ctx context.Context, id uint64) (data *minder_model.TargetUserData, err error)
+	GetUserTimeZone(ctx context.Context, id uint64) (timeZone string, err error)
+	UpdateTimeZone(ctx context.Context, id uint64, timeZone string) (data int64, err error)
+	UpdateIncognito(ctx context.Context, id uint64, enabled bool) (data int64, err error)
+	GetUserViewCount(ctx context.Context, id uint64, day minder_model.DayRange) (total *int64, err error)
+	GetRankingProfile(ctx context.Context, id uint64) (data *minder_model.RankingProfile, err error)
+	GetCandidates(ctx context.Context, id uint64, rules minder_model.DiscoveryRules, limit int) (data []*minder_model.RankingProfile, err error)
 	InsertSwipe(ctx context.Context, id uint64, targetId int64) (data int64, err error)
-	UpdateSwipe(ctx context.Context, req *minder_model.SwipeReq) (data int64, err error)
+	GetUserReservedCount(ctx context.Context, id uint64, day minder_model.DayRange) (total int64, err error)
+	GetReservedCards(ctx context.Context, id uint64, day minder_model.DayRange, afterSwipeId int64, limit int) (data []*minder_model.DeckCard, err error)
+	ReserveSwipe(ctx context.Context, id uint64, targetId int64) (swipeId int64, err error)
+	GetUserRewindCount(ctx context.Context, id uint64, day minder_model.DayRange) (total int64, err error)
+	Rewind(ctx context.Context, id uint64) (targetId int64, err error)
+	GetSwipeAction(ctx context.Context, req *minder_model.SwipeReq) (action string, err error)
+	Swipe(ctx context.Context, req *minder_model.SwipeReq) (data *minder_model.SwipeRes, err error)
+	GetUserSuperLikeCount(ctx context.Context, id uint64, day minder_model.DayRange) (total int64, err error)
+	GetActiveBoost(ctx context.Context, id uint64) (data *minder_model.BoostData, err error)
+	GetUserBoostCount(ctx context.Context, id uint64, day minder_model.DayRange) (total int64, err error)
+	InsertBoost(ctx context.Context, id uint64, minutes int64) (data int64, err error)
+	IncrementBoostImpressions(ctx context.Context, id int64) (data int64, err error)
+	GetUserTier(ctx context.Context, id uint64) (tier string, err error)
+	GetUserLikeCount(ctx context.Context, id uint64, day minder_model.DayRange) (total int64, err error)
+	GetQuotaOverride(ctx context.Context, id uint64, action string) (limit int64, err error)
+	InsertQuotaOverride(ctx context.Context, req *minder_model.QuotaOverrideReq) (data int64, err error)
+	GetDesirability(ctx context.Context, id int64) (score float64, err error)
+	UpdateDesirability(ctx context.Context, id int64, score float64) (data int64, err error)
+	InsertSwipeEvent(ctx context.Context, event *minder_model.SwipeEvent) (data int64, err error)
+	GetPendingSwipeEvents(ctx context.Context, limit int) (data []*minder_model.SwipeEvent, err error)
+	MarkSwipeEventProcessed(ctx context.Context, eventId int64) (data int64, err error)
+	GetMatches(ctx context.Context, id uint64, limit, offset int) (data []*minder_model.MatchData, err error)
+	CountMatches(ctx context.Context, id uint64) (total int64, err error)
+	Unmatch(ctx context.Context, matchId, userId uint64) (data int64, err error)
+	GetLikesReceived(ctx context.Context, id uint64, limit, offset int) (data []*minder_model.LikeReceivedData, err error)
+	CountLikesReceived(ctx context.Context, id uint64) (total int64, err error)
+	LikeBack(ctx context.Context, id uint64, likerId int64) (data *minder_model.SwipeRes, err error)
+	Block(ctx context.Context, blockerId, blockedId uint64) (data int64, err error)
+	InsertReport(ctx context.Context, reportedId uint64, req *minder_model.ReportReq) (reportId int64, err error)
+	GetReports(ctx context.Context, status string, limit, offset int) (data []*minder_model.ReportData, err error)
+	CountReports(ctx context.Context, status string) (total int64, err error)
+	ResolveReport(ctx context.Context, reportId uint64, req *minder_model.ResolveReportReq, now time.Time) (data *minder_model.ReportData, err error)
+	InsertNotification(ctx context.Context, id int64, notificationType, message string) (data int64, err error)
+	GetNotifications(ctx context.Context, id uint64, limit, offset int) (data []*minder_model.NotificationData, err error)
+	CountNotifications(ctx context.Context, id uint64) (total int64, err error)
 }
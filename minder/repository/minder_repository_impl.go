@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	minder_model "github.com/AlvinTendio/minder/minder/model"
 )
@@ -17,36 +19,317 @@ func NewMinderRepositoryImpl(db *sql.DB) MinderRepository {
 	return &minderRepositoryImpl{DB: db}
 }
 
+// defaultDesirability is the score of users nobody swiped on yet
+const defaultDesirability = "1500"
+
 const (
-	insertUsers = `INSERT INTO Users (username, email, phone_number, password, full_name, gender, date_of_birth, profile_picture)
-					VALUES (?,?,?,?,?,?,?,?)`
-	getUsersLoginData = `SELECT user_id, username, email, phone_number, full_name, gender, date_of_birth, profile_picture, is_upgraded FROM Users
+	insertUsers = `INSERT INTO Users (username, email, phone_number, password, full_name, gender, date_of_birth, profile_picture, bio, latitude, longitude, time_zone, trial_ends_at)
+					VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?)`
+	getUsersLoginData = `SELECT user_id, username, email, phone_number, full_name, gender, date_of_birth, profile_picture,
+					banned_at IS NOT NULL OR suspended_until > CURRENT_TIMESTAMP AS restricted
+					FROM Users
 					WHERE username = ? AND password = ?`
-	upgradeAccount = `UPDATE Users set is_upgraded=true WHERE user_id =?`
+	getUserTimeZone = `SELECT COALESCE(time_zone, '') FROM Users WHERE user_id=?`
+
+	updateTimeZone = `UPDATE Users SET time_zone=? WHERE user_id=?`
+
+	updateIncognito = `UPDATE Users SET incognito=? WHERE user_id=?`
+
+	getUserViewCount = `SELECT COUNT(1) AS total FROM Swipes
+			WHERE user_id=? AND created_at >= ? AND created_at < ? AND (is_reserved = FALSE OR swipe_action IS NOT NULL)`
+
+	getUserReservedCount = `SELECT COUNT(1) AS total FROM Swipes
+			WHERE user_id=? AND created_at >= ? AND created_at < ? AND is_reserved = TRUE AND swipe_action IS NULL`
+
+	getReservedCards = `SELECT s.swipe_id, u.user_id, u.username, u.email, u.phone_number, u.full_name, u.gender, u.date_of_birth, u.profile_picture,
+				EXISTS (
+					SELECT 1
+					FROM Swipes sl
+					WHERE sl.user_id = u.user_id
+						AND sl.target_user_id = s.user_id
+						AND sl.swipe_action = 'superlike'
+				) AS super_liked
+			FROM Swipes s
+			JOIN Users u ON u.user_id = s.target_user_id
+			WHERE s.user_id = ?
+				AND s.is_reserved = TRUE
+				AND s.swipe_action IS NULL
+				AND (
+					s.requeued_at IS NOT NULL
+					OR (s.created_at >= ? AND s.created_at < ? AND s.swipe_id > ?)
+				)
+				AND ` + activeUser + `
+				AND ` + notBlocked + `
+			ORDER BY s.requeued_at IS NULL, s.requeued_at DESC, s.swipe_id
+			LIMIT ?`
+
+	insertReservedSwipe = `INSERT INTO Swipes (user_id, target_user_id, is_reserved) VALUES(?,?,TRUE)`
+
+	getUserRewindCount = `SELECT COUNT(1) AS total FROM Rewinds WHERE user_id=? AND created_at >= ? AND created_at < ?`
+
+	getLastActionedSwipe = `SELECT swipe_id, target_user_id, swipe_action FROM Swipes
+			WHERE user_id=? AND swipe_action IS NOT NULL
+			ORDER BY actioned_at DESC, swipe_id DESC
+			LIMIT 1
+			FOR UPDATE`
+
+	countPairMatch = `SELECT COUNT(1) FROM Matches WHERE user_one_id=? AND user_two_id=?`
+
+	requeueSwipe = `UPDATE Swipes SET swipe_action=NULL, actioned_at=NULL, is_reserved=TRUE, requeued_at=CURRENT_TIMESTAMP
+			WHERE swipe_id=?`
+
+	insertRewind = `INSERT INTO Rewinds (user_id, swipe_id, previous_action) VALUES (?,?,?)`
+
+	// activeUser holds for users of alias u who are neither banned nor suspended
+	activeUser = `u.banned_at IS NULL AND (u.suspended_until IS NULL OR u.suspended_until <= CURRENT_TIMESTAMP)`
+
+	// notBlocked holds when neither s.user_id nor s.target_user_id blocked the other
+	notBlocked = `NOT EXISTS (
+					SELECT 1
+					FROM Blocks bl
+					WHERE (bl.blocker_id = s.user_id AND bl.blocked_id = s.target_user_id)
+						OR (bl.blocker_id = s.target_user_id AND bl.blocked_id = s.user_id)
+				)`
+
+	pendingLikeCondition = `s.swipe_action IN ('like', 'superlike')
+				AND ` + notBlocked + `
+				AND NOT EXISTS (
+					SELECT 1
+					FROM Users liker
+					WHERE liker.user_id = s.user_id
+						AND (liker.banned_at IS NOT NULL OR liker.suspended_until > CURRENT_TIMESTAMP)
+				)
+				AND NOT EXISTS (
+					SELECT 1
+					FROM Swipes mine
+					WHERE mine.user_id = s.target_user_id
+						AND mine.target_user_id = s.user_id
+						AND mine.swipe_action IS NOT NULL
+				)
+				AND NOT EXISTS (
+					SELECT 1
+					FROM Matches m
+					WHERE m.user_one_id = LEAST(s.user_id, s.target_user_id)
+						AND m.user_two_id = GREATEST(s.user_id, s.target_user_id)
+				)`
+
+	getLikesReceived = `SELECT s.actioned_at, s.swipe_action = 'superlike', u.user_id, u.username, u.email, u.phone_number, u.full_name, u.gender, u.date_of_birth, u.profile_picture
+			FROM Swipes s
+			JOIN Users u ON u.user_id = s.user_id
+			WHERE s.target_user_id = ?
+				AND ` + pendingLikeCondition + `
+			ORDER BY s.actioned_at DESC, s.swipe_id DESC
+			LIMIT ? OFFSET ?`
+
+	countLikesReceived = `SELECT COUNT(DISTINCT s.user_id)
+			FROM Swipes s
+			WHERE s.target_user_id = ?
+				AND ` + pendingLikeCondition
+
+	countPendingLike = `SELECT COUNT(1)
+			FROM Swipes s
+			WHERE s.user_id = ?
+				AND s.target_user_id = ?
+				AND ` + pendingLikeCondition + `
+			LOCK IN SHARE MODE`
+
+	getActiveBoost = `SELECT boost_id, started_at, expires_at, impressions FROM Boosts
+			WHERE user_id=? AND expires_at > CURRENT_TIMESTAMP
+			ORDER BY expires_at DESC
+			LIMIT 1`
+
+	getUserBoostCount = `SELECT COUNT(1) AS total FROM Boosts WHERE user_id=? AND started_at >= ? AND started_at < ?`
+
+	insertBoost = `INSERT INTO Boosts (user_id, started_at, expires_at)
+			VALUES (?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP + INTERVAL ? MINUTE)`
+
+	incrementBoostImpressions = `UPDATE Boosts SET impressions = impressions + 1
+			WHERE user_id=? AND expires_at > CURRENT_TIMESTAMP`
+
+	getDesirability = `SELECT COALESCE((SELECT desirability FROM UserScores WHERE user_id=?), ` + defaultDesirability + `)`
+
+	upsertDesirability = `INSERT INTO UserScores (user_id, desirability) VALUES (?,?)
+			ON DUPLICATE KEY UPDATE desirability=VALUES(desirability)`
+
+	insertSwipeEvent = `INSERT INTO SwipeEvents (user_id, target_user_id, swipe_action) VALUES (?,?,?)`
+
+	// SKIP LOCKED lets several workers share the events without applying one twice
+	getPendingSwipeEvents = `SELECT event_id, user_id, target_user_id, swipe_action
+			FROM SwipeEvents
+			WHERE processed_at IS NULL
+			ORDER BY event_id
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED`
+
+	markSwipeEventProcessed = `UPDATE SwipeEvents SET processed_at=CURRENT_TIMESTAMP WHERE event_id=?`
+
+	// premium comes from subscriptions, see the entitlement service
+	getUserTier = `SELECT IF(trial_ends_at > CURRENT_TIMESTAMP, 'trial', 'free') FROM Users WHERE user_id=?`
+
+	getUserLikeCount = `SELECT COUNT(1) AS total FROM Swipes
+			WHERE user_id=? AND swipe_action='like' AND actioned_at >= ? AND actioned_at < ?`
+
+	getQuotaOverride = `SELECT daily_limit FROM QuotaOverrides
+			WHERE user_id=? AND action=? AND expires_at > CURRENT_TIMESTAMP
+			ORDER BY created_at DESC, override_id DESC
+			LIMIT 1`
 
-	getUserUpgradeStatus = `SELECT is_upgraded FROM Users WHERE user_id=?`
+	insertQuotaOverride = `INSERT INTO QuotaOverrides (user_id, action, daily_limit, expires_at) VALUES (?,?,?,?)`
 
-	getUserViewCount = `SELECT COUNT(1) AS total FROM Swipes WHERE user_id=? AND DATE(created_at) = CURDATE()`
+	getUserSuperLikeCount = `SELECT COUNT(1) AS total FROM Swipes
+			WHERE user_id=? AND swipe_action='superlike' AND actioned_at >= ? AND actioned_at < ?`
 
-	getTargetUser = `SELECT u.user_id, u.username, u.email, u.phone_number, u.full_name, u.gender, u.date_of_birth, u.profile_picture
+	rankingProfileColumns = `u.user_id, u.username, u.email, u.phone_number, u.full_name, u.gender, u.date_of_birth, u.profile_picture,
+			u.bio, u.latitude, u.longitude, u.last_active_at,
+			(SELECT GROUP_CONCAT(ui.interest) FROM UserInterests ui WHERE ui.user_id = u.user_id) AS interests`
+
+	getRankingProfile = `SELECT ` + rankingProfileColumns + `
+			FROM Users u
+			WHERE u.user_id = ?`
+
+	getCandidates = `SELECT ` + rankingProfileColumns + `,
+			EXISTS (
+				SELECT 1
+				FROM Swipes sl
+				WHERE sl.user_id = u.user_id
+					AND sl.target_user_id = ?
+					AND sl.swipe_action = 'superlike'
+			) AS super_liked,
+			EXISTS (
+				SELECT 1
+				FROM Boosts b
+				WHERE b.user_id = u.user_id
+					AND b.expires_at > CURRENT_TIMESTAMP
+			) AS boosted,
+			u.incognito AND NOT EXISTS (
+				SELECT 1
+				FROM Swipes il
+				WHERE il.user_id = u.user_id
+					AND il.target_user_id = ?
+					AND il.swipe_action IN ('like', 'superlike')
+			) AS incognito,
+			ABS(COALESCE(us.desirability, ` + defaultDesirability + `) - COALESCE(vs.desirability, ` + defaultDesirability + `)) <= ? AS in_band
 			FROM Users u
-			WHERE u.user_id NOT IN (
-				SELECT s.target_user_id
+			LEFT JOIN UserScores us ON us.user_id = u.user_id
+			LEFT JOIN UserScores vs ON vs.user_id = ?
+			WHERE NOT EXISTS (
+				SELECT 1
 				FROM Swipes s
 				WHERE s.user_id = ?
-					AND DATE(s.created_at) = CURDATE()
+					AND s.target_user_id = u.user_id
+					AND (
+						s.swipe_action IN ('like', 'superlike')
+						OR (s.swipe_action = 'pass' AND s.actioned_at >= ?)
+						OR (s.swipe_action IS NULL AND s.created_at >= ?)
+					)
 			)
+			AND u.user_id != ?
 			AND u.gender != (
 				SELECT gender
 				FROM Users
 				WHERE user_id = ?
 			)
-			LIMIT 1`
+			AND NOT EXISTS (
+				SELECT 1
+				FROM Matches m
+				WHERE (m.user_one_id = ? AND m.user_two_id = u.user_id)
+					OR (m.user_two_id = ? AND m.user_one_id = u.user_id)
+			)
+			AND NOT EXISTS (
+				SELECT 1
+				FROM Blocks bl
+				WHERE (bl.blocker_id = ? AND bl.blocked_id = u.user_id)
+					OR (bl.blocked_id = ? AND bl.blocker_id = u.user_id)
+			)
+			AND ` + activeUser + `
+			ORDER BY super_liked DESC, incognito, boosted DESC, in_band DESC, u.last_active_at IS NULL, u.last_active_at DESC
+			LIMIT ?`
+
+	updateLastActive = `UPDATE Users SET last_active_at=CURRENT_TIMESTAMP WHERE user_id=?`
+
+	insertUserInterest = `INSERT IGNORE INTO UserInterests (user_id, interest) VALUES (?,?)`
 
 	insertSwipeLog = `INSERT INTO Swipes (user_id, target_user_id) 
 						VALUES(?,?)`
 
-	updateSwipeLog = `UPDATE Swipes SET swipe_action=? WHERE user_id=? AND target_user_id=?`
+	updateSwipeLog = `UPDATE Swipes SET actioned_at=IF(swipe_action <=> ?, actioned_at, CURRENT_TIMESTAMP), swipe_action=?,
+				client_swipe_id=COALESCE(client_swipe_id, ?)
+			WHERE swipe_id=?`
+
+	getLatestSwipe = `SELECT swipe_id, COALESCE(swipe_action, '') FROM Swipes
+			WHERE user_id=? AND target_user_id=?
+			ORDER BY swipe_id DESC
+			LIMIT 1
+			FOR UPDATE`
+
+	getClientSwipe = `SELECT target_user_id, COALESCE(swipe_action, '') FROM Swipes
+			WHERE user_id=? AND client_swipe_id=?
+			FOR UPDATE`
+
+	lockSwipePair = `SELECT user_id FROM Users WHERE user_id IN (?,?) ORDER BY user_id FOR UPDATE`
+
+	lockUsers = `SELECT user_id FROM Users WHERE user_id IN (%s) ORDER BY user_id FOR UPDATE`
+
+	getMutualLike = `SELECT COUNT(1) FROM Swipes WHERE user_id=? AND target_user_id=? AND swipe_action IN ('like', 'superlike') LOCK IN SHARE MODE`
+
+	getPairMatch = `SELECT match_id, unmatched_at IS NOT NULL FROM Matches WHERE user_one_id=? AND user_two_id=? FOR UPDATE`
+
+	insertMatch = `INSERT INTO Matches (user_one_id, user_two_id) VALUES (?,?)`
+
+	insertMatchEvent = `INSERT INTO MatchEvents (match_id, actor_user_id, event_type) VALUES (?,?,?)`
+
+	unmatch = `UPDATE Matches SET unmatched_by=?, unmatched_at=CURRENT_TIMESTAMP
+			WHERE match_id=? AND (user_one_id=? OR user_two_id=?) AND unmatched_at IS NULL`
+
+	getMatches = `SELECT m.match_id, m.created_at, u.user_id, u.username, u.email, u.phone_number, u.full_name, u.gender, u.date_of_birth, u.profile_picture
+			FROM Matches m
+			JOIN Users u ON u.user_id = IF(m.user_one_id = ?, m.user_two_id, m.user_one_id)
+			WHERE (m.user_one_id = ? OR m.user_two_id = ?)
+				AND m.unmatched_at IS NULL
+			ORDER BY m.created_at DESC, m.match_id DESC
+			LIMIT ? OFFSET ?`
+
+	countMatches = `SELECT COUNT(1) FROM Matches WHERE (user_one_id=? OR user_two_id=?) AND unmatched_at IS NULL`
+
+	countBlockBetween = `SELECT COUNT(1) FROM Blocks
+			WHERE (blocker_id=? AND blocked_id=?) OR (blocker_id=? AND blocked_id=?)`
+
+	insertBlock = `INSERT IGNORE INTO Blocks (blocker_id, blocked_id) VALUES (?,?)`
+
+	insertReport = `INSERT INTO Reports (reporter_id, reported_id, reason, details) VALUES (?,?,?,?)`
+
+	reportColumns = `r.report_id, r.reporter_id, r.reported_id, r.reason, COALESCE(r.details, ''), r.status, r.created_at, r.resolved_at,
+			(SELECT COUNT(1) FROM Reports rc WHERE rc.reported_id = r.reported_id) AS report_count`
+
+	getReports = `SELECT ` + reportColumns + `
+			FROM Reports r
+			WHERE (? = '' OR r.status = ?)
+			ORDER BY r.created_at, r.report_id
+			LIMIT ? OFFSET ?`
+
+	countReports = `SELECT COUNT(1) FROM Reports WHERE (? = '' OR status = ?)`
+
+	getReportForUpdate = `SELECT ` + reportColumns + `
+			FROM Reports r
+			WHERE r.report_id = ?
+			FOR UPDATE`
+
+	resolveReport = `UPDATE Reports SET status='resolved', resolved_at=CURRENT_TIMESTAMP WHERE report_id=?`
+
+	insertModerationAction = `INSERT INTO ModerationActions (report_id, user_id, action, note, expires_at) VALUES (?,?,?,?,?)`
+
+	suspendUser = `UPDATE Users SET suspended_until=GREATEST(COALESCE(suspended_until, ?), ?) WHERE user_id=?`
+
+	banUser = `UPDATE Users SET banned_at=COALESCE(banned_at, CURRENT_TIMESTAMP) WHERE user_id=?`
+
+	insertNotification = `INSERT INTO Notifications (user_id, notification_type, message) VALUES (?,?,?)`
+
+	getNotifications = `SELECT notification_id, notification_type, message, created_at FROM Notifications
+			WHERE user_id=?
+			ORDER BY created_at DESC, notification_id DESC
+			LIMIT ? OFFSET ?`
+
+	countNotifications = `SELECT COUNT(1) FROM Notifications WHERE user_id=?`
 )
 
 func closeRows(rows *sql.Rows) {
@@ -55,25 +338,38 @@ func closeRows(rows *sql.Rows) {
 	}
 }
 
-func (r *minderRepositoryImpl) Register(ctx context.Context, req *minder_model.RegisterReq) (data int64, err error) {
-	stmt, err := r.DB.PrepareContext(ctx, insertUsers)
-	if err != nil {
-		log.Println(ctx, "[repository:minder] Preparing Register err", err)
-		return
-	}
-	defer stmt.Close()
-	result, err := stmt.ExecContext(ctx, req.Username, req.Email, req.PhoneNumber, req.Password, req.FullName, req.Gender, req.DateOfBirth, req.ProfilePicture)
-	if err != nil {
-		log.Println(ctx, "[repository:minder] Insert Register err ", err)
-		return
-	}
-
-	return result.RowsAffected()
+// Register creates the user with a trial running until trialEndsAt
+func (r *minderRepositoryImpl) Register(ctx context.Context, req *minder_model.RegisterReq, trialEndsAt time.Time) (data int64, err error) {
+	err = r.withTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, insertUsers, req.Username, req.Email, req.PhoneNumber, req.Password, req.FullName, req.Gender, req.DateOfBirth, req.ProfilePicture,
+			nullString(req.Bio), req.Latitude, req.Longitude, nullString(req.TimeZone), trialEndsAt)
+		if err != nil {
+			log.Println(ctx, "[repository:minder] Insert Register err ", err)
+			return err
+		}
+		data, err = result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		userId, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
 
+		for _, interest := range req.Interests {
+			_, err = tx.ExecContext(ctx, insertUserInterest, userId, interest)
+			if err != nil {
+				log.Println(ctx, "[repository:minder] Insert User Interest err ", err)
+				return err
+			}
+		}
+		return nil
+	})
+	return
 }
 
 func (r *minderRepositoryImpl) Login(ctx context.Context, req *minder_model.LoginReq) (data *minder_model.UserData, err error) {
-	stmt, err := r.DB.PrepareContext(ctx, getUsersLoginData)
+	stmt, err := r.conn(ctx).PrepareContext(ctx, getUsersLoginData)
 	if err != nil {
 		log.Println(ctx, "[repository:minder] Preparing Get User Login Data Count err", err)
 		return
@@ -106,39 +402,80 @@ func (r *minderRepositoryImpl) Login(ctx context.Context, req *minder_model.Logi
 		&userData.Gender,
 		&userData.DateOfBirth,
 		&userData.ProfilePicture,
-		&userData.IsUpgraded,
+		&userData.Restricted,
 	)
+	if err != nil {
+		return nil, err
+	}
+	if userData.Restricted {
+		return &userData, nil
+	}
+
+	_, err = r.conn(ctx).ExecContext(ctx, updateLastActive, userData.UserId)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Update Last Active err", err)
+	}
 	return &userData, err
 }
-func (r *minderRepositoryImpl) UpgradeAccount(ctx context.Context, id uint64) (data int64, err error) {
-	stmt, err := r.DB.PrepareContext(ctx, upgradeAccount)
+
+// GetUserTimeZone returns the user's IANA time zone, empty when the user never set one
+func (r *minderRepositoryImpl) GetUserTimeZone(ctx context.Context, id uint64) (timeZone string, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, getUserTimeZone)
 	if err != nil {
-		log.Println(ctx, "[repository:minder] Preparing Upgrade Account err", err)
+		log.Println(ctx, "[repository:minder] Preparing Get User Time Zone err", err)
 		return
 	}
 	defer stmt.Close()
-	result, err := stmt.ExecContext(ctx, id)
+	err = stmt.QueryRowContext(ctx, id).Scan(&timeZone)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Get User Time Zone err", err)
+	}
+	return
+}
+
+func (r *minderRepositoryImpl) UpdateTimeZone(ctx context.Context, id uint64, timeZone string) (data int64, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, updateTimeZone)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Preparing Update Time Zone err", err)
+		return
+	}
+	defer stmt.Close()
+	result, err := stmt.ExecContext(ctx, timeZone, id)
 	if err != nil {
-		log.Println(ctx, "[repository:minder] Upgrade Account err", err)
+		log.Println(ctx, "[repository:minder] Update Time Zone err", err)
 		return
 	}
-	data, err = result.RowsAffected()
+	return result.RowsAffected()
+}
+
+func (r *minderRepositoryImpl) UpdateIncognito(ctx context.Context, id uint64, enabled bool) (data int64, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, updateIncognito)
 	if err != nil {
-		log.Println(ctx, "[repository:minder] Get RowsEffected  err", err)
+		log.Println(ctx, "[repository:minder] Preparing Update Incognito err", err)
+		return
 	}
-	return
+	defer stmt.Close()
+	result, err := stmt.ExecContext(ctx, enabled, id)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Update Incognito err", err)
+		return
+	}
+	return result.RowsAffected()
 }
 
-func (r *minderRepositoryImpl) GetUserUpgradeStatus(ctx context.Context, id uint64) (data bool, err error) {
-	stmt, err := r.DB.PrepareContext(ctx, getUserUpgradeStatus)
+func (r *minderRepositoryImpl) GetUserViewCount(ctx context.Context, id uint64, day minder_model.DayRange) (total *int64, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, getUserViewCount)
 	if err != nil {
-		log.Println(ctx, "[repository:minder] Preparing Get User Upgrade Status err", err)
+		log.Println(ctx, "[repository:minder] Preparing Get User View Count err", err)
 		return
 	}
 	defer stmt.Close()
-	rows, err := stmt.QueryContext(ctx, id)
+	rows, err := stmt.QueryContext(ctx, id, day.Start, day.End)
 	if err != nil {
-		log.Println(ctx, "[repository:minder] User Upgrade Status err", err)
+		log.Println(ctx, "[repository:minder] User View Count err", err)
 		return
 	}
 
@@ -148,28 +485,24 @@ func (r *minderRepositoryImpl) GetUserUpgradeStatus(ctx context.Context, id uint
 			log.Println(err)
 		}
 	}()
-
 	if !rows.Next() {
-		return false, fmt.Errorf("no data found")
+		return nil, fmt.Errorf("no data found")
 	}
-
 	err = rows.Scan(
-		&data,
+		&total,
 	)
-
 	return
 }
-
-func (r *minderRepositoryImpl) GetUserViewCount(ctx context.Context, id uint64) (total *int64, err error) {
-	stmt, err := r.DB.PrepareContext(ctx, getUserViewCount)
+func (r *minderRepositoryImpl) GetRankingProfile(ctx context.Context, id uint64) (data *minder_model.RankingProfile, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, getRankingProfile)
 	if err != nil {
-		log.Println(ctx, "[repository:minder] Preparing Get User View Count err", err)
+		log.Println(ctx, "[repository:minder] Preparing Get Ranking Profile err", err)
 		return
 	}
 	defer stmt.Close()
 	rows, err := stmt.QueryContext(ctx, id)
 	if err != nil {
-		log.Println(ctx, "[repository:minder] User View Count err", err)
+		log.Println(ctx, "[repository:minder] Get Ranking Profile err", err)
 		return
 	}
 
@@ -182,21 +515,21 @@ func (r *minderRepositoryImpl) GetUserViewCount(ctx context.Context, id uint64)
 	if !rows.Next() {
 		return nil, fmt.Errorf("no data found")
 	}
-	err = rows.Scan(
-		&total,
-	)
-	return
+
+	return scanRankingProfile(rows)
 }
-func (r *minderRepositoryImpl) GetTargetUser(ctx context.Context, id uint64) (data *minder_model.TargetUserData, err error) {
-	stmt, err := r.DB.PrepareContext(ctx, getTargetUser)
+
+// GetCandidates returns up to limit users the given user may be shown, applying the exclusion rules
+func (r *minderRepositoryImpl) GetCandidates(ctx context.Context, id uint64, rules minder_model.DiscoveryRules, limit int) (data []*minder_model.RankingProfile, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, getCandidates)
 	if err != nil {
-		log.Println(ctx, "[repository:minder] Preparing Get Targer User Data err", err)
+		log.Println(ctx, "[repository:minder] Preparing Get Candidates err", err)
 		return
 	}
 	defer stmt.Close()
-	rows, err := stmt.QueryContext(ctx, id, id)
+	rows, err := stmt.QueryContext(ctx, id, id, rules.ScoreBand, id, id, rules.PassedSince, rules.ViewedSince, id, id, id, id, id, id, limit)
 	if err != nil {
-		log.Println(ctx, "[repository:minder] Get Target User Data err", err)
+		log.Println(ctx, "[repository:minder] Get Candidates err", err)
 		return
 	}
 
@@ -206,30 +539,75 @@ func (r *minderRepositoryImpl) GetTargetUser(ctx context.Context, id uint64) (da
 			log.Println(err)
 		}
 	}()
-	if !rows.Next() {
-		return nil, fmt.Errorf("no data found")
+
+	data = []*minder_model.RankingProfile{}
+	for rows.Next() {
+		var superLiked, boosted, incognito, inBand bool
+		candidate, err := scanRankingProfile(rows, &superLiked, &boosted, &incognito, &inBand)
+		if err != nil {
+			return nil, err
+		}
+		candidate.SuperLiked = superLiked
+		candidate.Boosted = boosted
+		candidate.Incognito = incognito
+		data = append(data, candidate)
 	}
-	var userData minder_model.TargetUserData
-	err = rows.Scan(
-		&userData.UserId,
-		&userData.Username,
-		&userData.Email,
-		&userData.PhoneNumber,
-		&userData.FullName,
-		&userData.Gender,
-		&userData.DateOfBirth,
-		&userData.ProfilePicture,
+
+	return
+}
+
+// scanRankingProfile scans the ranking profile columns followed by any extra columns of the query
+func scanRankingProfile(rows *sql.Rows, extra ...any) (*minder_model.RankingProfile, error) {
+	var (
+		profile      minder_model.RankingProfile
+		bio          sql.NullString
+		latitude     sql.NullFloat64
+		longitude    sql.NullFloat64
+		lastActiveAt sql.NullTime
+		interests    sql.NullString
 	)
+	dest := []any{
+		&profile.UserId,
+		&profile.Username,
+		&profile.Email,
+		&profile.PhoneNumber,
+		&profile.FullName,
+		&profile.Gender,
+		&profile.DateOfBirth,
+		&profile.ProfilePicture,
+		&bio,
+		&latitude,
+		&longitude,
+		&lastActiveAt,
+		&interests,
+	}
+	err := rows.Scan(append(dest, extra...)...)
 	if err != nil {
 		log.Println("[repository:minder] Error scanning row:", err)
 		return nil, err
 	}
 
-	return &userData, err
+	profile.Bio = bio.String
+	if latitude.Valid && longitude.Valid {
+		profile.Latitude = &latitude.Float64
+		profile.Longitude = &longitude.Float64
+	}
+	if lastActiveAt.Valid {
+		profile.LastActiveAt = &lastActiveAt.Time
+	}
+	if interests.Valid && interests.String != "" {
+		profile.Interests = strings.Split(interests.String, ",")
+	}
+
+	return &profile, nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
 }
 
 func (r *minderRepositoryImpl) InsertSwipe(ctx context.Context, id uint64, targetId int64) (data int64, err error) {
-	stmt, err := r.DB.PrepareContext(ctx, insertSwipeLog)
+	stmt, err := r.conn(ctx).PrepareContext(ctx, insertSwipeLog)
 	if err != nil {
 		log.Println(ctx, "[repository:minder] Preparing Swipe err", err)
 		return
@@ -244,18 +622,991 @@ func (r *minderRepositoryImpl) InsertSwipe(ctx context.Context, id uint64, targe
 	return result.RowsAffected()
 }
 
-func (r *minderRepositoryImpl) UpdateSwipe(ctx context.Context, req *minder_model.SwipeReq) (data int64, err error) {
-	stmt, err := r.DB.PrepareContext(ctx, updateSwipeLog)
+// Swipe records the swipe action and, when both users liked each other, creates their match.
+// Both user rows are locked in id order so two users liking each other at the same time
+// always see each other's like and end up with a single match.
+func (r *minderRepositoryImpl) Swipe(ctx context.Context, req *minder_model.SwipeReq) (data *minder_model.SwipeRes, err error) {
+	data = &minder_model.SwipeRes{}
+	err = r.withTx(ctx, func(tx *sql.Tx) error {
+		return r.swipeTx(ctx, tx, req, data)
+	})
 	if err != nil {
-		log.Println(ctx, "[repository:minder] Preparing Swipe err", err)
+		return nil, err
+	}
+
+	return
+}
+
+// GetSwipeAction returns the action already recorded for the swipe, empty when the card is still
+// unanswered. A swipe with a client swipe id is looked up by that id, otherwise the latest card
+// served for the pair is used. ErrNotFound means the target was never served to the user.
+func (r *minderRepositoryImpl) GetSwipeAction(ctx context.Context, req *minder_model.SwipeReq) (action string, err error) {
+	err = r.withTx(ctx, func(tx *sql.Tx) error {
+		replayed, err := r.clientSwipeTx(ctx, tx, req)
+		if err != nil || replayed {
+			action = req.Action
+			return err
+		}
+
+		_, action, err = r.latestSwipeTx(ctx, tx, req)
+		return err
+	})
+	return
+}
+
+// clientSwipeTx tells whether the client swipe id was already recorded for this very swipe
+func (r *minderRepositoryImpl) clientSwipeTx(ctx context.Context, tx *sql.Tx, req *minder_model.SwipeReq) (replayed bool, err error) {
+	if req.ClientSwipeId == "" {
+		return false, nil
+	}
+
+	var targetId int64
+	var action string
+	err = tx.QueryRowContext(ctx, getClientSwipe, req.Id, req.ClientSwipeId).Scan(&targetId, &action)
+	switch {
+	case err == sql.ErrNoRows:
+		return false, nil
+	case err != nil:
+		log.Println(ctx, "[repository:minder] Get Client Swipe err ", err)
+		return false, err
+	case targetId != req.TargetId || action != req.Action:
+		return false, ErrSwipeConflict
+	}
+	return true, nil
+}
+
+func (r *minderRepositoryImpl) latestSwipeTx(ctx context.Context, tx *sql.Tx, req *minder_model.SwipeReq) (swipeId int64, action string, err error) {
+	err = tx.QueryRowContext(ctx, getLatestSwipe, req.Id, req.TargetId).Scan(&swipeId, &action)
+	if err == sql.ErrNoRows {
+		return 0, "", ErrNotFound
+	}
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Get Latest Swipe err ", err)
+	}
+	return
+}
+
+// swipeTx answers the latest card served for the pair. Repeating the same swipe changes nothing and
+// returns the same result, while passing on a user one is matched with fails with ErrSwipeMatched.
+func (r *minderRepositoryImpl) swipeTx(ctx context.Context, tx *sql.Tx, req *minder_model.SwipeReq, data *minder_model.SwipeRes) error {
+	rows, err := tx.QueryContext(ctx, lockSwipePair, req.Id, req.TargetId)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Lock Swipe Pair err", err)
+		return err
+	}
+	closeRows(rows)
+
+	userOne, userTwo := req.Id, req.TargetId
+	if userOne > userTwo {
+		userOne, userTwo = userTwo, userOne
+	}
+
+	var blocked int64
+	err = tx.QueryRowContext(ctx, countBlockBetween, req.Id, req.TargetId, req.TargetId, req.Id).Scan(&blocked)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Count Block err ", err)
+		return err
+	}
+	if blocked > 0 {
+		return ErrNotFound
+	}
+
+	replayed, err := r.clientSwipeTx(ctx, tx, req)
+	if err != nil {
+		return err
+	}
+
+	if !replayed {
+		swipeId, previous, err := r.latestSwipeTx(ctx, tx, req)
+		if err != nil {
+			return err
+		}
+
+		if previous != req.Action && req.Action == minder_model.SwipeActionPass {
+			var matchId int64
+			var unmatched bool
+			err = tx.QueryRowContext(ctx, getPairMatch, userOne, userTwo).Scan(&matchId, &unmatched)
+			switch {
+			case err == nil && !unmatched:
+				return ErrSwipeMatched
+			case err != nil && err != sql.ErrNoRows:
+				log.Println(ctx, "[repository:minder] Get Pair Match err ", err)
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, updateSwipeLog, req.Action, req.Action, nullString(req.ClientSwipeId), swipeId)
+		if err != nil {
+			log.Println(ctx, "[repository:minder] Update Swipe err ", err)
+			return err
+		}
+	}
+
+	if req.Action == minder_model.SwipeActionPass {
+		return nil
+	}
+
+	var mutual int64
+	if err := tx.QueryRowContext(ctx, getMutualLike, req.TargetId, req.Id).Scan(&mutual); err != nil {
+		log.Println(ctx, "[repository:minder] Get Mutual Like err ", err)
+		return err
+	}
+	if mutual == 0 {
+		return nil
+	}
+
+	// An unmatched pair stays apart for good, while an active match is returned as is
+	// so repeating the same like gives the same answer.
+	var matchId int64
+	var unmatched bool
+	err = tx.QueryRowContext(ctx, getPairMatch, userOne, userTwo).Scan(&matchId, &unmatched)
+	switch {
+	case err == nil && unmatched:
+		return nil
+	case err == nil:
+		data.Matched = true
+		data.MatchId = matchId
+		return nil
+	case err != sql.ErrNoRows:
+		log.Println(ctx, "[repository:minder] Get Pair Match err ", err)
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, insertMatch, userOne, userTwo)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Insert Match err ", err)
+		return err
+	}
+	matchId, err = result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, insertMatchEvent, matchId, req.Id, minder_model.MatchEventMatched)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Insert Match Event err ", err)
+		return err
+	}
+
+	err = writePushOutbox(ctx, tx, req.Id, minder_model.PushTypeMatch, &minder_model.MatchStreamEvent{MatchId: matchId, UserId: req.TargetId})
+	if err != nil {
+		return err
+	}
+	err = writePushOutbox(ctx, tx, req.TargetId, minder_model.PushTypeMatch, &minder_model.MatchStreamEvent{MatchId: matchId, UserId: req.Id})
+	if err != nil {
+		return err
+	}
+
+	data.Matched = true
+	data.MatchId = matchId
+	return nil
+}
+
+// LikeBack likes a user who already liked the given user, which always creates their match
+func (r *minderRepositoryImpl) LikeBack(ctx context.Context, id uint64, likerId int64) (data *minder_model.SwipeRes, err error) {
+	data = &minder_model.SwipeRes{}
+	err = r.withTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, lockSwipePair, id, likerId)
+		if err != nil {
+			log.Println(ctx, "[repository:minder] Lock Swipe Pair err", err)
+			return err
+		}
+		closeRows(rows)
+
+		var pending int64
+		if err := tx.QueryRowContext(ctx, countPendingLike, likerId, id).Scan(&pending); err != nil {
+			log.Println(ctx, "[repository:minder] Count Pending Like err ", err)
+			return err
+		}
+		if pending == 0 {
+			return ErrNotFound
+		}
+
+		if _, err := tx.ExecContext(ctx, insertSwipeLog, id, likerId); err != nil {
+			log.Println(ctx, "[repository:minder] Insert Swipe err ", err)
+			return err
+		}
+
+		return r.swipeTx(ctx, tx, &minder_model.SwipeReq{
+			Id:       int64(id),
+			TargetId: likerId,
+			Action:   minder_model.SwipeActionLike,
+		}, data)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+func (r *minderRepositoryImpl) GetLikesReceived(ctx context.Context, id uint64, limit, offset int) (data []*minder_model.LikeReceivedData, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, getLikesReceived)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Preparing Get Likes Received err", err)
 		return
 	}
 	defer stmt.Close()
-	result, err := stmt.ExecContext(ctx, req.Action, req.Id, req.TargetId)
+	rows, err := stmt.QueryContext(ctx, id, limit, offset)
 	if err != nil {
-		log.Println(ctx, "[repository:minder] Insert Swipe err ", err)
+		log.Println(ctx, "[repository:minder] Get Likes Received err", err)
 		return
 	}
 
-	return result.RowsAffected()
+	defer func() {
+		closeRows(rows)
+		if err := rows.Err(); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	data = []*minder_model.LikeReceivedData{}
+	for rows.Next() {
+		like := &minder_model.LikeReceivedData{User: &minder_model.TargetUserData{}}
+		err = rows.Scan(
+			&like.LikedAt,
+			&like.User.SuperLiked,
+			&like.User.UserId,
+			&like.User.Username,
+			&like.User.Email,
+			&like.User.PhoneNumber,
+			&like.User.FullName,
+			&like.User.Gender,
+			&like.User.DateOfBirth,
+			&like.User.ProfilePicture,
+		)
+		if err != nil {
+			log.Println("[repository:minder] Error scanning row:", err)
+			return nil, err
+		}
+		data = append(data, like)
+	}
+
+	return
+}
+
+func (r *minderRepositoryImpl) CountLikesReceived(ctx context.Context, id uint64) (total int64, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, countLikesReceived)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Preparing Count Likes Received err", err)
+		return
+	}
+	defer stmt.Close()
+	err = stmt.QueryRowContext(ctx, id).Scan(&total)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Count Likes Received err", err)
+	}
+	return
+}
+
+func (r *minderRepositoryImpl) GetMatches(ctx context.Context, id uint64, limit, offset int) (data []*minder_model.MatchData, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, getMatches)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Preparing Get Matches err", err)
+		return
+	}
+	defer stmt.Close()
+	rows, err := stmt.QueryContext(ctx, id, id, id, limit, offset)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Get Matches err", err)
+		return
+	}
+
+	defer func() {
+		closeRows(rows)
+		if err := rows.Err(); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	data = []*minder_model.MatchData{}
+	for rows.Next() {
+		match := &minder_model.MatchData{User: &minder_model.TargetUserData{}}
+		err = rows.Scan(
+			&match.MatchId,
+			&match.MatchedAt,
+			&match.User.UserId,
+			&match.User.Username,
+			&match.User.Email,
+			&match.User.PhoneNumber,
+			&match.User.FullName,
+			&match.User.Gender,
+			&match.User.DateOfBirth,
+			&match.User.ProfilePicture,
+		)
+		if err != nil {
+			log.Println("[repository:minder] Error scanning row:", err)
+			return nil, err
+		}
+		data = append(data, match)
+	}
+
+	return
+}
+
+func (r *minderRepositoryImpl) CountMatches(ctx context.Context, id uint64) (total int64, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, countMatches)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Preparing Count Matches err", err)
+		return
+	}
+	defer stmt.Close()
+	err = stmt.QueryRowContext(ctx, id, id).Scan(&total)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Count Matches err", err)
+	}
+	return
+}
+
+// Unmatch ends an active match on behalf of one of its users and records who ended it.
+func (r *minderRepositoryImpl) Unmatch(ctx context.Context, matchId, userId uint64) (data int64, err error) {
+	err = r.withTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, unmatch, userId, matchId, userId, userId)
+		if err != nil {
+			log.Println(ctx, "[repository:minder] Unmatch err ", err)
+			return err
+		}
+		data, err = result.RowsAffected()
+		if err != nil || data == 0 {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, insertMatchEvent, matchId, userId, minder_model.MatchEventUnmatched)
+		if err != nil {
+			log.Println(ctx, "[repository:minder] Insert Match Event err ", err)
+		}
+		return err
+	})
+	return
+}
+
+func (r *minderRepositoryImpl) GetUserReservedCount(ctx context.Context, id uint64, day minder_model.DayRange) (total int64, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, getUserReservedCount)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Preparing Get User Reserved Count err", err)
+		return
+	}
+	defer stmt.Close()
+	err = stmt.QueryRowContext(ctx, id, day.Start, day.End).Scan(&total)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Get User Reserved Count err", err)
+	}
+	return
+}
+
+// GetReservedCards returns the day's reserved but unanswered cards served after the given swipe id
+func (r *minderRepositoryImpl) GetReservedCards(ctx context.Context, id uint64, day minder_model.DayRange, afterSwipeId int64, limit int) (data []*minder_model.DeckCard, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, getReservedCards)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Preparing Get Reserved Cards err", err)
+		return
+	}
+	defer stmt.Close()
+	rows, err := stmt.QueryContext(ctx, id, day.Start, day.End, afterSwipeId, limit)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Get Reserved Cards err", err)
+		return
+	}
+
+	defer func() {
+		closeRows(rows)
+		if err := rows.Err(); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	data = []*minder_model.DeckCard{}
+	for rows.Next() {
+		var card minder_model.DeckCard
+		err = rows.Scan(
+			&card.SwipeId,
+			&card.UserId,
+			&card.Username,
+			&card.Email,
+			&card.PhoneNumber,
+			&card.FullName,
+			&card.Gender,
+			&card.DateOfBirth,
+			&card.ProfilePicture,
+			&card.SuperLiked,
+		)
+		if err != nil {
+			log.Println("[repository:minder] Error scanning row:", err)
+			return nil, err
+		}
+		data = append(data, &card)
+	}
+
+	return
+}
+
+// ReserveSwipe serves a card without counting it as a view until the user swipes on it
+func (r *minderRepositoryImpl) ReserveSwipe(ctx context.Context, id uint64, targetId int64) (swipeId int64, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, insertReservedSwipe)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Preparing Reserve Swipe err", err)
+		return
+	}
+	defer stmt.Close()
+	result, err := stmt.ExecContext(ctx, id, targetId)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Reserve Swipe err ", err)
+		return
+	}
+
+	return result.LastInsertId()
+}
+
+func (r *minderRepositoryImpl) GetUserRewindCount(ctx context.Context, id uint64, day minder_model.DayRange) (total int64, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, getUserRewindCount)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Preparing Get User Rewind Count err", err)
+		return
+	}
+	defer stmt.Close()
+	err = stmt.QueryRowContext(ctx, id, day.Start, day.End).Scan(&total)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Get User Rewind Count err", err)
+	}
+	return
+}
+
+// Rewind puts the user's last answered swipe back at the front of the deck.
+// A like that already created a match is never rewound.
+func (r *minderRepositoryImpl) Rewind(ctx context.Context, id uint64) (targetId int64, err error) {
+	err = r.withTx(ctx, func(tx *sql.Tx) error {
+		var swipeId int64
+		var action string
+		err := tx.QueryRowContext(ctx, getLastActionedSwipe, id).Scan(&swipeId, &targetId, &action)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			log.Println(ctx, "[repository:minder] Get Last Actioned Swipe err ", err)
+			return err
+		}
+
+		if action != minder_model.SwipeActionPass {
+			userOne, userTwo := int64(id), targetId
+			if userOne > userTwo {
+				userOne, userTwo = userTwo, userOne
+			}
+			var matches int64
+			if err := tx.QueryRowContext(ctx, countPairMatch, userOne, userTwo).Scan(&matches); err != nil {
+				log.Println(ctx, "[repository:minder] Count Pair Match err ", err)
+				return err
+			}
+			if matches > 0 {
+				return ErrSwipeMatched
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, requeueSwipe, swipeId); err != nil {
+			log.Println(ctx, "[repository:minder] Requeue Swipe err ", err)
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, insertRewind, id, swipeId, action); err != nil {
+			log.Println(ctx, "[repository:minder] Insert Rewind err ", err)
+			return err
+		}
+		return nil
+	})
+	return
+}
+
+func (r *minderRepositoryImpl) GetUserSuperLikeCount(ctx context.Context, id uint64, day minder_model.DayRange) (total int64, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, getUserSuperLikeCount)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Preparing Get User Super Like Count err", err)
+		return
+	}
+	defer stmt.Close()
+	err = stmt.QueryRowContext(ctx, id, day.Start, day.End).Scan(&total)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Get User Super Like Count err", err)
+	}
+	return
+}
+
+// GetActiveBoost returns the user's running boost, or ErrNotFound when none is running
+func (r *minderRepositoryImpl) GetActiveBoost(ctx context.Context, id uint64) (data *minder_model.BoostData, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, getActiveBoost)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Preparing Get Active Boost err", err)
+		return
+	}
+	defer stmt.Close()
+
+	data = &minder_model.BoostData{}
+	err = stmt.QueryRowContext(ctx, id).Scan(&data.BoostId, &data.StartedAt, &data.ExpiresAt, &data.Impressions)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Get Active Boost err", err)
+		return nil, err
+	}
+	return
+}
+
+func (r *minderRepositoryImpl) GetUserBoostCount(ctx context.Context, id uint64, day minder_model.DayRange) (total int64, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, getUserBoostCount)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Preparing Get User Boost Count err", err)
+		return
+	}
+	defer stmt.Close()
+	err = stmt.QueryRowContext(ctx, id, day.Start, day.End).Scan(&total)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Get User Boost Count err", err)
+	}
+	return
+}
+
+func (r *minderRepositoryImpl) InsertBoost(ctx context.Context, id uint64, minutes int64) (data int64, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, insertBoost)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Preparing Insert Boost err", err)
+		return
+	}
+	defer stmt.Close()
+	result, err := stmt.ExecContext(ctx, id, minutes)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Insert Boost err ", err)
+		return
+	}
+
+	return result.LastInsertId()
+}
+
+func (r *minderRepositoryImpl) IncrementBoostImpressions(ctx context.Context, id int64) (data int64, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, incrementBoostImpressions)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Preparing Increment Boost Impressions err", err)
+		return
+	}
+	defer stmt.Close()
+	result, err := stmt.ExecContext(ctx, id)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Increment Boost Impressions err ", err)
+		return
+	}
+
+	return result.RowsAffected()
+}
+
+func (r *minderRepositoryImpl) GetDesirability(ctx context.Context, id int64) (score float64, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, getDesirability)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Preparing Get Desirability err", err)
+		return
+	}
+	defer stmt.Close()
+	err = stmt.QueryRowContext(ctx, id).Scan(&score)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Get Desirability err", err)
+	}
+	return
+}
+
+func (r *minderRepositoryImpl) UpdateDesirability(ctx context.Context, id int64, score float64) (data int64, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, upsertDesirability)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Preparing Update Desirability err", err)
+		return
+	}
+	defer stmt.Close()
+	result, err := stmt.ExecContext(ctx, id, score)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Update Desirability err ", err)
+		return
+	}
+
+	return result.RowsAffected()
+}
+
+func (r *minderRepositoryImpl) InsertSwipeEvent(ctx context.Context, event *minder_model.SwipeEvent) (data int64, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, insertSwipeEvent)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Preparing Insert Swipe Event err", err)
+		return
+	}
+	defer stmt.Close()
+	result, err := stmt.ExecContext(ctx, event.UserId, event.TargetId, event.Action)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Insert Swipe Event err ", err)
+		return
+	}
+
+	return result.LastInsertId()
+}
+
+// GetPendingSwipeEvents locks up to limit unprocessed swipe events in order until the surrounding
+// transaction ends, events already locked by another worker are skipped
+func (r *minderRepositoryImpl) GetPendingSwipeEvents(ctx context.Context, limit int) (data []*minder_model.SwipeEvent, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, getPendingSwipeEvents)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Preparing Get Pending Swipe Events err", err)
+		return
+	}
+	defer stmt.Close()
+	rows, err := stmt.QueryContext(ctx, limit)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Get Pending Swipe Events err", err)
+		return
+	}
+
+	defer func() {
+		closeRows(rows)
+		if err := rows.Err(); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	data = []*minder_model.SwipeEvent{}
+	for rows.Next() {
+		var event minder_model.SwipeEvent
+		err = rows.Scan(&event.EventId, &event.UserId, &event.TargetId, &event.Action)
+		if err != nil {
+			log.Println("[repository:minder] Error scanning row:", err)
+			return nil, err
+		}
+		data = append(data, &event)
+	}
+
+	return
+}
+
+func (r *minderRepositoryImpl) MarkSwipeEventProcessed(ctx context.Context, eventId int64) (data int64, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, markSwipeEventProcessed)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Preparing Mark Swipe Event Processed err", err)
+		return
+	}
+	defer stmt.Close()
+	result, err := stmt.ExecContext(ctx, eventId)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Mark Swipe Event Processed err ", err)
+		return
+	}
+
+	return result.RowsAffected()
+}
+
+// GetUserTier returns trial while the user's trial runs and free otherwise
+func (r *minderRepositoryImpl) GetUserTier(ctx context.Context, id uint64) (tier string, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, getUserTier)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Preparing Get User Tier err", err)
+		return
+	}
+	defer stmt.Close()
+	err = stmt.QueryRowContext(ctx, id).Scan(&tier)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Get User Tier err", err)
+	}
+	return
+}
+
+func (r *minderRepositoryImpl) GetUserLikeCount(ctx context.Context, id uint64, day minder_model.DayRange) (total int64, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, getUserLikeCount)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Preparing Get User Like Count err", err)
+		return
+	}
+	defer stmt.Close()
+	err = stmt.QueryRowContext(ctx, id, day.Start, day.End).Scan(&total)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Get User Like Count err", err)
+	}
+	return
+}
+
+// GetQuotaOverride returns the daily limit granted to the user for the action, or ErrNotFound when none is active
+func (r *minderRepositoryImpl) GetQuotaOverride(ctx context.Context, id uint64, action string) (limit int64, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, getQuotaOverride)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Preparing Get Quota Override err", err)
+		return
+	}
+	defer stmt.Close()
+	err = stmt.QueryRowContext(ctx, id, action).Scan(&limit)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Get Quota Override err", err)
+	}
+	return
+}
+
+func (r *minderRepositoryImpl) InsertQuotaOverride(ctx context.Context, req *minder_model.QuotaOverrideReq) (data int64, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, insertQuotaOverride)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Preparing Insert Quota Override err", err)
+		return
+	}
+	defer stmt.Close()
+	result, err := stmt.ExecContext(ctx, req.UserId, req.Action, req.Limit, req.ExpiresAt)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Insert Quota Override err ", err)
+		return
+	}
+
+	return result.LastInsertId()
+}
+
+// Block hides both users from each other for good and ends their active match.
+// It returns ErrNotFound when either user does not exist.
+func (r *minderRepositoryImpl) Block(ctx context.Context, blockerId, blockedId uint64) (data int64, err error) {
+	err = r.withTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, lockSwipePair, blockerId, blockedId)
+		if err != nil {
+			log.Println(ctx, "[repository:minder] Lock Swipe Pair err", err)
+			return err
+		}
+		locked := 0
+		for rows.Next() {
+			locked++
+		}
+		closeRows(rows)
+		if locked < 2 {
+			return ErrNotFound
+		}
+
+		result, err := tx.ExecContext(ctx, insertBlock, blockerId, blockedId)
+		if err != nil {
+			log.Println(ctx, "[repository:minder] Insert Block err ", err)
+			return err
+		}
+		data, err = result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		userOne, userTwo := min(blockerId, blockedId), max(blockerId, blockedId)
+		var matchId int64
+		var unmatched bool
+		err = tx.QueryRowContext(ctx, getPairMatch, userOne, userTwo).Scan(&matchId, &unmatched)
+		switch {
+		case err == sql.ErrNoRows || (err == nil && unmatched):
+			return nil
+		case err != nil:
+			log.Println(ctx, "[repository:minder] Get Pair Match err ", err)
+			return err
+		}
+
+		if _, err = tx.ExecContext(ctx, unmatch, blockerId, matchId, blockerId, blockerId); err != nil {
+			log.Println(ctx, "[repository:minder] Unmatch err ", err)
+			return err
+		}
+		_, err = tx.ExecContext(ctx, insertMatchEvent, matchId, blockerId, minder_model.MatchEventUnmatched)
+		if err != nil {
+			log.Println(ctx, "[repository:minder] Insert Match Event err ", err)
+		}
+		return err
+	})
+	return
+}
+
+func (r *minderRepositoryImpl) InsertReport(ctx context.Context, reportedId uint64, req *minder_model.ReportReq) (reportId int64, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, insertReport)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Preparing Insert Report err", err)
+		return
+	}
+	defer stmt.Close()
+	result, err := stmt.ExecContext(ctx, req.UserId, reportedId, req.Reason, nullString(req.Details))
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Insert Report err ", err)
+		return
+	}
+	return result.LastInsertId()
+}
+
+func scanReport(scanner interface{ Scan(dest ...any) error }) (*minder_model.ReportData, error) {
+	var report minder_model.ReportData
+	err := scanner.Scan(
+		&report.ReportId,
+		&report.ReporterId,
+		&report.ReportedId,
+		&report.Reason,
+		&report.Details,
+		&report.Status,
+		&report.CreatedAt,
+		&report.ResolvedAt,
+		&report.ReportCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// GetReports returns the moderation queue oldest first, an empty status lists every report
+func (r *minderRepositoryImpl) GetReports(ctx context.Context, status string, limit, offset int) (data []*minder_model.ReportData, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, getReports)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Preparing Get Reports err", err)
+		return
+	}
+	defer stmt.Close()
+	rows, err := stmt.QueryContext(ctx, status, status, limit, offset)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Get Reports err", err)
+		return
+	}
+
+	defer func() {
+		closeRows(rows)
+		if err := rows.Err(); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	data = []*minder_model.ReportData{}
+	for rows.Next() {
+		report, err := scanReport(rows)
+		if err != nil {
+			log.Println("[repository:minder] Error scanning row:", err)
+			return nil, err
+		}
+		data = append(data, report)
+	}
+
+	return
+}
+
+func (r *minderRepositoryImpl) CountReports(ctx context.Context, status string) (total int64, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, countReports)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Preparing Count Reports err", err)
+		return
+	}
+	defer stmt.Close()
+	err = stmt.QueryRowContext(ctx, status, status).Scan(&total)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Count Reports err", err)
+	}
+	return
+}
+
+// ResolveReport closes an open report and applies the moderator's action to the reported user.
+// A suspension never shortens one already running and a ban is permanent.
+func (r *minderRepositoryImpl) ResolveReport(ctx context.Context, reportId uint64, req *minder_model.ResolveReportReq, now time.Time) (data *minder_model.ReportData, err error) {
+	err = r.withTx(ctx, func(tx *sql.Tx) error {
+		report, err := scanReport(tx.QueryRowContext(ctx, getReportForUpdate, reportId))
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			log.Println(ctx, "[repository:minder] Get Report err ", err)
+			return err
+		}
+		if report.Status == minder_model.ReportStatusResolved {
+			return ErrReportResolved
+		}
+
+		var expiresAt *time.Time
+		switch req.Action {
+		case minder_model.ModerationSuspend:
+			until := now.Add(time.Duration(req.SuspendHours) * time.Hour)
+			expiresAt = &until
+			_, err = tx.ExecContext(ctx, suspendUser, until, until, report.ReportedId)
+		case minder_model.ModerationBan:
+			_, err = tx.ExecContext(ctx, banUser, report.ReportedId)
+		}
+		if err != nil {
+			log.Println(ctx, "[repository:minder] Apply Moderation err ", err)
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, insertModerationAction, reportId, report.ReportedId, req.Action, nullString(req.Note), expiresAt)
+		if err != nil {
+			log.Println(ctx, "[repository:minder] Insert Moderation Action err ", err)
+			return err
+		}
+
+		if _, err = tx.ExecContext(ctx, resolveReport, reportId); err != nil {
+			log.Println(ctx, "[repository:minder] Resolve Report err ", err)
+			return err
+		}
+
+		report.Status = minder_model.ReportStatusResolved
+		report.ResolvedAt = &now
+		data = report
+		return nil
+	})
+	return
+}
+
+func (r *minderRepositoryImpl) InsertNotification(ctx context.Context, id int64, notificationType, message string) (data int64, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, insertNotification)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Preparing Insert Notification err", err)
+		return
+	}
+	defer stmt.Close()
+	result, err := stmt.ExecContext(ctx, id, notificationType, message)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Insert Notification err ", err)
+		return
+	}
+	return result.RowsAffected()
+}
+
+func (r *minderRepositoryImpl) GetNotifications(ctx context.Context, id uint64, limit, offset int) (data []*minder_model.NotificationData, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, getNotifications)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Preparing Get Notifications err", err)
+		return
+	}
+	defer stmt.Close()
+	rows, err := stmt.QueryContext(ctx, id, limit, offset)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Get Notifications err", err)
+		return
+	}
+
+	defer func() {
+		closeRows(rows)
+		if err := rows.Err(); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	data = []*minder_model.NotificationData{}
+	for rows.Next() {
+		var notification minder_model.NotificationData
+		err = rows.Scan(
+			&notification.NotificationId,
+			&notification.Type,
+			&notification.Message,
+			&notification.CreatedAt,
+		)
+		if err != nil {
+			log.Println("[repository:minder] Error scanning row:", err)
+			return nil, err
+		}
+		data = append(data, &notification)
+	}
+
+	return
+}
+
+func (r *minderRepositoryImpl) CountNotifications(ctx context.Context, id uint64) (total int64, err error) {
+	stmt, err := r.conn(ctx).PrepareContext(ctx, countNotifications)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Preparing Count Notifications err", err)
+		return
+	}
+	defer stmt.Close()
+	err = stmt.QueryRowContext(ctx, id).Scan(&total)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Count Notifications err", err)
+	}
+	return
 }
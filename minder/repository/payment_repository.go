@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"context"
+
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+)
+
+type PaymentRepository interface {
+	InsertPayment(ctx context.Context, payment *minder_model.PaymentData) (data int64, err error)
+	SetPaymentCheckout(ctx context.Context, paymentId int64, checkoutURL string) (data int64, err error)
+	GetPayment(ctx context.Context, paymentId uint64) (data *minder_model.PaymentData, err error)
+	LockPaymentByOrder(ctx context.Context, orderId string) (data *minder_model.PaymentData, err error)
+	UpdatePaymentStatus(ctx context.Context, paymentId int64, status string, subscriptionId *int64) (data int64, err error)
+	CountPaidPayments(ctx context.Context, subscriptionId int64) (data int64, err error)
+	InsertPaymentEvent(ctx context.Context, eventId, orderId, eventType string) (inserted bool, err error)
+}
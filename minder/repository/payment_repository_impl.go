@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+)
+
+type paymentRepositoryImpl struct {
+	DB *sql.DB
+}
+
+func NewPaymentRepositoryImpl(db *sql.DB) PaymentRepository {
+	return &paymentRepositoryImpl{DB: db}
+}
+
+const (
+	paymentColumns = `payment_id, order_id, user_id, plan, amount, currency, status, subscription_id, COALESCE(checkout_url, ''), created_at`
+
+	insertPayment = `INSERT INTO Payments (order_id, user_id, plan, amount, currency) VALUES (?,?,?,?,?)`
+
+	setPaymentCheckout = `UPDATE Payments SET checkout_url=? WHERE payment_id=?`
+
+	getPayment = `SELECT ` + paymentColumns + ` FROM Payments WHERE payment_id=?`
+
+	lockPaymentByOrder = `SELECT ` + paymentColumns + ` FROM Payments WHERE order_id=? FOR UPDATE`
+
+	updatePaymentStatus = `UPDATE Payments SET status=?, subscription_id=COALESCE(?, subscription_id) WHERE payment_id=?`
+
+	countPaidPayments = `SELECT COUNT(*) FROM Payments WHERE subscription_id=? AND status='paid'`
+
+	insertPaymentEvent = `INSERT IGNORE INTO PaymentEvents (event_id, order_id, event_type) VALUES (?,?,?)`
+)
+
+func scanPayment(row interface{ Scan(dest ...any) error }) (*minder_model.PaymentData, error) {
+	var data minder_model.PaymentData
+	var subscriptionId sql.NullInt64
+	err := row.Scan(
+		&data.PaymentId,
+		&data.OrderId,
+		&data.UserId,
+		&data.Plan,
+		&data.Amount,
+		&data.Currency,
+		&data.Status,
+		&subscriptionId,
+		&data.CheckoutURL,
+		&data.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if subscriptionId.Valid {
+		data.SubscriptionId = &subscriptionId.Int64
+	}
+	return &data, nil
+}
+
+func (r *paymentRepositoryImpl) InsertPayment(ctx context.Context, payment *minder_model.PaymentData) (data int64, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, insertPayment)
+	if err != nil {
+		log.Println(ctx, "[repository:payment] Preparing Insert Payment err", err)
+		return
+	}
+	defer stmt.Close()
+	result, err := stmt.ExecContext(ctx, payment.OrderId, payment.UserId, payment.Plan, payment.Amount, payment.Currency)
+	if err != nil {
+		log.Println(ctx, "[repository:payment] Insert Payment err", err)
+		return
+	}
+	return result.LastInsertId()
+}
+
+func (r *paymentRepositoryImpl) SetPaymentCheckout(ctx context.Context, paymentId int64, checkoutURL string) (data int64, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, setPaymentCheckout)
+	if err != nil {
+		log.Println(ctx, "[repository:payment] Preparing Set Payment Checkout err", err)
+		return
+	}
+	defer stmt.Close()
+	result, err := stmt.ExecContext(ctx, checkoutURL, paymentId)
+	if err != nil {
+		log.Println(ctx, "[repository:payment] Set Payment Checkout err", err)
+		return
+	}
+	return result.RowsAffected()
+}
+
+func (r *paymentRepositoryImpl) GetPayment(ctx context.Context, paymentId uint64) (data *minder_model.PaymentData, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, getPayment)
+	if err != nil {
+		log.Println(ctx, "[repository:payment] Preparing Get Payment err", err)
+		return
+	}
+	defer stmt.Close()
+	data, err = scanPayment(stmt.QueryRowContext(ctx, paymentId))
+	if err != nil && err != ErrNotFound {
+		log.Println(ctx, "[repository:payment] Get Payment err", err)
+	}
+	return
+}
+
+// LockPaymentByOrder returns the order's payment locked until the surrounding transaction ends, or ErrNotFound
+func (r *paymentRepositoryImpl) LockPaymentByOrder(ctx context.Context, orderId string) (data *minder_model.PaymentData, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, lockPaymentByOrder)
+	if err != nil {
+		log.Println(ctx, "[repository:payment] Preparing Lock Payment err", err)
+		return
+	}
+	defer stmt.Close()
+	data, err = scanPayment(stmt.QueryRowContext(ctx, orderId))
+	if err != nil && err != ErrNotFound {
+		log.Println(ctx, "[repository:payment] Lock Payment err", err)
+	}
+	return
+}
+
+// UpdatePaymentStatus sets the payment's status, and its subscription unless subscriptionId is nil
+func (r *paymentRepositoryImpl) UpdatePaymentStatus(ctx context.Context, paymentId int64, status string, subscriptionId *int64) (data int64, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, updatePaymentStatus)
+	if err != nil {
+		log.Println(ctx, "[repository:payment] Preparing Update Payment Status err", err)
+		return
+	}
+	defer stmt.Close()
+	result, err := stmt.ExecContext(ctx, status, subscriptionId, paymentId)
+	if err != nil {
+		log.Println(ctx, "[repository:payment] Update Payment Status err", err)
+		return
+	}
+	return result.RowsAffected()
+}
+
+// CountPaidPayments returns how many payments of the subscription are paid and not given back
+func (r *paymentRepositoryImpl) CountPaidPayments(ctx context.Context, subscriptionId int64) (data int64, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, countPaidPayments)
+	if err != nil {
+		log.Println(ctx, "[repository:payment] Preparing Count Paid Payments err", err)
+		return
+	}
+	defer stmt.Close()
+	err = stmt.QueryRowContext(ctx, subscriptionId).Scan(&data)
+	if err != nil {
+		log.Println(ctx, "[repository:payment] Count Paid Payments err", err)
+	}
+	return
+}
+
+// InsertPaymentEvent records a webhook event, inserted is false when the event was already recorded
+func (r *paymentRepositoryImpl) InsertPaymentEvent(ctx context.Context, eventId, orderId, eventType string) (inserted bool, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, insertPaymentEvent)
+	if err != nil {
+		log.Println(ctx, "[repository:payment] Preparing Insert Payment Event err", err)
+		return
+	}
+	defer stmt.Close()
+	result, err := stmt.ExecContext(ctx, eventId, orderId, eventType)
+	if err != nil {
+		log.Println(ctx, "[repository:payment] Insert Payment Event err", err)
+		return
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
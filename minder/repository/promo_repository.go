@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+)
+
+type PromoRepository interface {
+	InsertPromoCode(ctx context.Context, req *minder_model.PromoCodeReq) (data int64, err error)
+	LockPromoCode(ctx context.Context, code string) (data *minder_model.PromoCodeData, err error)
+	InsertRedemption(ctx context.Context, promoCodeId int64, userId uint64, redeemedAt time.Time) (data int64, err error)
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+)
+
+type promoRepositoryImpl struct {
+	DB *sql.DB
+}
+
+func NewPromoRepositoryImpl(db *sql.DB) PromoRepository {
+	return &promoRepositoryImpl{DB: db}
+}
+
+const (
+	insertPromoCode = `INSERT IGNORE INTO PromoCodes (code, package, days, max_redemptions, expires_at) VALUES (?,?,?,?,?)`
+
+	lockPromoCode = `SELECT promo_code_id, code, package, days, max_redemptions, redemption_count, expires_at
+			FROM PromoCodes
+			WHERE code = ?
+			FOR UPDATE`
+
+	insertRedemption = `INSERT IGNORE INTO PromoRedemptions (promo_code_id, user_id, redeemed_at) VALUES (?,?,?)`
+
+	incrementRedemptions = `UPDATE PromoCodes SET redemption_count = redemption_count + 1 WHERE promo_code_id = ?`
+)
+
+// InsertPromoCode creates a promo code, it returns ErrPromoCodeExists when the code is already in use
+func (r *promoRepositoryImpl) InsertPromoCode(ctx context.Context, req *minder_model.PromoCodeReq) (data int64, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, insertPromoCode)
+	if err != nil {
+		log.Println(ctx, "[repository:promo] Preparing Insert Promo Code err", err)
+		return
+	}
+	defer stmt.Close()
+	result, err := stmt.ExecContext(ctx, req.Code, req.Package, req.Days, req.MaxRedemptions, req.ExpiresAt)
+	if err != nil {
+		log.Println(ctx, "[repository:promo] Insert Promo Code err ", err)
+		return
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	if inserted == 0 {
+		return 0, ErrPromoCodeExists
+	}
+	return result.LastInsertId()
+}
+
+// LockPromoCode reads a promo code and holds its row lock until the transaction ends
+func (r *promoRepositoryImpl) LockPromoCode(ctx context.Context, code string) (data *minder_model.PromoCodeData, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, lockPromoCode)
+	if err != nil {
+		log.Println(ctx, "[repository:promo] Preparing Lock Promo Code err", err)
+		return
+	}
+	defer stmt.Close()
+
+	data = &minder_model.PromoCodeData{}
+	err = stmt.QueryRowContext(ctx, code).Scan(&data.PromoCodeId, &data.Code, &data.Package, &data.Days,
+		&data.MaxRedemptions, &data.RedemptionCount, &data.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		log.Println(ctx, "[repository:promo] Lock Promo Code err", err)
+		return nil, err
+	}
+	return
+}
+
+// InsertRedemption records the user's redemption of a promo code and counts it against the code's cap.
+// It returns ErrPromoRedeemed when the user already redeemed the code.
+func (r *promoRepositoryImpl) InsertRedemption(ctx context.Context, promoCodeId int64, userId uint64, redeemedAt time.Time) (data int64, err error) {
+	err = runInTx(ctx, r.DB, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, insertRedemption, promoCodeId, userId, redeemedAt)
+		if err != nil {
+			log.Println(ctx, "[repository:promo] Insert Redemption err ", err)
+			return err
+		}
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if inserted == 0 {
+			return ErrPromoRedeemed
+		}
+		if data, err = result.LastInsertId(); err != nil {
+			return err
+		}
+
+		if _, err = tx.ExecContext(ctx, incrementRedemptions, promoCodeId); err != nil {
+			log.Println(ctx, "[repository:promo] Increment Redemptions err ", err)
+		}
+		return err
+	})
+	return
+}
@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+)
+
+type PushRepository interface {
+	SaveDeviceToken(ctx context.Context, req *minder_model.DeviceTokenReq) (data int64, err error)
+	DeleteDeviceToken(ctx context.Context, userId uint64, token string) (data int64, err error)
+	GetDeviceTokens(ctx context.Context, userId uint64) (data []*minder_model.DeviceToken, err error)
+	GetNotificationPreferences(ctx context.Context, userId uint64) (data *minder_model.NotificationPreferences, err error)
+	SaveNotificationPreferences(ctx context.Context, userId uint64, prefs *minder_model.NotificationPreferences) (data int64, err error)
+	GetDuePushes(ctx context.Context, now time.Time, limit int) (data []*minder_model.PushOutboxEntry, err error)
+	ClaimPush(ctx context.Context, outboxId int64, until time.Time) (data int64, err error)
+	MarkPushProcessed(ctx context.Context, outboxId int64, status string, now time.Time) (data int64, err error)
+	MarkPushFailed(ctx context.Context, outboxId int64, status string, attempts int64, nextAttemptAt time.Time, lastError string) (data int64, err error)
+}
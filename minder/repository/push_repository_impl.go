@@ -0,0 +1,258 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+)
+
+type pushRepositoryImpl struct {
+	DB *sql.DB
+}
+
+func NewPushRepositoryImpl(db *sql.DB) PushRepository {
+	return &pushRepositoryImpl{DB: db}
+}
+
+const (
+	saveDeviceToken = `INSERT INTO DeviceTokens (user_id, platform, token) VALUES (?,?,?)
+			ON DUPLICATE KEY UPDATE user_id = VALUES(user_id), platform = VALUES(platform)`
+
+	deleteDeviceToken = `DELETE FROM DeviceTokens WHERE user_id=? AND token=?`
+
+	getDeviceTokens = `SELECT device_token_id, user_id, platform, token FROM DeviceTokens WHERE user_id=? ORDER BY device_token_id`
+
+	getNotificationPreferences = `SELECT push_matches, push_messages FROM NotificationPreferences WHERE user_id=?`
+
+	saveNotificationPreferences = `INSERT INTO NotificationPreferences (user_id, push_matches, push_messages) VALUES (?,?,?)
+			ON DUPLICATE KEY UPDATE push_matches = VALUES(push_matches), push_messages = VALUES(push_messages)`
+
+	insertPushOutbox = `INSERT INTO PushOutbox (user_id, push_type, payload) VALUES (?,?,?)`
+
+	// SKIP LOCKED lets several dispatchers share the outbox without delivering an entry twice
+	getDuePushes = `SELECT outbox_id, user_id, push_type, payload, attempts
+			FROM PushOutbox
+			WHERE status = 'pending' AND next_attempt_at <= ?
+			ORDER BY outbox_id
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED`
+
+	// a claimed entry is not due again until the claim runs out
+	claimPush = `UPDATE PushOutbox SET next_attempt_at=? WHERE outbox_id=?`
+
+	markPushProcessed = `UPDATE PushOutbox SET status=?, processed_at=? WHERE outbox_id=?`
+
+	markPushFailed = `UPDATE PushOutbox SET status=?, attempts=?, next_attempt_at=?, last_error=? WHERE outbox_id=?`
+
+	lastErrorMaxLength = 255
+)
+
+// writePushOutbox queues a push for the user in tx, so it is only sent once what it announces is committed
+func writePushOutbox(ctx context.Context, tx dbtx, userId int64, pushType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, insertPushOutbox, userId, pushType, string(data)); err != nil {
+		log.Println(ctx, "[repository:push] Insert Push Outbox err ", err)
+		return err
+	}
+	return nil
+}
+
+// SaveDeviceToken registers the token for the user, taking it over from whoever registered it before
+func (r *pushRepositoryImpl) SaveDeviceToken(ctx context.Context, req *minder_model.DeviceTokenReq) (data int64, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, saveDeviceToken)
+	if err != nil {
+		log.Println(ctx, "[repository:push] Preparing Save Device Token err", err)
+		return
+	}
+	defer stmt.Close()
+	result, err := stmt.ExecContext(ctx, req.UserId, req.Platform, req.Token)
+	if err != nil {
+		log.Println(ctx, "[repository:push] Save Device Token err", err)
+		return
+	}
+	return result.RowsAffected()
+}
+
+// DeleteDeviceToken removes the user's token, or returns ErrNotFound when the user has no such token
+func (r *pushRepositoryImpl) DeleteDeviceToken(ctx context.Context, userId uint64, token string) (data int64, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, deleteDeviceToken)
+	if err != nil {
+		log.Println(ctx, "[repository:push] Preparing Delete Device Token err", err)
+		return
+	}
+	defer stmt.Close()
+	result, err := stmt.ExecContext(ctx, userId, token)
+	if err != nil {
+		log.Println(ctx, "[repository:push] Delete Device Token err", err)
+		return
+	}
+	data, err = result.RowsAffected()
+	if err == nil && data == 0 {
+		return 0, ErrNotFound
+	}
+	return
+}
+
+func (r *pushRepositoryImpl) GetDeviceTokens(ctx context.Context, userId uint64) (data []*minder_model.DeviceToken, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, getDeviceTokens)
+	if err != nil {
+		log.Println(ctx, "[repository:push] Preparing Get Device Tokens err", err)
+		return
+	}
+	defer stmt.Close()
+	rows, err := stmt.QueryContext(ctx, userId)
+	if err != nil {
+		log.Println(ctx, "[repository:push] Get Device Tokens err", err)
+		return
+	}
+
+	defer func() {
+		closeRows(rows)
+		if err := rows.Err(); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	data = []*minder_model.DeviceToken{}
+	for rows.Next() {
+		var device minder_model.DeviceToken
+		err = rows.Scan(&device.DeviceTokenId, &device.UserId, &device.Platform, &device.Token)
+		if err != nil {
+			log.Println("[repository:push] Error scanning row:", err)
+			return nil, err
+		}
+		data = append(data, &device)
+	}
+
+	return
+}
+
+// GetNotificationPreferences returns the user's preferences, every push is on for users who never changed them
+func (r *pushRepositoryImpl) GetNotificationPreferences(ctx context.Context, userId uint64) (data *minder_model.NotificationPreferences, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, getNotificationPreferences)
+	if err != nil {
+		log.Println(ctx, "[repository:push] Preparing Get Notification Preferences err", err)
+		return
+	}
+	defer stmt.Close()
+
+	data = &minder_model.NotificationPreferences{}
+	err = stmt.QueryRowContext(ctx, userId).Scan(&data.Matches, &data.Messages)
+	if err == sql.ErrNoRows {
+		return &minder_model.NotificationPreferences{Matches: true, Messages: true}, nil
+	}
+	if err != nil {
+		log.Println(ctx, "[repository:push] Get Notification Preferences err", err)
+		return nil, err
+	}
+	return
+}
+
+func (r *pushRepositoryImpl) SaveNotificationPreferences(ctx context.Context, userId uint64, prefs *minder_model.NotificationPreferences) (data int64, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, saveNotificationPreferences)
+	if err != nil {
+		log.Println(ctx, "[repository:push] Preparing Save Notification Preferences err", err)
+		return
+	}
+	defer stmt.Close()
+	result, err := stmt.ExecContext(ctx, userId, prefs.Matches, prefs.Messages)
+	if err != nil {
+		log.Println(ctx, "[repository:push] Save Notification Preferences err", err)
+		return
+	}
+	return result.RowsAffected()
+}
+
+// GetDuePushes locks up to limit pending pushes due by now until the surrounding transaction ends,
+// entries already locked by another dispatcher are skipped. Claim them before the transaction ends.
+func (r *pushRepositoryImpl) GetDuePushes(ctx context.Context, now time.Time, limit int) (data []*minder_model.PushOutboxEntry, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, getDuePushes)
+	if err != nil {
+		log.Println(ctx, "[repository:push] Preparing Get Due Pushes err", err)
+		return
+	}
+	defer stmt.Close()
+	rows, err := stmt.QueryContext(ctx, now, limit)
+	if err != nil {
+		log.Println(ctx, "[repository:push] Get Due Pushes err", err)
+		return
+	}
+
+	defer func() {
+		closeRows(rows)
+		if err := rows.Err(); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	data = []*minder_model.PushOutboxEntry{}
+	for rows.Next() {
+		var entry minder_model.PushOutboxEntry
+		var payload string
+		err = rows.Scan(&entry.OutboxId, &entry.UserId, &entry.PushType, &payload, &entry.Attempts)
+		if err != nil {
+			log.Println("[repository:push] Error scanning row:", err)
+			return nil, err
+		}
+		entry.Payload = json.RawMessage(payload)
+		data = append(data, &entry)
+	}
+
+	return
+}
+
+// ClaimPush keeps another dispatcher from picking the entry up until the claim runs out
+func (r *pushRepositoryImpl) ClaimPush(ctx context.Context, outboxId int64, until time.Time) (data int64, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, claimPush)
+	if err != nil {
+		log.Println(ctx, "[repository:push] Preparing Claim Push err", err)
+		return
+	}
+	defer stmt.Close()
+	result, err := stmt.ExecContext(ctx, until, outboxId)
+	if err != nil {
+		log.Println(ctx, "[repository:push] Claim Push err", err)
+		return
+	}
+	return result.RowsAffected()
+}
+
+func (r *pushRepositoryImpl) MarkPushProcessed(ctx context.Context, outboxId int64, status string, now time.Time) (data int64, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, markPushProcessed)
+	if err != nil {
+		log.Println(ctx, "[repository:push] Preparing Mark Push Processed err", err)
+		return
+	}
+	defer stmt.Close()
+	result, err := stmt.ExecContext(ctx, status, now, outboxId)
+	if err != nil {
+		log.Println(ctx, "[repository:push] Mark Push Processed err", err)
+		return
+	}
+	return result.RowsAffected()
+}
+
+func (r *pushRepositoryImpl) MarkPushFailed(ctx context.Context, outboxId int64, status string, attempts int64, nextAttemptAt time.Time, lastError string) (data int64, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, markPushFailed)
+	if err != nil {
+		log.Println(ctx, "[repository:push] Preparing Mark Push Failed err", err)
+		return
+	}
+	defer stmt.Close()
+	if len(lastError) > lastErrorMaxLength {
+		lastError = lastError[:lastErrorMaxLength]
+	}
+	result, err := stmt.ExecContext(ctx, status, attempts, nextAttemptAt, lastError, outboxId)
+	if err != nil {
+		log.Println(ctx, "[repository:push] Mark Push Failed err", err)
+		return
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+)
+
+type SubscriptionRepository interface {
+	GetEntitledSubscription(ctx context.Context, userId uint64, now time.Time, grace time.Duration) (data *minder_model.SubscriptionData, err error)
+	GetLatestSubscription(ctx context.Context, userId uint64) (data *minder_model.SubscriptionData, err error)
+	InsertSubscription(ctx context.Context, userId uint64, plan string, startedAt, periodEnd time.Time) (data int64, err error)
+	ExtendSubscription(ctx context.Context, subscriptionId int64, plan string, periodEnd time.Time) (data int64, err error)
+	ShortenSubscription(ctx context.Context, subscriptionId int64, periodEnd time.Time) (data int64, err error)
+	RevokeSubscription(ctx context.Context, subscriptionId int64, now time.Time) (data int64, err error)
+	CancelSubscription(ctx context.Context, subscriptionId int64, now time.Time) (data int64, err error)
+	LockSubscription(ctx context.Context, subscriptionId int64) (data *minder_model.SubscriptionData, err error)
+	LockStoreSubscription(ctx context.Context, store, storeTransactionId string) (data *minder_model.SubscriptionData, err error)
+	InsertStoreSubscription(ctx context.Context, userId uint64, plan, store, storeTransactionId string, startedAt, periodEnd time.Time, autoRenew bool) (data int64, err error)
+	SyncStoreSubscription(ctx context.Context, subscriptionId int64, plan string, periodEnd time.Time, autoRenew bool, now time.Time) (data int64, err error)
+	InsertStoreNotification(ctx context.Context, store, notificationId, notificationType string) (inserted bool, err error)
+	StartGracePeriods(ctx context.Context, now time.Time, grace time.Duration) (data int64, err error)
+	ExpireSubscriptions(ctx context.Context, now time.Time) (data int64, err error)
+}
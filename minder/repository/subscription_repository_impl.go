@@ -0,0 +1,331 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+)
+
+type subscriptionRepositoryImpl struct {
+	DB *sql.DB
+}
+
+func NewSubscriptionRepositoryImpl(db *sql.DB) SubscriptionRepository {
+	return &subscriptionRepositoryImpl{DB: db}
+}
+
+const (
+	subscriptionColumns = `subscription_id, user_id, plan, status, store, auto_renew, started_at, current_period_end, grace_ends_at, canceled_at`
+
+	// a subscription past its period still entitles while it renews automatically and the grace period lasts,
+	// even before the expiry job moved it to grace
+	getEntitledSubscription = `SELECT ` + subscriptionColumns + `
+			FROM Subscriptions
+			WHERE user_id = ? AND status IN ('active', 'grace')
+				AND (current_period_end > ? OR (auto_renew AND current_period_end > ?))
+			ORDER BY current_period_end DESC
+			LIMIT 1`
+
+	getLatestSubscription = `SELECT ` + subscriptionColumns + `
+			FROM Subscriptions
+			WHERE user_id = ?
+			ORDER BY subscription_id DESC
+			LIMIT 1`
+
+	insertSubscription = `INSERT INTO Subscriptions (user_id, plan, started_at, current_period_end) VALUES (?,?,?,?)`
+
+	extendSubscription = `UPDATE Subscriptions
+			SET plan = ?, status = 'active', current_period_end = ?, grace_ends_at = NULL
+			WHERE subscription_id = ?`
+
+	shortenSubscription = `UPDATE Subscriptions SET current_period_end = ? WHERE subscription_id = ?`
+
+	revokeSubscription = `UPDATE Subscriptions SET status = 'revoked', auto_renew = FALSE, revoked_at = ? WHERE subscription_id = ?`
+
+	cancelSubscription = `UPDATE Subscriptions SET auto_renew = FALSE, canceled_at = ? WHERE subscription_id = ? AND auto_renew`
+
+	lockSubscription = `SELECT ` + subscriptionColumns + ` FROM Subscriptions WHERE subscription_id = ? FOR UPDATE`
+
+	lockStoreSubscription = `SELECT ` + subscriptionColumns + `
+			FROM Subscriptions
+			WHERE store = ? AND store_transaction_id = ?
+			FOR UPDATE`
+
+	insertStoreSubscription = `INSERT INTO Subscriptions (user_id, plan, store, store_transaction_id, started_at, current_period_end, auto_renew, canceled_at)
+			VALUES (?,?,?,?,?,?,?,?)`
+
+	// the store is the source of truth of a store subscription, except that its period only moves forward,
+	// a state older than the stored one is delivered late. A revoked subscription is only reactivated by a
+	// period ending after the revoked one, the user subscribed again.
+	syncStoreSubscription = `UPDATE Subscriptions
+			SET plan = ?, status = 'active', current_period_end = ?, grace_ends_at = NULL, revoked_at = NULL,
+				auto_renew = ?, canceled_at = IF(?, NULL, COALESCE(canceled_at, ?))
+			WHERE subscription_id = ? AND current_period_end <= ? AND (status <> 'revoked' OR current_period_end < ?)`
+
+	insertStoreNotification = `INSERT IGNORE INTO StoreNotifications (store, notification_id, notification_type) VALUES (?,?,?)`
+
+	startGracePeriods = `UPDATE Subscriptions
+			SET status = 'grace', grace_ends_at = current_period_end + INTERVAL ? SECOND
+			WHERE status = 'active' AND auto_renew AND current_period_end <= ?`
+
+	expireSubscriptions = `UPDATE Subscriptions
+			SET status = 'expired', expired_at = ?
+			WHERE (status = 'active' AND NOT auto_renew AND current_period_end <= ?)
+				OR (status = 'grace' AND (NOT auto_renew OR grace_ends_at <= ?))`
+)
+
+func scanSubscription(row interface{ Scan(dest ...any) error }) (*minder_model.SubscriptionData, error) {
+	var data minder_model.SubscriptionData
+	var graceEndsAt, canceledAt sql.NullTime
+	err := row.Scan(
+		&data.SubscriptionId,
+		&data.UserId,
+		&data.Plan,
+		&data.Status,
+		&data.Store,
+		&data.AutoRenew,
+		&data.StartedAt,
+		&data.CurrentPeriodEnd,
+		&graceEndsAt,
+		&canceledAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if graceEndsAt.Valid {
+		data.GraceEndsAt = &graceEndsAt.Time
+	}
+	if canceledAt.Valid {
+		data.CanceledAt = &canceledAt.Time
+	}
+	return &data, nil
+}
+
+// GetEntitledSubscription returns the user's subscription granting premium at now, or ErrNotFound when none does
+func (r *subscriptionRepositoryImpl) GetEntitledSubscription(ctx context.Context, userId uint64, now time.Time, grace time.Duration) (data *minder_model.SubscriptionData, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, getEntitledSubscription)
+	if err != nil {
+		log.Println(ctx, "[repository:subscription] Preparing Get Entitled Subscription err", err)
+		return
+	}
+	defer stmt.Close()
+	data, err = scanSubscription(stmt.QueryRowContext(ctx, userId, now, now.Add(-grace)))
+	if err != nil && err != ErrNotFound {
+		log.Println(ctx, "[repository:subscription] Get Entitled Subscription err", err)
+	}
+	return
+}
+
+// GetLatestSubscription returns the user's most recent subscription whatever its status, or ErrNotFound
+func (r *subscriptionRepositoryImpl) GetLatestSubscription(ctx context.Context, userId uint64) (data *minder_model.SubscriptionData, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, getLatestSubscription)
+	if err != nil {
+		log.Println(ctx, "[repository:subscription] Preparing Get Latest Subscription err", err)
+		return
+	}
+	defer stmt.Close()
+	data, err = scanSubscription(stmt.QueryRowContext(ctx, userId))
+	if err != nil && err != ErrNotFound {
+		log.Println(ctx, "[repository:subscription] Get Latest Subscription err", err)
+	}
+	return
+}
+
+func (r *subscriptionRepositoryImpl) InsertSubscription(ctx context.Context, userId uint64, plan string, startedAt, periodEnd time.Time) (data int64, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, insertSubscription)
+	if err != nil {
+		log.Println(ctx, "[repository:subscription] Preparing Insert Subscription err", err)
+		return
+	}
+	defer stmt.Close()
+	result, err := stmt.ExecContext(ctx, userId, plan, startedAt, periodEnd)
+	if err != nil {
+		log.Println(ctx, "[repository:subscription] Insert Subscription err", err)
+		return
+	}
+	return result.LastInsertId()
+}
+
+// ExtendSubscription renews the subscription on the plan until periodEnd, ending any grace period
+func (r *subscriptionRepositoryImpl) ExtendSubscription(ctx context.Context, subscriptionId int64, plan string, periodEnd time.Time) (data int64, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, extendSubscription)
+	if err != nil {
+		log.Println(ctx, "[repository:subscription] Preparing Extend Subscription err", err)
+		return
+	}
+	defer stmt.Close()
+	result, err := stmt.ExecContext(ctx, plan, periodEnd, subscriptionId)
+	if err != nil {
+		log.Println(ctx, "[repository:subscription] Extend Subscription err", err)
+		return
+	}
+	return result.RowsAffected()
+}
+
+// ShortenSubscription moves the end of the subscription's period back to periodEnd, for instance when
+// the payment of one of its periods is refunded
+func (r *subscriptionRepositoryImpl) ShortenSubscription(ctx context.Context, subscriptionId int64, periodEnd time.Time) (data int64, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, shortenSubscription)
+	if err != nil {
+		log.Println(ctx, "[repository:subscription] Preparing Shorten Subscription err", err)
+		return
+	}
+	defer stmt.Close()
+	result, err := stmt.ExecContext(ctx, periodEnd, subscriptionId)
+	if err != nil {
+		log.Println(ctx, "[repository:subscription] Shorten Subscription err", err)
+		return
+	}
+	return result.RowsAffected()
+}
+
+// RevokeSubscription ends the subscription's premium right away, for instance when its payment is refunded
+func (r *subscriptionRepositoryImpl) RevokeSubscription(ctx context.Context, subscriptionId int64, now time.Time) (data int64, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, revokeSubscription)
+	if err != nil {
+		log.Println(ctx, "[repository:subscription] Preparing Revoke Subscription err", err)
+		return
+	}
+	defer stmt.Close()
+	result, err := stmt.ExecContext(ctx, now, subscriptionId)
+	if err != nil {
+		log.Println(ctx, "[repository:subscription] Revoke Subscription err", err)
+		return
+	}
+	return result.RowsAffected()
+}
+
+// CancelSubscription turns off the renewal, the subscription keeps entitling until its period ends.
+// ErrNotFound is returned when the subscription was already canceled.
+func (r *subscriptionRepositoryImpl) CancelSubscription(ctx context.Context, subscriptionId int64, now time.Time) (data int64, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, cancelSubscription)
+	if err != nil {
+		log.Println(ctx, "[repository:subscription] Preparing Cancel Subscription err", err)
+		return
+	}
+	defer stmt.Close()
+	result, err := stmt.ExecContext(ctx, now, subscriptionId)
+	if err != nil {
+		log.Println(ctx, "[repository:subscription] Cancel Subscription err", err)
+		return
+	}
+	data, err = result.RowsAffected()
+	if err == nil && data == 0 {
+		return 0, ErrNotFound
+	}
+	return
+}
+
+// LockSubscription returns the subscription and holds its row lock until the transaction ends, or ErrNotFound
+func (r *subscriptionRepositoryImpl) LockSubscription(ctx context.Context, subscriptionId int64) (data *minder_model.SubscriptionData, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, lockSubscription)
+	if err != nil {
+		log.Println(ctx, "[repository:subscription] Preparing Lock Subscription err", err)
+		return
+	}
+	defer stmt.Close()
+	data, err = scanSubscription(stmt.QueryRowContext(ctx, subscriptionId))
+	if err != nil && err != ErrNotFound {
+		log.Println(ctx, "[repository:subscription] Lock Subscription err", err)
+	}
+	return
+}
+
+// LockStoreSubscription returns the subscription a store knows by storeTransactionId and holds its row lock
+// until the transaction ends, or ErrNotFound when no user validated the subscription yet
+func (r *subscriptionRepositoryImpl) LockStoreSubscription(ctx context.Context, store, storeTransactionId string) (data *minder_model.SubscriptionData, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, lockStoreSubscription)
+	if err != nil {
+		log.Println(ctx, "[repository:subscription] Preparing Lock Store Subscription err", err)
+		return
+	}
+	defer stmt.Close()
+	data, err = scanSubscription(stmt.QueryRowContext(ctx, store, storeTransactionId))
+	if err != nil && err != ErrNotFound {
+		log.Println(ctx, "[repository:subscription] Lock Store Subscription err", err)
+	}
+	return
+}
+
+func (r *subscriptionRepositoryImpl) InsertStoreSubscription(ctx context.Context, userId uint64, plan, store, storeTransactionId string,
+	startedAt, periodEnd time.Time, autoRenew bool) (data int64, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, insertStoreSubscription)
+	if err != nil {
+		log.Println(ctx, "[repository:subscription] Preparing Insert Store Subscription err", err)
+		return
+	}
+	defer stmt.Close()
+
+	var canceledAt *time.Time
+	if !autoRenew {
+		canceledAt = &startedAt
+	}
+	result, err := stmt.ExecContext(ctx, userId, plan, store, storeTransactionId, startedAt, periodEnd, autoRenew, canceledAt)
+	if err != nil {
+		log.Println(ctx, "[repository:subscription] Insert Store Subscription err", err)
+		return
+	}
+	return result.LastInsertId()
+}
+
+// SyncStoreSubscription applies the state the store reports to the subscription, a subscription
+// that stopped renewing is marked canceled at now. A state ending before the stored period is
+// older than it and left out, as is a state of a revoked subscription not ending later, data is 0 then.
+func (r *subscriptionRepositoryImpl) SyncStoreSubscription(ctx context.Context, subscriptionId int64, plan string, periodEnd time.Time,
+	autoRenew bool, now time.Time) (data int64, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, syncStoreSubscription)
+	if err != nil {
+		log.Println(ctx, "[repository:subscription] Preparing Sync Store Subscription err", err)
+		return
+	}
+	defer stmt.Close()
+	result, err := stmt.ExecContext(ctx, plan, periodEnd, autoRenew, autoRenew, now, subscriptionId, periodEnd, periodEnd)
+	if err != nil {
+		log.Println(ctx, "[repository:subscription] Sync Store Subscription err", err)
+		return
+	}
+	return result.RowsAffected()
+}
+
+// InsertStoreNotification records a store notification, inserted is false when it was recorded before
+func (r *subscriptionRepositoryImpl) InsertStoreNotification(ctx context.Context, store, notificationId, notificationType string) (inserted bool, err error) {
+	stmt, err := connFrom(ctx, r.DB).PrepareContext(ctx, insertStoreNotification)
+	if err != nil {
+		log.Println(ctx, "[repository:subscription] Preparing Insert Store Notification err", err)
+		return
+	}
+	defer stmt.Close()
+	result, err := stmt.ExecContext(ctx, store, notificationId, notificationType)
+	if err != nil {
+		log.Println(ctx, "[repository:subscription] Insert Store Notification err", err)
+		return
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// StartGracePeriods moves renewing subscriptions past their period into their grace period
+func (r *subscriptionRepositoryImpl) StartGracePeriods(ctx context.Context, now time.Time, grace time.Duration) (data int64, err error) {
+	result, err := connFrom(ctx, r.DB).ExecContext(ctx, startGracePeriods, int64(grace.Seconds()), now)
+	if err != nil {
+		log.Println(ctx, "[repository:subscription] Start Grace Periods err", err)
+		return
+	}
+	return result.RowsAffected()
+}
+
+// ExpireSubscriptions expires canceled subscriptions past their period and grace periods that ran out
+func (r *subscriptionRepositoryImpl) ExpireSubscriptions(ctx context.Context, now time.Time) (data int64, err error) {
+	result, err := connFrom(ctx, r.DB).ExecContext(ctx, expireSubscriptions, now, now, now)
+	if err != nil {
+		log.Println(ctx, "[repository:subscription] Expire Subscriptions err", err)
+		return
+	}
+	return result.RowsAffected()
+}
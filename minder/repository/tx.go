@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// dbtx is what both *sql.DB and *sql.Tx offer, so every query runs the same inside or outside a transaction
+type dbtx interface {
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type txKey struct{}
+
+// runInTx runs fn in a transaction on db, or in the transaction ctx already carries.
+// Only the call that began the transaction commits or rolls it back.
+func runInTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) (err error) {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(tx)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println(ctx, "[repository:minder] Begin Transaction err", err)
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				log.Println(ctx, "[repository:minder] Rollback Transaction err", rbErr)
+			}
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		return
+	}
+
+	return tx.Commit()
+}
+
+// connFrom returns the transaction carried by ctx, or db when there is none
+func connFrom(ctx context.Context, db *sql.DB) dbtx {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return db
+}
+
+// WithTx runs fn in a single transaction. Every repository call made with the ctx passed to fn
+// joins that transaction, whichever repository makes it, it is committed when fn returns nil
+// and rolled back otherwise. Calling WithTx with a ctx that already carries a transaction joins
+// it instead of starting a new one.
+func (r *minderRepositoryImpl) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return r.withTx(ctx, func(tx *sql.Tx) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+func (r *minderRepositoryImpl) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	return runInTx(ctx, r.DB, fn)
+}
+
+func (r *minderRepositoryImpl) conn(ctx context.Context) dbtx {
+	return connFrom(ctx, r.DB)
+}
+
+// LockUsers locks the users' rows in id order until the surrounding transaction ends, so quota
+// checks and the writes that consume the quota run one request at a time per user
+func (r *minderRepositoryImpl) LockUsers(ctx context.Context, ids ...uint64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	query := fmt.Sprintf(lockUsers, strings.TrimSuffix(strings.Repeat("?,", len(ids)), ","))
+
+	return r.withTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, query, args...)
+		if err != nil {
+			log.Println(ctx, "[repository:minder] Lock Users err", err)
+			return err
+		}
+		defer closeRows(rows)
+
+		if !rows.Next() {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
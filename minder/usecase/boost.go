@@ -0,0 +1,154 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/AlvinTendio/minder/common"
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+	"github.com/AlvinTendio/minder/minder/repository"
+)
+
+const (
+	boostDurationMinutes = "boost.duration.minutes"
+	boostDailyLimit      = "boost.daily.limit"
+
+	defaultBoostDurationMinutes = 30
+	defaultBoostDailyLimit      = 1
+)
+
+// ActivateBoost pushes a user entitled to boosts to the front of nearby decks for the configured duration
+func (u *minderUsecaseImpl) ActivateBoost(ctx context.Context, req *minder_model.BoostReq) (res *common.HTTPResponse, err error) {
+	return u.atomically(ctx, []uint64{uint64(req.Id)}, func(ctx context.Context) (*common.HTTPResponse, error) {
+		return u.activateBoost(ctx, req)
+	})
+}
+
+func (u *minderUsecaseImpl) activateBoost(ctx context.Context, req *minder_model.BoostReq) (res *common.HTTPResponse, err error) {
+	id := uint64(req.Id)
+
+	allowed, err := u.Entitlements.Allowed(ctx, id, minder_model.FeatureBoost)
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	if !allowed {
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusForbidden,
+			ResponseCode:    common.StatusForbiddenErrorResponseCode,
+			ResponseMessage: common.StatusForbiddenErrorResponseMessage,
+		}
+		return
+	}
+
+	_, err = u.MinderRepo.GetActiveBoost(ctx, id)
+	switch {
+	case err == nil:
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusConflict,
+			ResponseCode:    common.StatusConflictErrorResponseCode,
+			ResponseMessage: common.StatusConflictErrorResponseMessage,
+		}
+		return
+	case !errors.Is(err, repository.ErrNotFound):
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	today, err := u.Quota.Today(ctx, id)
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	boostCount, err := u.MinderRepo.GetUserBoostCount(ctx, id, today)
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	if boostCount >= configInt(u.Config, boostDailyLimit, defaultBoostDailyLimit) {
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusTooManyRequests,
+			ResponseCode:    common.StatusTooManyRequestsResponseCode,
+			ResponseMessage: common.StatusTooManyRequestsResponseMessage,
+		}
+		return
+	}
+
+	_, err = u.MinderRepo.InsertBoost(ctx, id, configInt(u.Config, boostDurationMinutes, defaultBoostDurationMinutes))
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	return u.GetBoost(ctx, &minder_model.BoostStatusReq{UserId: req.Id})
+}
+
+// GetBoost returns the user's running boost and the impressions it got so far
+func (u *minderUsecaseImpl) GetBoost(ctx context.Context, req *minder_model.BoostStatusReq) (res *common.HTTPResponse, err error) {
+	data, err := u.MinderRepo.GetActiveBoost(ctx, uint64(req.UserId))
+
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusNotFound,
+			ResponseCode:    common.StatusNotFoundErrorResponseCode,
+			ResponseMessage: common.StatusNotFoundErrorResponseMessage,
+		}
+		return res, nil
+	case err != nil:
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+		Data:            data,
+	}
+
+	return
+}
+
+// recordBoostImpression counts a served card towards the boosted user's impressions.
+// Failing to count it must not fail serving the card.
+func (u *minderUsecaseImpl) recordBoostImpression(ctx context.Context, id int64) {
+	if _, err := u.MinderRepo.IncrementBoostImpressions(ctx, id); err != nil {
+		log.Println(ctx, "Error ", err)
+	}
+}
@@ -0,0 +1,312 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/AlvinTendio/minder/common"
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+	"github.com/AlvinTendio/minder/minder/repository"
+)
+
+const (
+	chatMessageMaxLength  = "chat.message.max.length"
+	chatRateLimitCount    = "chat.rate.limit.count"
+	chatRateLimitWindow   = "chat.rate.limit.window.seconds"
+	defaultMessageLength  = 1000
+	defaultRateLimitCount = 20
+	defaultRateLimitSecs  = 60
+
+	defaultHistorySize   = 30
+	messagePreviewLength = 100
+)
+
+// SendMessage sends a message to the other user of an active match. Every user may send at most
+// chat.rate.limit.count messages per chat.rate.limit.window.seconds, each up to chat.message.max.length characters.
+func (u *minderUsecaseImpl) SendMessage(ctx context.Context, matchId uint64, req *minder_model.SendMessageReq) (res *common.HTTPResponse, err error) {
+	id := uint64(req.UserId)
+	body := strings.TrimSpace(req.Body)
+	if body == "" || int64(utf8.RuneCountInString(body)) > configInt(u.Config, chatMessageMaxLength, defaultMessageLength) {
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusBadRequest,
+			ResponseCode:    common.StatusBadRequestErrorResponseCode,
+			ResponseMessage: common.StatusBadRequestErrorResponseMessage,
+		}
+		return
+	}
+
+	var match *minder_model.ChatMatch
+	var data *minder_model.MessageData
+	res, err = u.atomically(ctx, []uint64{id}, func(ctx context.Context) (res *common.HTTPResponse, err error) {
+		if match, res, err = u.checkChatMatch(ctx, matchId, id); res != nil || err != nil {
+			return
+		}
+
+		now := u.Clock.Now()
+		window := time.Duration(configInt(u.Config, chatRateLimitWindow, defaultRateLimitSecs)) * time.Second
+		sent, err := u.ChatRepo.CountMessagesSince(ctx, id, now.Add(-window))
+		if err != nil {
+			log.Println(ctx, "Error ", err)
+			return
+		}
+		if sent >= configInt(u.Config, chatRateLimitCount, defaultRateLimitCount) {
+			res = &common.HTTPResponse{
+				HTTPStatus:      http.StatusTooManyRequests,
+				ResponseCode:    common.StatusTooManyRequestsResponseCode,
+				ResponseMessage: common.StatusTooManyRequestsResponseMessage,
+			}
+			return res, nil
+		}
+
+		data, err = u.ChatRepo.InsertMessage(ctx, matchId, id, uint64(match.PeerId), body, now)
+		if err != nil {
+			log.Println(ctx, "Error ", err)
+		}
+		return
+	})
+	if res != nil || err != nil {
+		return
+	}
+	u.Hub.Publish(ctx, match.PeerId, minder_model.StreamEventMessage, &minder_model.MessageStreamEvent{MatchId: match.MatchId, Message: data})
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+		Data:            data,
+	}
+
+	return
+}
+
+// GetMessages pages through a match's messages, newest first
+func (u *minderUsecaseImpl) GetMessages(ctx context.Context, matchId uint64, req *minder_model.MessageHistoryReq) (res *common.HTTPResponse, err error) {
+	size := req.Size
+	if size < 1 {
+		size = defaultHistorySize
+	}
+
+	beforeMessageId, err := decodeCursor(req.Cursor)
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusBadRequest,
+			ResponseCode:    common.StatusBadRequestErrorResponseCode,
+			ResponseMessage: common.StatusBadRequestErrorResponseMessage,
+		}
+		return res, nil
+	}
+
+	if _, res, err = u.checkChatMatch(ctx, matchId, uint64(req.UserId)); res != nil || err != nil {
+		return
+	}
+
+	messages, err := u.ChatRepo.GetMessages(ctx, matchId, beforeMessageId, size)
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	data := &minder_model.MessageHistoryRes{Messages: messages}
+	if len(messages) == size {
+		data.NextCursor = encodeCursor(messages[len(messages)-1].MessageId)
+	}
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+		Data:            data,
+	}
+
+	return
+}
+
+// GetConversations lists the user's conversations, the one with the latest message first
+func (u *minderUsecaseImpl) GetConversations(ctx context.Context, req *minder_model.ConversationListReq) (res *common.HTTPResponse, err error) {
+	page, size := normalizePage(req.Page, req.Size)
+	id := uint64(req.UserId)
+
+	total, err := u.ChatRepo.CountConversations(ctx, id)
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	data, err := u.ChatRepo.GetConversations(ctx, id, size, (page-1)*size)
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	for _, conversation := range data {
+		conversation.LastMessage.Body = preview(conversation.LastMessage.Body)
+	}
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+		Data: &minder_model.ConversationListRes{
+			Conversations: data,
+			Pagination: minder_model.Pagination{
+				Page:  page,
+				Size:  size,
+				Total: total,
+			},
+		},
+	}
+
+	return
+}
+
+// MarkRead moves the user's read marker forward and tells the other user how far they read
+func (u *minderUsecaseImpl) MarkRead(ctx context.Context, matchId uint64, req *minder_model.ReadReq) (res *common.HTTPResponse, err error) {
+	match, res, err := u.checkChatMatch(ctx, matchId, uint64(req.UserId))
+	if res != nil || err != nil {
+		return
+	}
+
+	data, err := u.ChatRepo.MarkRead(ctx, matchId, uint64(req.UserId), req.MessageId)
+
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusNotFound,
+			ResponseCode:    common.StatusNotFoundErrorResponseCode,
+			ResponseMessage: common.StatusNotFoundErrorResponseMessage,
+		}
+		return res, nil
+	case err != nil:
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	if data.LastReadMessageId == req.MessageId {
+		u.Hub.Publish(ctx, match.PeerId, minder_model.StreamEventRead, &minder_model.ReadStreamEvent{
+			MatchId:           match.MatchId,
+			UserId:            req.UserId,
+			LastReadMessageId: data.LastReadMessageId,
+		})
+	}
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+		Data:            data,
+	}
+
+	return
+}
+
+// Typing tells the other user of the match the user is typing, nothing is stored
+func (u *minderUsecaseImpl) Typing(ctx context.Context, matchId uint64, req *minder_model.TypingReq) (res *common.HTTPResponse, err error) {
+	match, res, err := u.checkChatMatch(ctx, matchId, uint64(req.UserId))
+	if res != nil || err != nil {
+		return
+	}
+
+	u.Hub.Publish(ctx, match.PeerId, minder_model.StreamEventTyping, &minder_model.TypingStreamEvent{MatchId: match.MatchId, UserId: req.UserId})
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+	}
+
+	return
+}
+
+// GetUnreadCounts returns the unread messages of every conversation with any, and their total
+func (u *minderUsecaseImpl) GetUnreadCounts(ctx context.Context, req *minder_model.UnreadReq) (res *common.HTTPResponse, err error) {
+	conversations, err := u.ChatRepo.GetUnreadCounts(ctx, uint64(req.UserId))
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	data := &minder_model.UnreadRes{Conversations: conversations}
+	for _, conversation := range conversations {
+		data.Total += conversation.UnreadCount
+	}
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+		Data:            data,
+	}
+
+	return
+}
+
+// checkChatMatch returns a response when the user may not chat in the match: 404 when the user
+// is not part of it and 403 once the match ended or either user blocked the other
+func (u *minderUsecaseImpl) checkChatMatch(ctx context.Context, matchId, userId uint64) (match *minder_model.ChatMatch, res *common.HTTPResponse, err error) {
+	match, err = u.ChatRepo.GetChatMatch(ctx, matchId, userId)
+
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusNotFound,
+			ResponseCode:    common.StatusNotFoundErrorResponseCode,
+			ResponseMessage: common.StatusNotFoundErrorResponseMessage,
+		}
+		return nil, res, nil
+	case err != nil:
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	case !match.Active || match.Blocked:
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusForbidden,
+			ResponseCode:    common.StatusForbiddenErrorResponseCode,
+			ResponseMessage: common.StatusForbiddenErrorResponseMessage,
+		}
+	}
+
+	return
+}
+
+// preview shortens a message body for conversation lists
+func preview(body string) string {
+	if utf8.RuneCountInString(body) <= messagePreviewLength {
+		return body
+	}
+	return string([]rune(body)[:messagePreviewLength]) + "…"
+}
@@ -0,0 +1,53 @@
+package usecase
+
+import (
+	"context"
+	"log"
+	"time"
+
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+)
+
+const (
+	defaultTimeZoneKey = "timezone.default"
+	defaultTimeZone    = "Asia/Jakarta"
+)
+
+// Clock tells the current time, so day boundaries can be computed against a fixed time
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+// NewSystemClock returns a Clock reading the system time
+func NewSystemClock() Clock {
+	return systemClock{}
+}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// localDay returns the day containing now in loc
+func localDay(now time.Time, loc *time.Location) minder_model.DayRange {
+	local := now.In(loc)
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	return minder_model.DayRange{
+		Start: start,
+		End:   time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, loc),
+	}
+}
+
+// loadLocation returns the named location, or fallback when the name is empty or unknown
+func loadLocation(ctx context.Context, name string, fallback *time.Location) *time.Location {
+	if name == "" {
+		return fallback
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Println(ctx, "[usecase:clock] unknown time zone", name, err)
+		return fallback
+	}
+	return loc
+}
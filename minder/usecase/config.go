@@ -0,0 +1,19 @@
+package usecase
+
+import core_config "github.com/AlvinTendio/minder/config"
+
+// configFloat returns the config value for key, or fallback when the key is not set
+func configFloat(config core_config.Config, key string, fallback float64) float64 {
+	if config.Get(key) == nil {
+		return fallback
+	}
+	return config.GetFloat(key)
+}
+
+// configInt returns the config value for key, or fallback when the key is not set
+func configInt(config core_config.Config, key string, fallback int64) int64 {
+	if config.Get(key) == nil {
+		return fallback
+	}
+	return config.GetInt(key)
+}
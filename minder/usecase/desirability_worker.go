@@ -0,0 +1,112 @@
+package usecase
+
+import (
+	"context"
+	"log"
+	"math"
+	"time"
+
+	core_config "github.com/AlvinTendio/minder/config"
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+	"github.com/AlvinTendio/minder/minder/repository"
+)
+
+const (
+	desirabilityKFactor      = "desirability.k.factor"
+	desirabilityPollInterval = "desirability.poll.interval.seconds"
+	desirabilityBatchSize    = "desirability.batch.size"
+
+	defaultDesirabilityKFactor      = 32
+	defaultDesirabilityPollInterval = 5
+	defaultDesirabilityBatchSize    = 100
+)
+
+// SwipeEventPublisher records swipe events, publishing with the ctx of the transaction storing
+// the swipe keeps the event only when the swipe is committed
+type SwipeEventPublisher interface {
+	Publish(ctx context.Context, event minder_model.SwipeEvent) error
+}
+
+// DesirabilityWorker keeps an Elo style desirability score per user from swipe outcomes.
+// A like counts as a win for the swiped user against the swiper and a pass as a loss,
+// so a like from a highly rated user is worth more than one from a low rated user.
+// Events are read from the SwipeEvents table, so none is lost on a restart and clearing
+// their processed_at replays them.
+type DesirabilityWorker struct {
+	MinderRepo repository.MinderRepository
+	Config     core_config.Config
+}
+
+func NewDesirabilityWorker(minderRepo repository.MinderRepository, config core_config.Config) *DesirabilityWorker {
+	return &DesirabilityWorker{
+		MinderRepo: minderRepo,
+		Config:     config,
+	}
+}
+
+// Publish stores the event for the worker
+func (w *DesirabilityWorker) Publish(ctx context.Context, event minder_model.SwipeEvent) error {
+	_, err := w.MinderRepo.InsertSwipeEvent(ctx, &event)
+	return err
+}
+
+// Run applies pending swipe events every desirability.poll.interval.seconds until ctx is done
+func (w *DesirabilityWorker) Run(ctx context.Context) {
+	interval := time.Duration(configInt(w.Config, desirabilityPollInterval, defaultDesirabilityPollInterval)) * time.Second
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := w.process(ctx); err != nil {
+				log.Println(ctx, "[usecase:desirability] process swipe events err", err)
+			}
+		case <-ctx.Done():
+			log.Println("[usecase:desirability] worker stopped!")
+			return
+		}
+	}
+}
+
+// process applies one batch of pending events in order, a failure rolls the whole batch back
+// so it is applied again on the next run
+func (w *DesirabilityWorker) process(ctx context.Context) error {
+	return w.MinderRepo.WithTx(ctx, func(ctx context.Context) error {
+		events, err := w.MinderRepo.GetPendingSwipeEvents(ctx, int(configInt(w.Config, desirabilityBatchSize, defaultDesirabilityBatchSize)))
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			if err := w.apply(ctx, *event); err != nil {
+				return err
+			}
+			if _, err := w.MinderRepo.MarkSwipeEventProcessed(ctx, event.EventId); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (w *DesirabilityWorker) apply(ctx context.Context, event minder_model.SwipeEvent) error {
+	swiperScore, err := w.MinderRepo.GetDesirability(ctx, event.UserId)
+	if err != nil {
+		return err
+	}
+	targetScore, err := w.MinderRepo.GetDesirability(ctx, event.TargetId)
+	if err != nil {
+		return err
+	}
+
+	outcome := 1.0
+	if event.Action == minder_model.SwipeActionPass {
+		outcome = 0
+	}
+	expected := 1 / (1 + math.Pow(10, (swiperScore-targetScore)/400))
+	kFactor := configFloat(w.Config, desirabilityKFactor, defaultDesirabilityKFactor)
+
+	_, err = w.MinderRepo.UpdateDesirability(ctx, event.TargetId, targetScore+kFactor*(outcome-expected))
+	return err
+}
@@ -0,0 +1,60 @@
+package usecase
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+)
+
+const (
+	discoveryPassCooldownDays  = "discovery.cooldown.pass.days"
+	discoveryViewCooldownHours = "discovery.cooldown.view.hours"
+	discoveryScoreBand         = "desirability.band"
+
+	defaultPassCooldownDays  = 30
+	defaultViewCooldownHours = 24
+	defaultScoreBand         = 200
+)
+
+// discoveryRules builds the discovery rules from config, so changes apply on the next
+// request without a restart
+func (u *minderUsecaseImpl) discoveryRules() minder_model.DiscoveryRules {
+	now := u.Clock.Now()
+	passDays := configInt(u.Config, discoveryPassCooldownDays, defaultPassCooldownDays)
+	viewHours := configInt(u.Config, discoveryViewCooldownHours, defaultViewCooldownHours)
+
+	return minder_model.DiscoveryRules{
+		PassedSince: now.AddDate(0, 0, -int(passDays)),
+		ViewedSince: now.Add(-time.Duration(viewHours) * time.Hour),
+		ScoreBand:   configFloat(u.Config, discoveryScoreBand, defaultScoreBand),
+	}
+}
+
+// superLikesFirst moves candidates who super liked the viewer to the front, keeping the ranking otherwise
+func superLikesFirst(ranked []*minder_model.RankingProfile) []*minder_model.RankingProfile {
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].SuperLiked && !ranked[j].SuperLiked
+	})
+	return ranked
+}
+
+// visibleCandidates drops candidates hidden by incognito. The query flags users in incognito who did not
+// like the viewer, they stay hidden only while still entitled to incognito.
+func (u *minderUsecaseImpl) visibleCandidates(ctx context.Context, candidates []*minder_model.RankingProfile) ([]*minder_model.RankingProfile, error) {
+	visible := make([]*minder_model.RankingProfile, 0, len(candidates))
+	for _, candidate := range candidates {
+		if candidate.Incognito {
+			hidden, err := u.Entitlements.Allowed(ctx, uint64(candidate.UserId), minder_model.FeatureIncognito)
+			if err != nil {
+				return nil, err
+			}
+			if hidden {
+				continue
+			}
+		}
+		visible = append(visible, candidate)
+	}
+	return visible, nil
+}
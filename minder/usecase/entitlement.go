@@ -0,0 +1,404 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AlvinTendio/minder/common"
+	core_config "github.com/AlvinTendio/minder/config"
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+	"github.com/AlvinTendio/minder/minder/repository"
+)
+
+const (
+	subscriptionGraceDays = "subscription.grace.days"
+	entitlementPackages   = "entitlements.packages"
+
+	defaultSubscriptionGraceDays = 3
+)
+
+var (
+	subscriptionPlans = []string{minder_model.SubscriptionPlanMonthly, minder_model.SubscriptionPlanYearly}
+
+	// legacyQuotaTiers are the tiers of the deprecated quota.<tier>.<action> keys
+	legacyQuotaTiers = []string{minder_model.TierFree, minder_model.TierPremium, minder_model.TierTrial}
+
+	// defaultPackageFeatures applies when entitlements.package.<name> is not configured
+	defaultPackageFeatures = map[string][]string{
+		minder_model.TierFree:    {"view:10/day", "like", "superlike:1/day"},
+		minder_model.TierTrial:   {"view", "like", "superlike:5/day", "rewind:3/day", "see_likes", "boost", "incognito"},
+		minder_model.TierPremium: {"view", "like", "superlike:5/day", "rewind:3/day", "see_likes", "boost", "incognito"},
+		"extra_swipes":           {"view:30/day", "superlike:3/day"},
+	}
+)
+
+// EntitlementService decides what a user is entitled to, every feature check goes through it.
+// A user has the package of their tier, the package of their subscription plan and the packages
+// granted to them, and gets every feature of those packages.
+type EntitlementService interface {
+	// IsPremium tells whether the user has a subscription within its period, or within the grace period of a pending renewal
+	IsPremium(ctx context.Context, id uint64) (premium bool, err error)
+	Allowed(ctx context.Context, id uint64, feature string) (allowed bool, err error)
+	Entitlements(ctx context.Context, id uint64) (data *minder_model.EntitlementsRes, err error)
+	HasPackage(name string) bool
+}
+
+type entitlementServiceImpl struct {
+	MinderRepo       repository.MinderRepository
+	SubscriptionRepo repository.SubscriptionRepository
+	EntitlementRepo  repository.EntitlementRepository
+	Clock            Clock
+	Config           core_config.Config
+
+	mu       sync.RWMutex
+	packages map[string]map[string]int64
+	plans    map[string]string
+
+	watchMu sync.Mutex
+	watched map[string]bool
+}
+
+// NewEntitlementService returns an EntitlementService with the packages listed in entitlements.packages.
+// Each package lists its features in entitlements.package.<name>, either as a bare name or limited
+// as name:N/day, and entitlements.plan.<plan> names the package of a subscription plan, premium by default.
+// Packages are reloaded whenever they change, including packages added to entitlements.packages later on.
+// The deprecated quota.<tier>.<action> keys still apply to the package of their tier while it is not configured.
+func NewEntitlementService(minderRepo repository.MinderRepository, subscriptionRepo repository.SubscriptionRepository,
+	entitlementRepo repository.EntitlementRepository, clock Clock, config core_config.Config) EntitlementService {
+	e := &entitlementServiceImpl{
+		MinderRepo:       minderRepo,
+		SubscriptionRepo: subscriptionRepo,
+		EntitlementRepo:  entitlementRepo,
+		Clock:            clock,
+		Config:           config,
+		watched:          make(map[string]bool),
+	}
+	e.load(config)
+
+	keys := []string{entitlementPackages}
+	for _, plan := range subscriptionPlans {
+		keys = append(keys, planKey(plan))
+	}
+	for _, tier := range legacyQuotaTiers {
+		for _, action := range quotaActions {
+			keys = append(keys, legacyQuotaKey(tier, action))
+		}
+	}
+	e.watch(config, keys)
+	e.watchPackages(config)
+
+	return e
+}
+
+// watch reloads the packages whenever one of keys changes
+func (e *entitlementServiceImpl) watch(config core_config.Config, keys []string) {
+	changes := config.Watch(keys...)
+	go func() {
+		for keys := range changes {
+			log.Println("[usecase:entitlement] reloading packages, changed keys:", keys)
+			e.load(config)
+			e.watchPackages(config)
+		}
+	}()
+}
+
+// watchPackages starts watching the keys of packages added to entitlements.packages since the last call,
+// keys of packages removed from it stay watched so adding them back needs no new watch
+func (e *entitlementServiceImpl) watchPackages(config core_config.Config) {
+	e.watchMu.Lock()
+	defer e.watchMu.Unlock()
+
+	var keys []string
+	for _, name := range packageNames(config) {
+		if key := packageKey(name); !e.watched[key] {
+			e.watched[key] = true
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) > 0 {
+		e.watch(config, keys)
+	}
+}
+
+func packageKey(name string) string {
+	return fmt.Sprintf("entitlements.package.%s", name)
+}
+
+func planKey(plan string) string {
+	return fmt.Sprintf("entitlements.plan.%s", plan)
+}
+
+// legacyQuotaKey is the key daily limits were configured in before packages, -1 meaning unlimited
+func legacyQuotaKey(tier, action string) string {
+	return fmt.Sprintf("quota.%s.%s", tier, action)
+}
+
+func packageNames(config core_config.Config) []string {
+	if config.Get(entitlementPackages) == nil {
+		names := make([]string, 0, len(defaultPackageFeatures))
+		for name := range defaultPackageFeatures {
+			names = append(names, name)
+		}
+		return names
+	}
+
+	var names []string
+	for _, name := range config.GetArray(entitlementPackages) {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (e *entitlementServiceImpl) load(config core_config.Config) {
+	packages := make(map[string]map[string]int64)
+	for _, name := range packageNames(config) {
+		spec := defaultPackageFeatures[name]
+		if config.Get(packageKey(name)) != nil {
+			spec = config.GetArray(packageKey(name))
+		}
+
+		features := make(map[string]int64, len(spec))
+		for _, entry := range spec {
+			feature, limit, err := parseFeature(entry)
+			if err != nil {
+				log.Println("[usecase:entitlement] skipping feature of package", name, ":", err)
+				continue
+			}
+			features[feature] = limit
+		}
+		if slices.Contains(legacyQuotaTiers, name) {
+			applyLegacyQuotas(config, name, features, config.Get(packageKey(name)) != nil)
+		}
+		packages[name] = features
+	}
+
+	plans := make(map[string]string, len(subscriptionPlans))
+	for _, plan := range subscriptionPlans {
+		plans[plan] = config.GetString(planKey(plan))
+		if plans[plan] == "" {
+			plans[plan] = minder_model.TierPremium
+		}
+	}
+
+	e.mu.Lock()
+	e.packages = packages
+	e.plans = plans
+	e.mu.Unlock()
+}
+
+// applyLegacyQuotas applies the deprecated quota.<tier>.<action> keys set for the tier to the features of its
+// package, unless the package is configured, and logs each key found so it gets moved to the package
+func applyLegacyQuotas(config core_config.Config, tier string, features map[string]int64, configured bool) {
+	for _, action := range quotaActions {
+		key := legacyQuotaKey(tier, action)
+		if config.Get(key) == nil {
+			continue
+		}
+		if configured {
+			log.Println("[usecase:entitlement] ignoring deprecated", key, "as", packageKey(tier), "is configured, remove it")
+			continue
+		}
+
+		log.Println("[usecase:entitlement] deprecated", key, "is applied to", packageKey(tier), ", move it there")
+		switch limit := config.GetInt(key); {
+		case limit < 0:
+			features[action] = minder_model.QuotaUnlimited
+		case limit == 0:
+			delete(features, action)
+		default:
+			features[action] = limit
+		}
+	}
+}
+
+// parseFeature reads a feature of a package, name for a feature without limit or name:N/day for one limited to N a day
+func parseFeature(entry string) (feature string, limit int64, err error) {
+	feature, rule, limited := strings.Cut(strings.TrimSpace(entry), ":")
+	if feature == "" {
+		return "", 0, fmt.Errorf("empty feature in %q", entry)
+	}
+	if !limited {
+		return feature, minder_model.QuotaUnlimited, nil
+	}
+
+	count, ok := strings.CutSuffix(rule, "/day")
+	if !ok {
+		return "", 0, fmt.Errorf("limit of %q is not per day", entry)
+	}
+	limit, err = strconv.ParseInt(count, 10, 64)
+	if err != nil || limit < 0 {
+		return "", 0, fmt.Errorf("invalid limit in %q", entry)
+	}
+	return feature, limit, nil
+}
+
+func (e *entitlementServiceImpl) IsPremium(ctx context.Context, id uint64) (bool, error) {
+	_, err := e.SubscriptionRepo.GetEntitledSubscription(ctx, id, e.Clock.Now(), gracePeriod(e.Config))
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (e *entitlementServiceImpl) Allowed(ctx context.Context, id uint64, feature string) (bool, error) {
+	data, err := e.Entitlements(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	return data.Limit(feature) != 0, nil
+}
+
+// Entitlements merges the features of every package the user has right now, a feature in several
+// packages gets the highest of their limits. The tier is premium for subscribers, trial while the
+// user's trial runs and free otherwise.
+func (e *entitlementServiceImpl) Entitlements(ctx context.Context, id uint64) (*minder_model.EntitlementsRes, error) {
+	now := e.Clock.Now()
+
+	tier, err := e.MinderRepo.GetUserTier(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	packages := []string{tier}
+
+	subscription, err := e.SubscriptionRepo.GetEntitledSubscription(ctx, id, now, gracePeriod(e.Config))
+	switch {
+	case err == nil:
+		tier = minder_model.TierPremium
+		e.mu.RLock()
+		packages = append(packages, e.plans[subscription.Plan])
+		e.mu.RUnlock()
+	case !errors.Is(err, repository.ErrNotFound):
+		return nil, err
+	}
+
+	grants, err := e.EntitlementRepo.GetActiveGrants(ctx, id, now)
+	if err != nil {
+		return nil, err
+	}
+	for _, grant := range grants {
+		if !slices.Contains(packages, grant.Package) {
+			packages = append(packages, grant.Package)
+		}
+	}
+
+	features := make(map[string]int64)
+	e.mu.RLock()
+	for _, name := range packages {
+		pkg, ok := e.packages[name]
+		if !ok {
+			log.Println(ctx, "[usecase:entitlement] user", id, "has unknown package", name)
+		}
+		for feature, limit := range pkg {
+			current, ok := features[feature]
+			if !ok || (current != minder_model.QuotaUnlimited && (limit == minder_model.QuotaUnlimited || limit > current)) {
+				features[feature] = limit
+			}
+		}
+	}
+	e.mu.RUnlock()
+
+	data := &minder_model.EntitlementsRes{Tier: tier, Packages: packages, Entitlements: []*minder_model.Entitlement{}}
+	for feature, limit := range features {
+		if limit != 0 {
+			data.Entitlements = append(data.Entitlements, &minder_model.Entitlement{Feature: feature, Limit: limit})
+		}
+	}
+	sort.Slice(data.Entitlements, func(i, j int) bool {
+		return data.Entitlements[i].Feature < data.Entitlements[j].Feature
+	})
+
+	return data, nil
+}
+
+func (e *entitlementServiceImpl) HasPackage(name string) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	_, ok := e.packages[name]
+	return ok
+}
+
+// gracePeriod is how long a subscription keeps entitling after its period while its renewal is pending
+func gracePeriod(config core_config.Config) time.Duration {
+	return time.Duration(configInt(config, subscriptionGraceDays, defaultSubscriptionGraceDays)) * 24 * time.Hour
+}
+
+// GetEntitlements lists the features the user has right now
+func (u *minderUsecaseImpl) GetEntitlements(ctx context.Context, req *minder_model.EntitlementsReq) (res *common.HTTPResponse, err error) {
+	data, err := u.Entitlements.Entitlements(ctx, uint64(req.UserId))
+
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusNotFound,
+			ResponseCode:    common.StatusNotFoundErrorResponseCode,
+			ResponseMessage: common.StatusNotFoundErrorResponseMessage,
+		}
+		return res, nil
+	case err != nil:
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+		Data:            data,
+	}
+
+	return
+}
+
+// CreateEntitlementGrant gives a user one of the configured packages until the grant expires
+func (u *minderUsecaseImpl) CreateEntitlementGrant(ctx context.Context, req *minder_model.EntitlementGrantReq) (res *common.HTTPResponse, err error) {
+	if !u.Entitlements.HasPackage(req.Package) || !req.ExpiresAt.After(u.Clock.Now()) {
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusBadRequest,
+			ResponseCode:    common.StatusBadRequestErrorResponseCode,
+			ResponseMessage: common.StatusBadRequestErrorResponseMessage,
+		}
+		return
+	}
+
+	grantId, err := u.EntitlementRepo.InsertGrant(ctx, uint64(req.UserId), req.Package, minder_model.GrantSourceAdmin, req.ExpiresAt)
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+		Data: &minder_model.EntitlementGrant{
+			GrantId:   grantId,
+			UserId:    req.UserId,
+			Package:   req.Package,
+			Source:    minder_model.GrantSourceAdmin,
+			ExpiresAt: req.ExpiresAt,
+		},
+	}
+
+	return
+}
@@ -0,0 +1,36 @@
+package usecase
+
+import (
+	"maps"
+	"testing"
+
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+)
+
+func TestLegacyQuotaKeys(t *testing.T) {
+	config := testConfig{
+		legacyQuotaKey(minder_model.TierFree, minder_model.QuotaActionView):      "20",
+		legacyQuotaKey(minder_model.TierFree, minder_model.QuotaActionSuperLike): "0",
+		legacyQuotaKey(minder_model.TierFree, minder_model.QuotaActionRewind):    "-1",
+		legacyQuotaKey(minder_model.TierTrial, minder_model.QuotaActionView):     "5",
+		packageKey(minder_model.TierTrial):                                       "view:50/day",
+	}
+	e := NewEntitlementService(nil, nil, nil, NewSystemClock(), config).(*entitlementServiceImpl)
+
+	for name, want := range map[string]map[string]int64{
+		// the legacy keys apply to a package left to its defaults
+		minder_model.TierFree: {
+			minder_model.QuotaActionView:   20,
+			minder_model.QuotaActionLike:   minder_model.QuotaUnlimited,
+			minder_model.QuotaActionRewind: minder_model.QuotaUnlimited,
+		},
+		// a configured package wins over the legacy keys
+		minder_model.TierTrial: {
+			minder_model.QuotaActionView: 50,
+		},
+	} {
+		if got := e.packages[name]; !maps.Equal(got, want) {
+			t.Errorf("package %s has features %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package usecase
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newMemoryUsecase returns the usecase over the in-memory repositories of one memoryStore
+func newMemoryUsecase() (*minderUsecaseImpl, *memoryStore) {
+	store := newMemoryStore()
+	return &minderUsecaseImpl{
+		MinderRepo:       memoryMinderRepo{memoryStore: store},
+		SubscriptionRepo: memorySubscriptionRepo{memoryStore: store},
+		PaymentRepo:      memoryPaymentRepo{memoryStore: store},
+		Clock:            NewSystemClock(),
+		Config:           testConfig{},
+	}, store
+}
+
+// webhookRecorder receives the webhooks of a fake provider and keeps the last one, which answer
+// passes on when set so the webhook can be applied as it arrives
+type webhookRecorder struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	header http.Header
+	body   []byte
+}
+
+func newWebhookRecorder(t *testing.T, answer func(r *http.Request, body []byte) int) *webhookRecorder {
+	t.Helper()
+	w := &webhookRecorder{}
+	w.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.mu.Lock()
+		w.header, w.body = r.Header.Clone(), body
+		w.mu.Unlock()
+
+		if answer != nil {
+			rw.WriteHeader(answer(r, body))
+		}
+	}))
+	t.Cleanup(w.Close)
+	return w
+}
+
+// last returns the last webhook received
+func (w *webhookRecorder) last() (http.Header, []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.header, w.body
+}
+
+// testConfig is a fixed core_config.Config, keys missing from the map are not set
+type testConfig map[string]string
+
+func (c testConfig) Close() error {
+	return nil
+}
+
+func (c testConfig) Get(key string) interface{} {
+	value, ok := c[key]
+	if !ok {
+		return nil
+	}
+	return value
+}
+
+func (c testConfig) GetInt(key string) int64 {
+	value, _ := strconv.ParseInt(c[key], 10, 64)
+	return value
+}
+
+func (c testConfig) GetString(key string) string {
+	return c[key]
+}
+
+func (c testConfig) GetBool(key string) bool {
+	value, _ := strconv.ParseBool(c[key])
+	return value
+}
+
+func (c testConfig) GetFloat(key string) float64 {
+	value, _ := strconv.ParseFloat(c[key], 64)
+	return value
+}
+
+func (c testConfig) GetBinary(key string) []byte {
+	return nil
+}
+
+func (c testConfig) GetArray(key string) []string {
+	if c[key] == "" {
+		return nil
+	}
+	return strings.Split(c[key], ",")
+}
+
+func (c testConfig) GetMap(key string) map[string]string {
+	maps := make(map[string]string)
+	for _, element := range c.GetArray(key) {
+		if k, v, ok := strings.Cut(element, ":"); ok {
+			maps[k] = v
+		}
+	}
+	return maps
+}
+
+// Watch never reports a change, the values are fixed
+func (c testConfig) Watch(keys ...string) <-chan []string {
+	return make(chan []string)
+}
@@ -0,0 +1,226 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/AlvinTendio/minder/common"
+	core_config "github.com/AlvinTendio/minder/config"
+	"github.com/AlvinTendio/minder/iap"
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+	"github.com/AlvinTendio/minder/minder/repository"
+)
+
+const iapProducts = "iap.products"
+
+// defaultProductPlans applies when iap.products is not configured
+var defaultProductPlans = map[string]string{
+	"minder_premium_monthly": minder_model.SubscriptionPlanMonthly,
+	"minder_premium_yearly":  minder_model.SubscriptionPlanYearly,
+}
+
+// productPlan returns the plan a store product sells, from iap.products formatted as <productId>:<plan>,...
+func productPlan(config core_config.Config, productId string) (plan string, ok bool) {
+	plans := defaultProductPlans
+	if config.Get(iapProducts) != nil {
+		plans = config.GetMap(iapProducts)
+	}
+	plan, ok = plans[productId]
+	return
+}
+
+// ValidateReceipt activates the subscription a receipt from the app proves, the subscription is kept
+// in sync with its store from then on. A store subscription belongs to the first user validating it.
+// A revoked subscription is reactivated by a receipt of a later period, the user subscribed again.
+func (u *minderUsecaseImpl) ValidateReceipt(ctx context.Context, req *minder_model.ReceiptReq) (res *common.HTTPResponse, err error) {
+	id := uint64(req.UserId)
+
+	verifier, ok := u.Stores[req.Store]
+	if !ok {
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusBadRequest,
+			ResponseCode:    common.StatusBadRequestErrorResponseCode,
+			ResponseMessage: common.StatusBadRequestErrorResponseMessage,
+		}
+		return
+	}
+
+	purchase, err := verifier.VerifyReceipt(ctx, req.Receipt)
+	if errors.Is(err, iap.ErrInvalidReceipt) || errors.Is(err, iap.ErrNotSubscription) {
+		log.Println(ctx, "[usecase:iap] rejecting receipt:", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusBadRequest,
+			ResponseCode:    common.StatusBadRequestErrorResponseCode,
+			ResponseMessage: common.StatusBadRequestErrorResponseMessage,
+		}
+		return res, nil
+	}
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	plan, ok := productPlan(u.Config, purchase.ProductId)
+	if !ok {
+		log.Println(ctx, "[usecase:iap] rejecting receipt of unknown product", purchase.ProductId)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusBadRequest,
+			ResponseCode:    common.StatusBadRequestErrorResponseCode,
+			ResponseMessage: common.StatusBadRequestErrorResponseMessage,
+		}
+		return
+	}
+
+	now := u.Clock.Now()
+	if purchase.Revoked || !purchase.ExpiresAt.After(now) {
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusForbidden,
+			ResponseCode:    common.StatusForbiddenErrorResponseCode,
+			ResponseMessage: common.StatusForbiddenErrorResponseMessage,
+		}
+		return
+	}
+
+	return u.atomically(ctx, []uint64{id}, func(ctx context.Context) (res *common.HTTPResponse, err error) {
+		subscription, err := u.SubscriptionRepo.LockStoreSubscription(ctx, purchase.Store, purchase.OriginalTransactionId)
+
+		switch {
+		case err == nil && subscription.UserId != req.UserId:
+			res = &common.HTTPResponse{
+				HTTPStatus:      http.StatusConflict,
+				ResponseCode:    common.StatusConflictErrorResponseCode,
+				ResponseMessage: common.StatusConflictErrorResponseMessage,
+			}
+			return res, nil
+		case err == nil:
+			_, err = u.SubscriptionRepo.SyncStoreSubscription(ctx, subscription.SubscriptionId, plan, purchase.ExpiresAt, purchase.AutoRenew, now)
+		case errors.Is(err, repository.ErrNotFound):
+			_, err = u.SubscriptionRepo.InsertStoreSubscription(ctx, id, plan, purchase.Store, purchase.OriginalTransactionId,
+				purchase.PurchasedAt, purchase.ExpiresAt, purchase.AutoRenew)
+		}
+		if err != nil {
+			return
+		}
+
+		data, err := u.SubscriptionRepo.LockStoreSubscription(ctx, purchase.Store, purchase.OriginalTransactionId)
+		if err != nil {
+			return
+		}
+
+		// a receipt of the period that was revoked cannot bring it back
+		if data.Status == minder_model.SubscriptionStatusRevoked {
+			res = &common.HTTPResponse{
+				HTTPStatus:      http.StatusForbidden,
+				ResponseCode:    common.StatusForbiddenErrorResponseCode,
+				ResponseMessage: common.StatusForbiddenErrorResponseMessage,
+			}
+			return res, nil
+		}
+		data.Entitled = true
+
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusOK,
+			ResponseCode:    common.StatusOKResponseCode,
+			ResponseMessage: common.StatusOKResponseMessage,
+			Data:            data,
+		}
+		return
+	})
+}
+
+// HandleStoreNotification applies a server-to-server notification of an app store to the subscription
+// it is about. Notifications the store did not sign are refused, one seen before is acknowledged without
+// being applied again and one about a subscription no user validated yet is acknowledged and ignored,
+// its receipt brings the subscription's state along once validated. Stores deliver out of order, so a
+// notification ending the period before the stored one is older and only a revocation still applies.
+func (u *minderUsecaseImpl) HandleStoreNotification(ctx context.Context, store string, header http.Header, body []byte) (res *common.HTTPResponse, err error) {
+	verifier, ok := u.Stores[store]
+	if !ok {
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusNotFound,
+			ResponseCode:    common.StatusNotFoundErrorResponseCode,
+			ResponseMessage: common.StatusNotFoundErrorResponseMessage,
+		}
+		return
+	}
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+	}
+
+	notification, err := verifier.VerifyNotification(ctx, header, body)
+	switch {
+	case errors.Is(err, iap.ErrInvalidReceipt):
+		log.Println(ctx, "[usecase:iap] rejecting notification:", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusUnauthorized,
+			ResponseCode:    common.StatusUnauthorizedErrorResponseCode,
+			ResponseMessage: common.StatusUnauthorizedErrorResponseMessage,
+		}
+		return res, nil
+	case errors.Is(err, iap.ErrNotSubscription):
+		return res, nil
+	case err != nil:
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	err = u.MinderRepo.WithTx(ctx, func(ctx context.Context) error {
+		inserted, err := u.SubscriptionRepo.InsertStoreNotification(ctx, store, notification.NotificationId, notification.Type)
+		if err != nil || !inserted || notification.Purchase == nil {
+			return err
+		}
+
+		purchase := notification.Purchase
+		subscription, err := u.SubscriptionRepo.LockStoreSubscription(ctx, store, purchase.OriginalTransactionId)
+		if errors.Is(err, repository.ErrNotFound) {
+			log.Println(ctx, "[usecase:iap] ignoring", notification.Type, "of unknown subscription", purchase.OriginalTransactionId)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if purchase.Revoked {
+			_, err = u.SubscriptionRepo.RevokeSubscription(ctx, subscription.SubscriptionId, u.Clock.Now())
+			return err
+		}
+
+		if purchase.ExpiresAt.Before(subscription.CurrentPeriodEnd) {
+			log.Println(ctx, "[usecase:iap] ignoring late", notification.Type, "of subscription", purchase.OriginalTransactionId)
+			return nil
+		}
+
+		plan, ok := productPlan(u.Config, purchase.ProductId)
+		if !ok {
+			plan = subscription.Plan
+		}
+		_, err = u.SubscriptionRepo.SyncStoreSubscription(ctx, subscription.SubscriptionId, plan, purchase.ExpiresAt, purchase.AutoRenew, u.Clock.Now())
+		return err
+	})
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	return
+}
@@ -0,0 +1,220 @@
+package usecase
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/AlvinTendio/minder/common"
+	"github.com/AlvinTendio/minder/iap"
+	"github.com/AlvinTendio/minder/iap/appstore"
+	"github.com/AlvinTendio/minder/iap/fake"
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+)
+
+const (
+	testBundleId = "com.minder.app"
+
+	// testTransactionId is a renewing monthly subscription of the fake store's fixtures
+	testTransactionId = "2000000000000001"
+)
+
+// storeFixture wires the usecase to the fake App Store, the notifications it sends are only recorded
+// so each test decides when they arrive
+type storeFixture struct {
+	u             *minderUsecaseImpl
+	store         *memoryStore
+	fakeStore     *httptest.Server
+	notifications *webhookRecorder
+}
+
+func newStoreFixture(t *testing.T) *storeFixture {
+	t.Helper()
+	f := &storeFixture{notifications: newWebhookRecorder(t, nil)}
+	f.u, f.store = newMemoryUsecase()
+
+	server, err := fake.NewServer(fake.Config{BundleId: testBundleId, AppStoreWebhook: f.notifications.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.fakeStore = httptest.NewServer(server)
+	t.Cleanup(f.fakeStore.Close)
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(server.RootPEM())
+	f.u.Stores = map[string]iap.Verifier{iap.StoreAppStore: appstore.NewVerifier(roots, testBundleId)}
+	return f
+}
+
+// receipt returns the signed transaction the app sends for the subscription
+func (f *storeFixture) receipt(t *testing.T, transactionId string) string {
+	t.Helper()
+	resp, err := http.Get(f.fakeStore.URL + "/appstore/transactions/" + transactionId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		SignedTransaction string `json:"signedTransaction"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	return body.SignedTransaction
+}
+
+func (f *storeFixture) validate(t *testing.T, userId int64, receipt string) *common.HTTPResponse {
+	t.Helper()
+	res, err := f.u.ValidateReceipt(context.Background(), &minder_model.ReceiptReq{UserId: userId, Store: iap.StoreAppStore, Receipt: receipt})
+	if err != nil && res == nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+// notify makes the fake store apply event to the subscription and returns the notification it sent about it
+func (f *storeFixture) notify(t *testing.T, transactionId, event string) []byte {
+	t.Helper()
+	resp, err := http.Post(f.fakeStore.URL+"/appstore/transactions/"+transactionId+"/notify?event="+event, "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("%s answered %d", event, resp.StatusCode)
+	}
+	_, notification := f.notifications.last()
+	return notification
+}
+
+func (f *storeFixture) deliver(t *testing.T, notification []byte) {
+	t.Helper()
+	res, err := f.u.HandleStoreNotification(context.Background(), iap.StoreAppStore, http.Header{}, notification)
+	if err != nil || res.HTTPStatus != http.StatusOK {
+		t.Fatalf("HandleStoreNotification answered %+v, err %v", res, err)
+	}
+}
+
+// subscriptionOf returns the subscription of the store transaction
+func (f *storeFixture) subscriptionOf(t *testing.T, transactionId string) minder_model.SubscriptionData {
+	t.Helper()
+	f.store.mu.Lock()
+	id, ok := f.store.storeSubscriptions[iap.StoreAppStore+"/"+transactionId]
+	f.store.mu.Unlock()
+	if !ok {
+		t.Fatalf("no subscription of transaction %s", transactionId)
+	}
+	return f.store.subscription(id)
+}
+
+func TestStoreNotificationDeliveredLate(t *testing.T) {
+	f := newStoreFixture(t)
+	if res := f.validate(t, 1, f.receipt(t, testTransactionId)); res.HTTPStatus != http.StatusOK {
+		t.Fatalf("ValidateReceipt answered %d", res.HTTPStatus)
+	}
+
+	canceled := f.notify(t, testTransactionId, fake.EventCancel)
+	renewed := f.notify(t, testTransactionId, fake.EventRenew)
+
+	f.deliver(t, renewed)
+	current := f.subscriptionOf(t, testTransactionId)
+	if !current.AutoRenew {
+		t.Fatal("renewal left the subscription without auto renew")
+	}
+
+	// the cancellation was sent before the renewal and must not undo it
+	f.deliver(t, canceled)
+	if late := f.subscriptionOf(t, testTransactionId); !late.CurrentPeriodEnd.Equal(current.CurrentPeriodEnd) || !late.AutoRenew {
+		t.Errorf("late cancellation moved the period end from %v to %v, auto renew %v", current.CurrentPeriodEnd, late.CurrentPeriodEnd, late.AutoRenew)
+	}
+
+	// a revocation applies whatever its period
+	f.deliver(t, f.notify(t, testTransactionId, fake.EventRefund))
+	if revoked := f.subscriptionOf(t, testTransactionId); revoked.Status != minder_model.SubscriptionStatusRevoked {
+		t.Errorf("refund left the subscription %s, want %s", revoked.Status, minder_model.SubscriptionStatusRevoked)
+	}
+}
+
+func TestReceiptActivatesSubscription(t *testing.T) {
+	f := newStoreFixture(t)
+	receipt := f.receipt(t, testTransactionId)
+
+	res := f.validate(t, 1, receipt)
+	if res.HTTPStatus != http.StatusOK {
+		t.Fatalf("ValidateReceipt answered %d", res.HTTPStatus)
+	}
+	data := res.Data.(*minder_model.SubscriptionData)
+	if !data.Entitled || data.Plan != minder_model.SubscriptionPlanMonthly || data.Store != iap.StoreAppStore {
+		t.Errorf("receipt activated %+v, want an entitled monthly App Store subscription", data)
+	}
+	if _, err := f.u.SubscriptionRepo.GetEntitledSubscription(context.Background(), 1, time.Now(), 0); err != nil {
+		t.Errorf("user is not entitled after validating the receipt: %v", err)
+	}
+
+	// validating again keeps the one subscription, another user cannot take it over
+	if res := f.validate(t, 1, receipt); res.HTTPStatus != http.StatusOK {
+		t.Errorf("validating the receipt again answered %d", res.HTTPStatus)
+	}
+	if res := f.validate(t, 2, receipt); res.HTTPStatus != http.StatusConflict {
+		t.Errorf("validating the receipt of another user answered %d, want %d", res.HTTPStatus, http.StatusConflict)
+	}
+	if count := f.store.count(); count != 1 {
+		t.Errorf("receipts stored %d subscriptions, want 1", count)
+	}
+}
+
+func TestReceiptRejected(t *testing.T) {
+	f := newStoreFixture(t)
+	untrusted := newStoreFixture(t)
+
+	for name, test := range map[string]struct {
+		receipt string
+		status  int
+	}{
+		"expired":   {f.receipt(t, "2000000000000003"), http.StatusForbidden},
+		"revoked":   {f.receipt(t, "2000000000000004"), http.StatusForbidden},
+		"untrusted": {untrusted.receipt(t, testTransactionId), http.StatusBadRequest},
+		"malformed": {"not-a-receipt", http.StatusBadRequest},
+	} {
+		if res := f.validate(t, 1, test.receipt); res.HTTPStatus != test.status {
+			t.Errorf("%s receipt answered %d, want %d", name, res.HTTPStatus, test.status)
+		}
+	}
+	if count := f.store.count(); count != 0 {
+		t.Errorf("rejected receipts stored %d subscriptions", count)
+	}
+}
+
+func TestReceiptReactivatesRevokedSubscription(t *testing.T) {
+	f := newStoreFixture(t)
+	receipt := f.receipt(t, testTransactionId)
+	if res := f.validate(t, 1, receipt); res.HTTPStatus != http.StatusOK {
+		t.Fatalf("ValidateReceipt answered %d", res.HTTPStatus)
+	}
+	f.deliver(t, f.notify(t, testTransactionId, fake.EventRefund))
+
+	// the receipt of the refunded period does not undo the refund
+	if res := f.validate(t, 1, receipt); res.HTTPStatus != http.StatusForbidden {
+		t.Errorf("receipt of the refunded period answered %d, want %d", res.HTTPStatus, http.StatusForbidden)
+	}
+	if revoked := f.subscriptionOf(t, testTransactionId); revoked.Status != minder_model.SubscriptionStatusRevoked {
+		t.Fatalf("receipt of the refunded period left the subscription %s, want %s", revoked.Status, minder_model.SubscriptionStatusRevoked)
+	}
+
+	// subscribing again brings it back
+	f.notify(t, testTransactionId, fake.EventRenew)
+	res := f.validate(t, 1, f.receipt(t, testTransactionId))
+	if res.HTTPStatus != http.StatusOK || !res.Data.(*minder_model.SubscriptionData).Entitled {
+		t.Fatalf("receipt of the new period answered %+v, want an entitled subscription", res)
+	}
+	if _, err := f.u.SubscriptionRepo.GetEntitledSubscription(context.Background(), 1, time.Now(), 0); err != nil {
+		t.Errorf("user is not entitled after subscribing again: %v", err)
+	}
+	if count := f.store.count(); count != 1 {
+		t.Errorf("subscribing again stored %d subscriptions, want 1", count)
+	}
+}
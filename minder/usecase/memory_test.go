@@ -0,0 +1,340 @@
+package usecase
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+	"github.com/AlvinTendio/minder/minder/repository"
+)
+
+type memoryTxKey struct{}
+
+// memoryStore keeps payments and subscriptions in memory for the repositories below. A transaction
+// holds the store for itself and puts everything back when it fails, like the database would.
+type memoryStore struct {
+	mu                 sync.Mutex
+	lastId             int64
+	payments           map[int64]minder_model.PaymentData
+	paymentEvents      map[string]bool
+	subscriptions      map[int64]minder_model.SubscriptionData
+	storeSubscriptions map[string]int64
+	storeNotifications map[string]bool
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		payments:           make(map[int64]minder_model.PaymentData),
+		paymentEvents:      make(map[string]bool),
+		subscriptions:      make(map[int64]minder_model.SubscriptionData),
+		storeSubscriptions: make(map[string]int64),
+		storeNotifications: make(map[string]bool),
+	}
+}
+
+// do runs fn holding the store, unless ctx is in a transaction that already holds it
+func (s *memoryStore) do(ctx context.Context, fn func() error) error {
+	if ctx.Value(memoryTxKey{}) == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+	return fn()
+}
+
+func (s *memoryStore) nextId() int64 {
+	s.lastId++
+	return s.lastId
+}
+
+func (s *memoryStore) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(memoryTxKey{}) != nil {
+		return fn(ctx)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	payments, paymentEvents := maps.Clone(s.payments), maps.Clone(s.paymentEvents)
+	subscriptions, storeSubscriptions, storeNotifications := maps.Clone(s.subscriptions), maps.Clone(s.storeSubscriptions),
+		maps.Clone(s.storeNotifications)
+
+	err := fn(context.WithValue(ctx, memoryTxKey{}, true))
+	if err != nil {
+		s.payments, s.paymentEvents = payments, paymentEvents
+		s.subscriptions, s.storeSubscriptions, s.storeNotifications = subscriptions, storeSubscriptions, storeNotifications
+	}
+	return err
+}
+
+// memoryMinderRepo only offers transactions, user locks are implied by the store being held
+type memoryMinderRepo struct {
+	repository.MinderRepository
+	*memoryStore
+}
+
+func (r memoryMinderRepo) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return r.memoryStore.WithTx(ctx, fn)
+}
+
+func (r memoryMinderRepo) LockUsers(ctx context.Context, ids ...uint64) error {
+	return nil
+}
+
+type memoryPaymentRepo struct {
+	*memoryStore
+}
+
+func (r memoryPaymentRepo) InsertPayment(ctx context.Context, payment *minder_model.PaymentData) (data int64, err error) {
+	err = r.do(ctx, func() error {
+		data = r.nextId()
+		stored := *payment
+		stored.PaymentId = data
+		stored.Status = minder_model.PaymentStatusPending
+		r.payments[data] = stored
+		return nil
+	})
+	return
+}
+
+func (r memoryPaymentRepo) SetPaymentCheckout(ctx context.Context, paymentId int64, checkoutURL string) (data int64, err error) {
+	err = r.do(ctx, func() error {
+		payment, ok := r.payments[paymentId]
+		if !ok {
+			return nil
+		}
+		payment.CheckoutURL = checkoutURL
+		r.payments[paymentId] = payment
+		data = 1
+		return nil
+	})
+	return
+}
+
+func (r memoryPaymentRepo) GetPayment(ctx context.Context, paymentId uint64) (data *minder_model.PaymentData, err error) {
+	err = r.do(ctx, func() error {
+		payment, ok := r.payments[int64(paymentId)]
+		if !ok {
+			return repository.ErrNotFound
+		}
+		data = &payment
+		return nil
+	})
+	return
+}
+
+func (r memoryPaymentRepo) LockPaymentByOrder(ctx context.Context, orderId string) (data *minder_model.PaymentData, err error) {
+	err = r.do(ctx, func() error {
+		for _, payment := range r.payments {
+			if payment.OrderId == orderId {
+				data = &payment
+				return nil
+			}
+		}
+		return repository.ErrNotFound
+	})
+	return
+}
+
+func (r memoryPaymentRepo) UpdatePaymentStatus(ctx context.Context, paymentId int64, status string, subscriptionId *int64) (data int64, err error) {
+	err = r.do(ctx, func() error {
+		payment, ok := r.payments[paymentId]
+		if !ok {
+			return nil
+		}
+		payment.Status = status
+		if subscriptionId != nil {
+			payment.SubscriptionId = subscriptionId
+		}
+		r.payments[paymentId] = payment
+		data = 1
+		return nil
+	})
+	return
+}
+
+func (r memoryPaymentRepo) CountPaidPayments(ctx context.Context, subscriptionId int64) (data int64, err error) {
+	err = r.do(ctx, func() error {
+		for _, payment := range r.payments {
+			if payment.Status == minder_model.PaymentStatusPaid && payment.SubscriptionId != nil && *payment.SubscriptionId == subscriptionId {
+				data++
+			}
+		}
+		return nil
+	})
+	return
+}
+
+func (r memoryPaymentRepo) InsertPaymentEvent(ctx context.Context, eventId, orderId, eventType string) (inserted bool, err error) {
+	err = r.do(ctx, func() error {
+		inserted = !r.paymentEvents[eventId]
+		r.paymentEvents[eventId] = true
+		return nil
+	})
+	return
+}
+
+// memorySubscriptionRepo offers what payments and store purchases need
+type memorySubscriptionRepo struct {
+	repository.SubscriptionRepository
+	*memoryStore
+}
+
+func (r memorySubscriptionRepo) GetEntitledSubscription(ctx context.Context, userId uint64, now time.Time, grace time.Duration) (data *minder_model.SubscriptionData, err error) {
+	err = r.do(ctx, func() error {
+		for _, subscription := range r.subscriptions {
+			if subscription.UserId != int64(userId) ||
+				(subscription.Status != minder_model.SubscriptionStatusActive && subscription.Status != minder_model.SubscriptionStatusGrace) ||
+				!(subscription.CurrentPeriodEnd.After(now) || (subscription.AutoRenew && subscription.CurrentPeriodEnd.After(now.Add(-grace)))) {
+				continue
+			}
+			if data == nil || subscription.CurrentPeriodEnd.After(data.CurrentPeriodEnd) {
+				data = &subscription
+			}
+		}
+		if data == nil {
+			return repository.ErrNotFound
+		}
+		return nil
+	})
+	return
+}
+
+func (r memorySubscriptionRepo) InsertSubscription(ctx context.Context, userId uint64, plan string, startedAt, periodEnd time.Time) (data int64, err error) {
+	return r.InsertStoreSubscription(ctx, userId, plan, minder_model.SubscriptionStoreWeb, "", startedAt, periodEnd, true)
+}
+
+func (r memorySubscriptionRepo) ExtendSubscription(ctx context.Context, subscriptionId int64, plan string, periodEnd time.Time) (data int64, err error) {
+	err = r.do(ctx, func() error {
+		subscription := r.subscriptions[subscriptionId]
+		subscription.Plan = plan
+		subscription.Status = minder_model.SubscriptionStatusActive
+		subscription.CurrentPeriodEnd = periodEnd
+		subscription.GraceEndsAt = nil
+		r.subscriptions[subscriptionId] = subscription
+		data = 1
+		return nil
+	})
+	return
+}
+
+func (r memorySubscriptionRepo) ShortenSubscription(ctx context.Context, subscriptionId int64, periodEnd time.Time) (data int64, err error) {
+	err = r.do(ctx, func() error {
+		subscription := r.subscriptions[subscriptionId]
+		subscription.CurrentPeriodEnd = periodEnd
+		r.subscriptions[subscriptionId] = subscription
+		data = 1
+		return nil
+	})
+	return
+}
+
+func (r memorySubscriptionRepo) RevokeSubscription(ctx context.Context, subscriptionId int64, now time.Time) (data int64, err error) {
+	err = r.do(ctx, func() error {
+		subscription := r.subscriptions[subscriptionId]
+		subscription.Status = minder_model.SubscriptionStatusRevoked
+		subscription.AutoRenew = false
+		r.subscriptions[subscriptionId] = subscription
+		data = 1
+		return nil
+	})
+	return
+}
+
+func (r memorySubscriptionRepo) LockSubscription(ctx context.Context, subscriptionId int64) (data *minder_model.SubscriptionData, err error) {
+	err = r.do(ctx, func() error {
+		subscription, ok := r.subscriptions[subscriptionId]
+		if !ok {
+			return repository.ErrNotFound
+		}
+		data = &subscription
+		return nil
+	})
+	return
+}
+
+func (r memorySubscriptionRepo) LockStoreSubscription(ctx context.Context, store, storeTransactionId string) (data *minder_model.SubscriptionData, err error) {
+	err = r.do(ctx, func() error {
+		id, ok := r.storeSubscriptions[store+"/"+storeTransactionId]
+		if !ok {
+			return repository.ErrNotFound
+		}
+		subscription := r.subscriptions[id]
+		data = &subscription
+		return nil
+	})
+	return
+}
+
+func (r memorySubscriptionRepo) InsertStoreSubscription(ctx context.Context, userId uint64, plan, store, storeTransactionId string,
+	startedAt, periodEnd time.Time, autoRenew bool) (data int64, err error) {
+	err = r.do(ctx, func() error {
+		data = r.nextId()
+		r.subscriptions[data] = minder_model.SubscriptionData{
+			SubscriptionId:   data,
+			UserId:           int64(userId),
+			Plan:             plan,
+			Status:           minder_model.SubscriptionStatusActive,
+			Store:            store,
+			AutoRenew:        autoRenew,
+			StartedAt:        startedAt,
+			CurrentPeriodEnd: periodEnd,
+		}
+		if storeTransactionId != "" {
+			r.storeSubscriptions[store+"/"+storeTransactionId] = data
+		}
+		return nil
+	})
+	return
+}
+
+func (r memorySubscriptionRepo) SyncStoreSubscription(ctx context.Context, subscriptionId int64, plan string, periodEnd time.Time,
+	autoRenew bool, now time.Time) (data int64, err error) {
+	err = r.do(ctx, func() error {
+		subscription := r.subscriptions[subscriptionId]
+		if periodEnd.Before(subscription.CurrentPeriodEnd) ||
+			(subscription.Status == minder_model.SubscriptionStatusRevoked && !periodEnd.After(subscription.CurrentPeriodEnd)) {
+			return nil
+		}
+		subscription.Plan = plan
+		subscription.Status = minder_model.SubscriptionStatusActive
+		subscription.CurrentPeriodEnd = periodEnd
+		subscription.GraceEndsAt = nil
+		subscription.AutoRenew = autoRenew
+		r.subscriptions[subscriptionId] = subscription
+		data = 1
+		return nil
+	})
+	return
+}
+
+func (r memorySubscriptionRepo) InsertStoreNotification(ctx context.Context, store, notificationId, notificationType string) (inserted bool, err error) {
+	err = r.do(ctx, func() error {
+		inserted = !r.storeNotifications[store+"/"+notificationId]
+		r.storeNotifications[store+"/"+notificationId] = true
+		return nil
+	})
+	return
+}
+
+// subscription returns the stored subscription
+func (s *memoryStore) subscription(id int64) minder_model.SubscriptionData {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.subscriptions[id]
+}
+
+// count returns how many subscriptions are stored
+func (s *memoryStore) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subscriptions)
+}
+
+// payment returns the stored payment
+func (s *memoryStore) payment(id int64) minder_model.PaymentData {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.payments[id]
+}
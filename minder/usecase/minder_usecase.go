@@ -2,15 +2,55 @@ package usecase
 
 import (
 	"context"
+	"net/http"
 
 	"github.com/AlvinTendio/minder/common"
 	minder_model "github.com/AlvinTendio/minder/minder/model"
+	"github.com/AlvinTendio/minder/stream"
 )
 
 type MinderUsecase interface {
 	Register(ctx context.Context, req *minder_model.RegisterReq) (res *common.HTTPResponse, err error)
 	Login(ctx context.Context, req *minder_model.LoginReq) (res *common.HTTPResponse, err error)
-	UpgradeAccount(ctx context.Context, id uint64) (res *common.HTTPResponse, err error)
+	UpgradeAccount(ctx context.Context, id uint64, req *minder_model.UpgradeReq) (res *common.HTTPResponse, err error)
+	GetSubscription(ctx context.Context, req *minder_model.SubscriptionReq) (res *common.HTTPResponse, err error)
+	CancelSubscription(ctx context.Context, req *minder_model.CancelSubscriptionReq) (res *common.HTTPResponse, err error)
+	HandlePaymentWebhook(ctx context.Context, header http.Header, body []byte) (res *common.HTTPResponse, err error)
+	RefundPayment(ctx context.Context, paymentId uint64) (res *common.HTTPResponse, err error)
+	ValidateReceipt(ctx context.Context, req *minder_model.ReceiptReq) (res *common.HTTPResponse, err error)
+	HandleStoreNotification(ctx context.Context, store string, header http.Header, body []byte) (res *common.HTTPResponse, err error)
+	UpdateTimeZone(ctx context.Context, id uint64, req *minder_model.TimeZoneReq) (res *common.HTTPResponse, err error)
+	UpdateIncognito(ctx context.Context, id uint64, req *minder_model.IncognitoReq) (res *common.HTTPResponse, err error)
 	GetTargetUser(ctx context.Context, id uint64) (res *common.HTTPResponse, err error)
+	GetDeck(ctx context.Context, req *minder_model.DeckReq) (res *common.HTTPResponse, err error)
 	Swipe(ctx context.Context, req *minder_model.SwipeReq) (res *common.HTTPResponse, err error)
+	Rewind(ctx context.Context, req *minder_model.RewindReq) (res *common.HTTPResponse, err error)
+	GetMatches(ctx context.Context, req *minder_model.MatchListReq) (res *common.HTTPResponse, err error)
+	Unmatch(ctx context.Context, matchId uint64, req *minder_model.UnmatchReq) (res *common.HTTPResponse, err error)
+	GetLikesReceived(ctx context.Context, req *minder_model.LikesReceivedReq) (res *common.HTTPResponse, err error)
+	LikeBack(ctx context.Context, likerId uint64, req *minder_model.LikeBackReq) (res *common.HTTPResponse, err error)
+	ActivateBoost(ctx context.Context, req *minder_model.BoostReq) (res *common.HTTPResponse, err error)
+	GetBoost(ctx context.Context, req *minder_model.BoostStatusReq) (res *common.HTTPResponse, err error)
+	GetQuota(ctx context.Context, req *minder_model.QuotaReq) (res *common.HTTPResponse, err error)
+	GetEntitlements(ctx context.Context, req *minder_model.EntitlementsReq) (res *common.HTTPResponse, err error)
+	CreateEntitlementGrant(ctx context.Context, req *minder_model.EntitlementGrantReq) (res *common.HTTPResponse, err error)
+	CreatePromoCode(ctx context.Context, req *minder_model.PromoCodeReq) (res *common.HTTPResponse, err error)
+	RedeemPromo(ctx context.Context, req *minder_model.RedeemPromoReq) (res *common.HTTPResponse, err error)
+	BlockUser(ctx context.Context, blockedId uint64, req *minder_model.BlockReq) (res *common.HTTPResponse, err error)
+	ReportUser(ctx context.Context, reportedId uint64, req *minder_model.ReportReq) (res *common.HTTPResponse, err error)
+	GetReports(ctx context.Context, req *minder_model.ReportListReq) (res *common.HTTPResponse, err error)
+	ResolveReport(ctx context.Context, reportId uint64, req *minder_model.ResolveReportReq) (res *common.HTTPResponse, err error)
+	GetNotifications(ctx context.Context, req *minder_model.NotificationListReq) (res *common.HTTPResponse, err error)
+	SendMessage(ctx context.Context, matchId uint64, req *minder_model.SendMessageReq) (res *common.HTTPResponse, err error)
+	GetMessages(ctx context.Context, matchId uint64, req *minder_model.MessageHistoryReq) (res *common.HTTPResponse, err error)
+	GetConversations(ctx context.Context, req *minder_model.ConversationListReq) (res *common.HTTPResponse, err error)
+	MarkRead(ctx context.Context, matchId uint64, req *minder_model.ReadReq) (res *common.HTTPResponse, err error)
+	Typing(ctx context.Context, matchId uint64, req *minder_model.TypingReq) (res *common.HTTPResponse, err error)
+	GetUnreadCounts(ctx context.Context, req *minder_model.UnreadReq) (res *common.HTTPResponse, err error)
+	RegisterDevice(ctx context.Context, req *minder_model.DeviceTokenReq) (res *common.HTTPResponse, err error)
+	RemoveDevice(ctx context.Context, req *minder_model.RemoveDeviceTokenReq) (res *common.HTTPResponse, err error)
+	GetNotificationPreferences(ctx context.Context, req *minder_model.NotificationPreferencesReq) (res *common.HTTPResponse, err error)
+	UpdateNotificationPreferences(ctx context.Context, req *minder_model.UpdateNotificationPreferencesReq) (res *common.HTTPResponse, err error)
+	CreateQuotaOverride(ctx context.Context, req *minder_model.QuotaOverrideReq) (res *common.HTTPResponse, err error)
+	Stream(ctx context.Context, req *minder_model.StreamReq) (events <-chan stream.Event, res *common.HTTPResponse, err error)
 }
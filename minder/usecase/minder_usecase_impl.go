@@ -2,26 +2,77 @@ package usecase
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 
 	"github.com/AlvinTendio/minder/common"
+	core_config "github.com/AlvinTendio/minder/config"
+	"github.com/AlvinTendio/minder/iap"
 	minder_model "github.com/AlvinTendio/minder/minder/model"
 	"github.com/AlvinTendio/minder/minder/repository"
+	"github.com/AlvinTendio/minder/payment"
+	"github.com/AlvinTendio/minder/stream"
+)
+
+const (
+	defaultPageSize   = 20
+	defaultDeckSize   = 10
+	candidatePoolSize = 50
+
+	trialDays        = "trial.days"
+	defaultTrialDays = 7
 )
 
 type minderUsecaseImpl struct {
 	MinderRepo repository.MinderRepository
+	ChatRepo   repository.ChatRepository
+	PushRepo   repository.PushRepository
+	// SubscriptionRepo is only for managing subscriptions, premium checks go through Entitlements
+	SubscriptionRepo repository.SubscriptionRepository
+	PaymentRepo      repository.PaymentRepository
+	Payments         payment.Provider
+	EntitlementRepo  repository.EntitlementRepository
+	PromoRepo        repository.PromoRepository
+	Stores           map[string]iap.Verifier
+	Ranker           Ranker
+	Quota            QuotaService
+	Entitlements     EntitlementService
+	SwipeEvents      SwipeEventPublisher
+	Hub              stream.Hub
+	Clock            Clock
+	Config           core_config.Config
 }
 
-func NewMinderUsecaseImpl(minderRepo repository.MinderRepository) MinderUsecase {
+func NewMinderUsecaseImpl(minderRepo repository.MinderRepository, chatRepo repository.ChatRepository, pushRepo repository.PushRepository,
+	subscriptionRepo repository.SubscriptionRepository, paymentRepo repository.PaymentRepository, payments payment.Provider,
+	entitlementRepo repository.EntitlementRepository, promoRepo repository.PromoRepository, stores map[string]iap.Verifier,
+	ranker Ranker, quota QuotaService, entitlements EntitlementService, swipeEvents SwipeEventPublisher, hub stream.Hub,
+	clock Clock, config core_config.Config) MinderUsecase {
 	return &minderUsecaseImpl{
-		MinderRepo: minderRepo,
+		MinderRepo:       minderRepo,
+		ChatRepo:         chatRepo,
+		PushRepo:         pushRepo,
+		SubscriptionRepo: subscriptionRepo,
+		PaymentRepo:      paymentRepo,
+		Payments:         payments,
+		EntitlementRepo:  entitlementRepo,
+		PromoRepo:        promoRepo,
+		Stores:           stores,
+		Ranker:           ranker,
+		Quota:            quota,
+		Entitlements:     entitlements,
+		SwipeEvents:      swipeEvents,
+		Hub:              hub,
+		Clock:            clock,
+		Config:           config,
 	}
 }
 
+// Register creates the user, every new user gets a trial of trial.days days
 func (u *minderUsecaseImpl) Register(ctx context.Context, req *minder_model.RegisterReq) (res *common.HTTPResponse, err error) {
-	data, err := u.MinderRepo.Register(ctx, req)
+	trialEndsAt := u.Clock.Now().AddDate(0, 0, int(configInt(u.Config, trialDays, defaultTrialDays)))
+	data, err := u.MinderRepo.Register(ctx, req, trialEndsAt)
 
 	if err != nil || data == 0 {
 		log.Println(ctx, "Error ", err)
@@ -55,6 +106,26 @@ func (u *minderUsecaseImpl) Login(ctx context.Context, req *minder_model.LoginRe
 		return
 	}
 
+	if data.Restricted {
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusForbidden,
+			ResponseCode:    common.StatusForbiddenErrorResponseCode,
+			ResponseMessage: common.StatusForbiddenErrorResponseMessage,
+		}
+		return
+	}
+
+	data.IsUpgraded, err = u.Entitlements.IsPremium(ctx, uint64(data.UserId))
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
 	res = &common.HTTPResponse{
 		HTTPStatus:      http.StatusOK,
 		ResponseCode:    common.StatusOKResponseCode,
@@ -64,10 +135,57 @@ func (u *minderUsecaseImpl) Login(ctx context.Context, req *minder_model.LoginRe
 
 	return
 }
-func (u *minderUsecaseImpl) UpgradeAccount(ctx context.Context, id uint64) (res *common.HTTPResponse, err error) {
-	data, err := u.MinderRepo.UpgradeAccount(ctx, id)
 
-	if err != nil || data == 0 {
+// UpdateTimeZone sets the time zone the user's daily quotas reset in
+func (u *minderUsecaseImpl) UpdateTimeZone(ctx context.Context, id uint64, req *minder_model.TimeZoneReq) (res *common.HTTPResponse, err error) {
+	_, err = u.MinderRepo.UpdateTimeZone(ctx, id, req.TimeZone)
+
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+	}
+
+	return
+}
+
+// UpdateIncognito turns incognito on or off, turning it on takes the incognito feature
+func (u *minderUsecaseImpl) UpdateIncognito(ctx context.Context, id uint64, req *minder_model.IncognitoReq) (res *common.HTTPResponse, err error) {
+	if *req.Enabled {
+		allowed, err := u.Entitlements.Allowed(ctx, id, minder_model.FeatureIncognito)
+		if err != nil {
+			log.Println(ctx, "Error ", err)
+			res = &common.HTTPResponse{
+				HTTPStatus:      http.StatusInternalServerError,
+				ResponseCode:    common.StatusInternalServerErrorResponseCode,
+				ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+			}
+			return res, err
+		}
+
+		if !allowed {
+			res = &common.HTTPResponse{
+				HTTPStatus:      http.StatusForbidden,
+				ResponseCode:    common.StatusForbiddenErrorResponseCode,
+				ResponseMessage: common.StatusForbiddenErrorResponseMessage,
+			}
+			return res, nil
+		}
+	}
+
+	_, err = u.MinderRepo.UpdateIncognito(ctx, id, *req.Enabled)
+
+	if err != nil {
 		log.Println(ctx, "Error ", err)
 		res = &common.HTTPResponse{
 			HTTPStatus:      http.StatusInternalServerError,
@@ -85,8 +203,15 @@ func (u *minderUsecaseImpl) UpgradeAccount(ctx context.Context, id uint64) (res
 
 	return
 }
+
 func (u *minderUsecaseImpl) GetTargetUser(ctx context.Context, id uint64) (res *common.HTTPResponse, err error) {
-	upgradeStatus, err := u.MinderRepo.GetUserUpgradeStatus(ctx, id)
+	return u.atomically(ctx, []uint64{id}, func(ctx context.Context) (*common.HTTPResponse, error) {
+		return u.getTargetUser(ctx, id)
+	})
+}
+
+func (u *minderUsecaseImpl) getTargetUser(ctx context.Context, id uint64) (res *common.HTTPResponse, err error) {
+	viewQuota, err := u.Quota.Status(ctx, id, minder_model.QuotaActionView)
 
 	if err != nil {
 		log.Println(ctx, "Error ", err)
@@ -98,10 +223,14 @@ func (u *minderUsecaseImpl) GetTargetUser(ctx context.Context, id uint64) (res *
 		return
 	}
 
-	if !upgradeStatus {
-		viewCount, err := u.MinderRepo.GetUserViewCount(ctx, id)
+	if viewQuota.Exhausted() {
+		return quotaExceeded(viewQuota), nil
+	}
 
-		if err != nil || viewCount == nil || *viewCount >= 10 {
+	// cards reserved in the deck spend the quota once swiped, so they are not left to view here
+	if viewQuota.Limit != minder_model.QuotaUnlimited {
+		today, err := u.Quota.Today(ctx, id)
+		if err != nil {
 			log.Println(ctx, "Error ", err)
 			res = &common.HTTPResponse{
 				HTTPStatus:      http.StatusInternalServerError,
@@ -110,11 +239,26 @@ func (u *minderUsecaseImpl) GetTargetUser(ctx context.Context, id uint64) (res *
 			}
 			return res, err
 		}
+
+		reservedCount, err := u.MinderRepo.GetUserReservedCount(ctx, id, today)
+		if err != nil {
+			log.Println(ctx, "Error ", err)
+			res = &common.HTTPResponse{
+				HTTPStatus:      http.StatusInternalServerError,
+				ResponseCode:    common.StatusInternalServerErrorResponseCode,
+				ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+			}
+			return res, err
+		}
+
+		if reservedCount >= viewQuota.Remaining {
+			return quotaExceeded(viewQuota), nil
+		}
 	}
 
-	data, err := u.MinderRepo.GetTargetUser(ctx, id)
+	viewer, err := u.MinderRepo.GetRankingProfile(ctx, id)
 
-	if err != nil || data == nil {
+	if err != nil {
 		log.Println(ctx, "Error ", err)
 		res = &common.HTTPResponse{
 			HTTPStatus:      http.StatusInternalServerError,
@@ -124,6 +268,27 @@ func (u *minderUsecaseImpl) GetTargetUser(ctx context.Context, id uint64) (res *
 		return
 	}
 
+	candidates, err := u.MinderRepo.GetCandidates(ctx, id, u.discoveryRules(), candidatePoolSize)
+	if err == nil {
+		candidates, err = u.visibleCandidates(ctx, candidates)
+	}
+
+	if err != nil || len(candidates) == 0 {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	top := superLikesFirst(u.Ranker.Rank(ctx, viewer, candidates))[0]
+	data := &top.TargetUserData
+	if top.Boosted {
+		u.recordBoostImpression(ctx, top.UserId)
+	}
+
 	total, err := u.MinderRepo.InsertSwipe(ctx, id, data.UserId)
 
 	if err != nil || total == 0 {
@@ -144,10 +309,437 @@ func (u *minderUsecaseImpl) GetTargetUser(ctx context.Context, id uint64) (res *
 	}
 	return
 }
+
+// Swipe answers a card served to the user. Repeating a swipe returns the same result without
+// spending quota again, a client swipe id makes retries safe even after the user swiped further.
 func (u *minderUsecaseImpl) Swipe(ctx context.Context, req *minder_model.SwipeReq) (res *common.HTTPResponse, err error) {
-	data, err := u.MinderRepo.UpdateSwipe(ctx, req)
+	var data *minder_model.SwipeRes
+	var duplicate bool
+	res, err = u.atomically(ctx, []uint64{uint64(req.Id), uint64(req.TargetId)}, func(ctx context.Context) (res *common.HTTPResponse, err error) {
+		current, err := u.MinderRepo.GetSwipeAction(ctx, req)
+		if res = swipeErrorResponse(err); res != nil {
+			return res, nil
+		}
+		if err != nil {
+			return
+		}
 
-	if err != nil || data == 0 {
+		duplicate = current == req.Action
+		if !duplicate && req.Action != minder_model.SwipeActionPass {
+			res, err = u.checkQuota(ctx, uint64(req.Id), req.Action)
+			if res != nil || err != nil {
+				return
+			}
+		}
+
+		data, err = u.MinderRepo.Swipe(ctx, req)
+		if res = swipeErrorResponse(err); res != nil {
+			return res, nil
+		}
+
+		if err != nil || data == nil {
+			log.Println(ctx, "Error ", err)
+			res = &common.HTTPResponse{
+				HTTPStatus:      http.StatusInternalServerError,
+				ResponseCode:    common.StatusInternalServerErrorResponseCode,
+				ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+			}
+			return
+		}
+
+		if !duplicate {
+			err = u.SwipeEvents.Publish(ctx, minder_model.SwipeEvent{UserId: req.Id, TargetId: req.TargetId, Action: req.Action})
+		}
+		return
+	})
+	if res != nil || err != nil {
+		return
+	}
+	if !duplicate {
+		u.publishSwipe(ctx, req.Id, req.TargetId, req.Action, data)
+	}
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+		Data:            data,
+	}
+
+	return
+}
+
+// swipeErrorResponse maps the swipe errors a client can cause to their response, nil for any other error
+func swipeErrorResponse(err error) *common.HTTPResponse {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return &common.HTTPResponse{
+			HTTPStatus:      http.StatusNotFound,
+			ResponseCode:    common.StatusNotFoundErrorResponseCode,
+			ResponseMessage: common.StatusNotFoundErrorResponseMessage,
+		}
+	case errors.Is(err, repository.ErrSwipeMatched), errors.Is(err, repository.ErrSwipeConflict):
+		return &common.HTTPResponse{
+			HTTPStatus:      http.StatusConflict,
+			ResponseCode:    common.StatusConflictErrorResponseCode,
+			ResponseMessage: common.StatusConflictErrorResponseMessage,
+		}
+	}
+	return nil
+}
+
+// atomically runs fn in one transaction holding the row locks of the given users, so concurrent
+// requests of the same user wait for each other and never spend more quota than the user has.
+// A response returned without an error is committed, an error rolls everything back and
+// ErrNotFound, for instance when none of the users exists, answers 404.
+func (u *minderUsecaseImpl) atomically(ctx context.Context, ids []uint64, fn func(ctx context.Context) (*common.HTTPResponse, error)) (res *common.HTTPResponse, err error) {
+	err = u.MinderRepo.WithTx(ctx, func(ctx context.Context) error {
+		if err := u.MinderRepo.LockUsers(ctx, ids...); err != nil {
+			return err
+		}
+		res, err = fn(ctx)
+		return err
+	})
+
+	if errors.Is(err, repository.ErrNotFound) && res == nil {
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusNotFound,
+			ResponseCode:    common.StatusNotFoundErrorResponseCode,
+			ResponseMessage: common.StatusNotFoundErrorResponseMessage,
+		}
+		return res, nil
+	}
+
+	if err != nil && res == nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+	}
+
+	return
+}
+
+// checkQuota returns a response when the user may not use the action right now,
+// a zero limit means the user's tier does not have the action at all
+func (u *minderUsecaseImpl) checkQuota(ctx context.Context, id uint64, action string) (res *common.HTTPResponse, err error) {
+	quota, err := u.Quota.Status(ctx, id, action)
+
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	switch {
+	case quota.Limit == 0:
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusForbidden,
+			ResponseCode:    common.StatusForbiddenErrorResponseCode,
+			ResponseMessage: common.StatusForbiddenErrorResponseMessage,
+		}
+	case quota.Exhausted():
+		res = quotaExceeded(quota)
+	}
+
+	return
+}
+
+func (u *minderUsecaseImpl) GetMatches(ctx context.Context, req *minder_model.MatchListReq) (res *common.HTTPResponse, err error) {
+	page, size := normalizePage(req.Page, req.Size)
+	id := uint64(req.UserId)
+
+	total, err := u.MinderRepo.CountMatches(ctx, id)
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	data, err := u.MinderRepo.GetMatches(ctx, id, size, (page-1)*size)
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+		Data: &minder_model.MatchListRes{
+			Matches: data,
+			Pagination: minder_model.Pagination{
+				Page:  page,
+				Size:  size,
+				Total: total,
+			},
+		},
+	}
+
+	return
+}
+
+func (u *minderUsecaseImpl) Unmatch(ctx context.Context, matchId uint64, req *minder_model.UnmatchReq) (res *common.HTTPResponse, err error) {
+	data, err := u.MinderRepo.Unmatch(ctx, matchId, uint64(req.UserId))
+
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	if data == 0 {
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusNotFound,
+			ResponseCode:    common.StatusNotFoundErrorResponseCode,
+			ResponseMessage: common.StatusNotFoundErrorResponseMessage,
+		}
+		return
+	}
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+	}
+
+	return
+}
+
+// GetDeck returns a batch of cards for the user. Cards already reserved and not answered yet come
+// first, the rest are new candidates reserved for the user. Reserved cards only count as views once
+// the user swipes on them, but a free user never gets more cards than views left for today.
+func (u *minderUsecaseImpl) GetDeck(ctx context.Context, req *minder_model.DeckReq) (res *common.HTTPResponse, err error) {
+	return u.atomically(ctx, []uint64{uint64(req.UserId)}, func(ctx context.Context) (*common.HTTPResponse, error) {
+		return u.getDeck(ctx, req)
+	})
+}
+
+func (u *minderUsecaseImpl) getDeck(ctx context.Context, req *minder_model.DeckReq) (res *common.HTTPResponse, err error) {
+	id := uint64(req.UserId)
+	size := req.Size
+	if size < 1 {
+		size = defaultDeckSize
+	}
+
+	afterSwipeId, err := decodeCursor(req.Cursor)
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusBadRequest,
+			ResponseCode:    common.StatusBadRequestErrorResponseCode,
+			ResponseMessage: common.StatusBadRequestErrorResponseMessage,
+		}
+		return res, nil
+	}
+
+	viewQuota, err := u.Quota.Status(ctx, id, minder_model.QuotaActionView)
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	today, err := u.Quota.Today(ctx, id)
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	cards, err := u.MinderRepo.GetReservedCards(ctx, id, today, afterSwipeId, size)
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	newCards := size - len(cards)
+	if viewQuota.Limit != minder_model.QuotaUnlimited && newCards > 0 {
+		reservedCount, err := u.MinderRepo.GetUserReservedCount(ctx, id, today)
+		if err != nil {
+			log.Println(ctx, "Error ", err)
+			res = &common.HTTPResponse{
+				HTTPStatus:      http.StatusInternalServerError,
+				ResponseCode:    common.StatusInternalServerErrorResponseCode,
+				ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+			}
+			return res, err
+		}
+
+		newCards = min(newCards, int(max(viewQuota.Remaining-reservedCount, 0)))
+	}
+
+	if newCards > 0 {
+		viewer, err := u.MinderRepo.GetRankingProfile(ctx, id)
+		if err != nil {
+			log.Println(ctx, "Error ", err)
+			res = &common.HTTPResponse{
+				HTTPStatus:      http.StatusInternalServerError,
+				ResponseCode:    common.StatusInternalServerErrorResponseCode,
+				ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+			}
+			return res, err
+		}
+
+		candidates, err := u.MinderRepo.GetCandidates(ctx, id, u.discoveryRules(), candidatePoolSize)
+		if err == nil {
+			candidates, err = u.visibleCandidates(ctx, candidates)
+		}
+		if err != nil {
+			log.Println(ctx, "Error ", err)
+			res = &common.HTTPResponse{
+				HTTPStatus:      http.StatusInternalServerError,
+				ResponseCode:    common.StatusInternalServerErrorResponseCode,
+				ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+			}
+			return res, err
+		}
+
+		ranked := superLikesFirst(u.Ranker.Rank(ctx, viewer, candidates))
+		for _, candidate := range ranked[:min(newCards, len(ranked))] {
+			swipeId, err := u.MinderRepo.ReserveSwipe(ctx, id, candidate.UserId)
+			if err != nil {
+				log.Println(ctx, "Error ", err)
+				res = &common.HTTPResponse{
+					HTTPStatus:      http.StatusInternalServerError,
+					ResponseCode:    common.StatusInternalServerErrorResponseCode,
+					ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+				}
+				return res, err
+			}
+			cards = append(cards, &minder_model.DeckCard{SwipeId: swipeId, TargetUserData: candidate.TargetUserData})
+			if candidate.Boosted {
+				u.recordBoostImpression(ctx, candidate.UserId)
+			}
+		}
+	}
+
+	if len(cards) == 0 && viewQuota.Exhausted() {
+		return quotaExceeded(viewQuota), nil
+	}
+
+	// Rewound cards jump the queue with their original swipe id, so the cursor
+	// follows the newest card served rather than the last one in the list.
+	deck := &minder_model.DeckRes{Cards: cards, NextCursor: req.Cursor}
+	lastSwipeId := afterSwipeId
+	for _, card := range cards {
+		lastSwipeId = max(lastSwipeId, card.SwipeId)
+	}
+	if lastSwipeId > afterSwipeId {
+		deck.NextCursor = encodeCursor(lastSwipeId)
+	}
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+		Data:            deck,
+	}
+
+	return
+}
+
+func (u *minderUsecaseImpl) Rewind(ctx context.Context, req *minder_model.RewindReq) (res *common.HTTPResponse, err error) {
+	return u.atomically(ctx, []uint64{uint64(req.Id)}, func(ctx context.Context) (*common.HTTPResponse, error) {
+		return u.rewind(ctx, req)
+	})
+}
+
+func (u *minderUsecaseImpl) rewind(ctx context.Context, req *minder_model.RewindReq) (res *common.HTTPResponse, err error) {
+	id := uint64(req.Id)
+
+	res, err = u.checkQuota(ctx, id, minder_model.QuotaActionRewind)
+	if res != nil || err != nil {
+		return
+	}
+
+	targetId, err := u.MinderRepo.Rewind(ctx, id)
+
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusNotFound,
+			ResponseCode:    common.StatusNotFoundErrorResponseCode,
+			ResponseMessage: common.StatusNotFoundErrorResponseMessage,
+		}
+		return res, nil
+	case errors.Is(err, repository.ErrSwipeMatched):
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusConflict,
+			ResponseCode:    common.StatusConflictErrorResponseCode,
+			ResponseMessage: common.StatusConflictErrorResponseMessage,
+		}
+		return res, nil
+	case err != nil:
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+		Data:            &minder_model.RewindRes{TargetId: targetId},
+	}
+
+	return
+}
+
+// GetLikesReceived lists users waiting for an answer to their like. Users not entitled to see likes only see how many there are.
+func (u *minderUsecaseImpl) GetLikesReceived(ctx context.Context, req *minder_model.LikesReceivedReq) (res *common.HTTPResponse, err error) {
+	page, size := normalizePage(req.Page, req.Size)
+	id := uint64(req.UserId)
+
+	seeLikes, err := u.Entitlements.Allowed(ctx, id, minder_model.FeatureSeeLikes)
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	total, err := u.MinderRepo.CountLikesReceived(ctx, id)
+	if err != nil {
 		log.Println(ctx, "Error ", err)
 		res = &common.HTTPResponse{
 			HTTPStatus:      http.StatusInternalServerError,
@@ -157,11 +749,99 @@ func (u *minderUsecaseImpl) Swipe(ctx context.Context, req *minder_model.SwipeRe
 		return
 	}
 
+	likes := &minder_model.LikesReceivedRes{
+		Blurred: !seeLikes,
+		Pagination: minder_model.Pagination{
+			Page:  page,
+			Size:  size,
+			Total: total,
+		},
+	}
+
+	if seeLikes {
+		likes.Likes, err = u.MinderRepo.GetLikesReceived(ctx, id, size, (page-1)*size)
+		if err != nil {
+			log.Println(ctx, "Error ", err)
+			res = &common.HTTPResponse{
+				HTTPStatus:      http.StatusInternalServerError,
+				ResponseCode:    common.StatusInternalServerErrorResponseCode,
+				ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+			}
+			return
+		}
+	}
+
 	res = &common.HTTPResponse{
 		HTTPStatus:      http.StatusOK,
 		ResponseCode:    common.StatusOKResponseCode,
 		ResponseMessage: common.StatusOKResponseMessage,
+		Data:            likes,
 	}
 
 	return
 }
+
+// LikeBack answers a received like from the likes list, which matches both users right away
+func (u *minderUsecaseImpl) LikeBack(ctx context.Context, likerId uint64, req *minder_model.LikeBackReq) (res *common.HTTPResponse, err error) {
+	id := uint64(req.Id)
+
+	seeLikes, err := u.Entitlements.Allowed(ctx, id, minder_model.FeatureSeeLikes)
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	if !seeLikes {
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusForbidden,
+			ResponseCode:    common.StatusForbiddenErrorResponseCode,
+			ResponseMessage: common.StatusForbiddenErrorResponseMessage,
+		}
+		return
+	}
+
+	// liking back spends the like quota like any other like
+	var data *minder_model.SwipeRes
+	res, err = u.atomically(ctx, []uint64{id, likerId}, func(ctx context.Context) (res *common.HTTPResponse, err error) {
+		res, err = u.checkQuota(ctx, id, minder_model.QuotaActionLike)
+		if res != nil || err != nil {
+			return
+		}
+
+		data, err = u.MinderRepo.LikeBack(ctx, id, int64(likerId))
+		if err != nil {
+			return
+		}
+		err = u.SwipeEvents.Publish(ctx, minder_model.SwipeEvent{UserId: req.Id, TargetId: int64(likerId), Action: minder_model.SwipeActionLike})
+		return
+	})
+	if res != nil || err != nil {
+		return
+	}
+
+	u.publishSwipe(ctx, req.Id, int64(likerId), minder_model.SwipeActionLike, data)
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+		Data:            data,
+	}
+
+	return
+}
+
+func normalizePage(page, size int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 {
+		size = defaultPageSize
+	}
+	return page, size
+}
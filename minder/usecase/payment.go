@@ -0,0 +1,323 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/AlvinTendio/minder/common"
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+	"github.com/AlvinTendio/minder/minder/repository"
+	"github.com/AlvinTendio/minder/payment"
+)
+
+const (
+	paymentCurrency = "payment.currency"
+
+	defaultPaymentCurrency = "IDR"
+)
+
+// defaultPlanPrices are used for plans without a payment.price.<plan> config key
+var defaultPlanPrices = map[string]int64{
+	minder_model.SubscriptionPlanMonthly: 49000,
+	minder_model.SubscriptionPlanYearly:  399000,
+}
+
+// UpgradeAccount starts the payment of a premium plan and returns where the user pays it,
+// the subscription is only activated once the payment provider reports the payment.
+// Paying while subscribed extends the current subscription.
+func (u *minderUsecaseImpl) UpgradeAccount(ctx context.Context, id uint64, req *minder_model.UpgradeReq) (res *common.HTTPResponse, err error) {
+	orderId, err := newOrderId(id)
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	currency := u.Config.GetString(paymentCurrency)
+	if currency == "" {
+		currency = defaultPaymentCurrency
+	}
+	data := &minder_model.PaymentData{
+		OrderId:  orderId,
+		UserId:   int64(id),
+		Plan:     req.Plan,
+		Amount:   configInt(u.Config, "payment.price."+req.Plan, defaultPlanPrices[req.Plan]),
+		Currency: currency,
+	}
+
+	data.PaymentId, err = u.PaymentRepo.InsertPayment(ctx, data)
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	checkout, err := u.Payments.CreateCheckout(ctx, &payment.CheckoutReq{
+		OrderId:     data.OrderId,
+		Amount:      data.Amount,
+		Currency:    data.Currency,
+		Description: fmt.Sprintf("Minder premium (%s)", data.Plan),
+		CustomerId:  data.UserId,
+	})
+	if err == nil {
+		_, err = u.PaymentRepo.SetPaymentCheckout(ctx, data.PaymentId, checkout.RedirectURL)
+	}
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		if _, err := u.PaymentRepo.UpdatePaymentStatus(ctx, data.PaymentId, minder_model.PaymentStatusFailed, nil); err != nil {
+			log.Println(ctx, "Error ", err)
+		}
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+		Data: &minder_model.CheckoutRes{
+			PaymentId:   data.PaymentId,
+			OrderId:     data.OrderId,
+			Plan:        data.Plan,
+			Amount:      data.Amount,
+			Currency:    data.Currency,
+			CheckoutURL: checkout.RedirectURL,
+			ExpiresAt:   checkout.ExpiresAt,
+		},
+	}
+
+	return
+}
+
+// HandlePaymentWebhook applies a payment provider event. Events with a bad signature are refused
+// and an event id seen before is acknowledged without being applied again. A payment activates or
+// extends the user's subscription, while a refund or chargeback takes back the period it paid for.
+func (u *minderUsecaseImpl) HandlePaymentWebhook(ctx context.Context, header http.Header, body []byte) (res *common.HTTPResponse, err error) {
+	event, err := u.Payments.VerifyWebhook(header, body)
+	switch {
+	case errors.Is(err, payment.ErrInvalidSignature):
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusUnauthorized,
+			ResponseCode:    common.StatusUnauthorizedErrorResponseCode,
+			ResponseMessage: common.StatusUnauthorizedErrorResponseMessage,
+		}
+		return res, nil
+	case err != nil:
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusBadRequest,
+			ResponseCode:    common.StatusBadRequestErrorResponseCode,
+			ResponseMessage: common.StatusBadRequestErrorResponseMessage,
+		}
+		return res, nil
+	}
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+	}
+
+	err = u.MinderRepo.WithTx(ctx, func(ctx context.Context) error {
+		inserted, err := u.PaymentRepo.InsertPaymentEvent(ctx, event.EventId, event.OrderId, event.Type)
+		if err != nil || !inserted {
+			return err
+		}
+
+		data, err := u.PaymentRepo.LockPaymentByOrder(ctx, event.OrderId)
+		if err != nil {
+			return err
+		}
+
+		switch event.Type {
+		case payment.EventPaymentSucceeded:
+			return u.applyPaymentSucceeded(ctx, data, event)
+		case payment.EventPaymentFailed:
+			if data.Status == minder_model.PaymentStatusPending {
+				_, err = u.PaymentRepo.UpdatePaymentStatus(ctx, data.PaymentId, minder_model.PaymentStatusFailed, nil)
+			}
+			return err
+		case payment.EventRefundSucceeded:
+			return u.revokePayment(ctx, data, minder_model.PaymentStatusRefunded)
+		case payment.EventChargeback:
+			return u.revokePayment(ctx, data, minder_model.PaymentStatusChargedBack)
+		}
+
+		log.Println(ctx, "[usecase:payment] ignoring payment event", event.EventId, "of type", event.Type)
+		return nil
+	})
+
+	if errors.Is(err, repository.ErrNotFound) {
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusNotFound,
+			ResponseCode:    common.StatusNotFoundErrorResponseCode,
+			ResponseMessage: common.StatusNotFoundErrorResponseMessage,
+		}
+		return res, nil
+	}
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	return
+}
+
+// applyPaymentSucceeded activates the paid plan, extending the web subscription the user already has if any.
+// A subscription bought through an app store is left to its store. A payment of another amount than ordered
+// is only flagged, it activates nothing and the event is acknowledged as retrying it cannot change the amount.
+func (u *minderUsecaseImpl) applyPaymentSucceeded(ctx context.Context, data *minder_model.PaymentData, event *payment.Event) error {
+	switch data.Status {
+	case minder_model.PaymentStatusPending, minder_model.PaymentStatusFailed, minder_model.PaymentStatusAmountMismatch:
+	default:
+		return nil
+	}
+	if event.Amount != data.Amount {
+		log.Println(ctx, "[usecase:payment] order", data.OrderId, "paid", event.Amount, "instead of", data.Amount, "in event", event.EventId)
+		_, err := u.PaymentRepo.UpdatePaymentStatus(ctx, data.PaymentId, minder_model.PaymentStatusAmountMismatch, nil)
+		return err
+	}
+
+	if err := u.MinderRepo.LockUsers(ctx, uint64(data.UserId)); err != nil {
+		return err
+	}
+
+	now := u.Clock.Now()
+	subscription, err := u.SubscriptionRepo.GetEntitledSubscription(ctx, uint64(data.UserId), now, gracePeriod(u.Config))
+
+	var subscriptionId int64
+	switch {
+	case err == nil && subscription.Store == minder_model.SubscriptionStoreWeb:
+		subscriptionId = subscription.SubscriptionId
+		start := subscription.CurrentPeriodEnd
+		if start.Before(now) {
+			start = now
+		}
+		_, err = u.SubscriptionRepo.ExtendSubscription(ctx, subscriptionId, data.Plan, planPeriodEnd(data.Plan, start))
+	case err == nil || errors.Is(err, repository.ErrNotFound):
+		subscriptionId, err = u.SubscriptionRepo.InsertSubscription(ctx, uint64(data.UserId), data.Plan, now, planPeriodEnd(data.Plan, now))
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = u.PaymentRepo.UpdatePaymentStatus(ctx, data.PaymentId, minder_model.PaymentStatusPaid, &subscriptionId)
+	return err
+}
+
+// revokePayment marks a paid payment as given back and takes the period it paid for off its subscription.
+// The subscription is revoked once no other paid payment is left on it or nothing of its period remains.
+func (u *minderUsecaseImpl) revokePayment(ctx context.Context, data *minder_model.PaymentData, status string) error {
+	if data.Status != minder_model.PaymentStatusPaid {
+		return nil
+	}
+
+	if err := u.MinderRepo.LockUsers(ctx, uint64(data.UserId)); err != nil {
+		return err
+	}
+	if _, err := u.PaymentRepo.UpdatePaymentStatus(ctx, data.PaymentId, status, nil); err != nil {
+		return err
+	}
+	if data.SubscriptionId == nil {
+		return nil
+	}
+
+	subscription, err := u.SubscriptionRepo.LockSubscription(ctx, *data.SubscriptionId)
+	if err != nil {
+		return err
+	}
+	paid, err := u.PaymentRepo.CountPaidPayments(ctx, subscription.SubscriptionId)
+	if err != nil {
+		return err
+	}
+
+	now := u.Clock.Now()
+	periodEnd := planPeriodStart(data.Plan, subscription.CurrentPeriodEnd)
+	if paid == 0 || !periodEnd.After(now) {
+		_, err = u.SubscriptionRepo.RevokeSubscription(ctx, subscription.SubscriptionId, now)
+		return err
+	}
+	_, err = u.SubscriptionRepo.ShortenSubscription(ctx, subscription.SubscriptionId, periodEnd)
+	return err
+}
+
+// RefundPayment asks the payment provider to refund a paid payment, its period is taken off the
+// subscription once the provider confirms the refund through the webhook
+func (u *minderUsecaseImpl) RefundPayment(ctx context.Context, paymentId uint64) (res *common.HTTPResponse, err error) {
+	data, err := u.PaymentRepo.GetPayment(ctx, paymentId)
+
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusNotFound,
+			ResponseCode:    common.StatusNotFoundErrorResponseCode,
+			ResponseMessage: common.StatusNotFoundErrorResponseMessage,
+		}
+		return res, nil
+	case err != nil:
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	case data.Status != minder_model.PaymentStatusPaid:
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusConflict,
+			ResponseCode:    common.StatusConflictErrorResponseCode,
+			ResponseMessage: common.StatusConflictErrorResponseMessage,
+		}
+		return res, nil
+	}
+
+	if err = u.Payments.Refund(ctx, data.OrderId, data.Amount); err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+		Data:            data,
+	}
+
+	return
+}
+
+// newOrderId returns a unique order id for a payment of the user
+func newOrderId(userId uint64) (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("MDR-%d-%s", userId, hex.EncodeToString(b)), nil
+}
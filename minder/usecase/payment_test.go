@@ -0,0 +1,249 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+	"github.com/AlvinTendio/minder/payment"
+	"github.com/AlvinTendio/minder/payment/fake"
+)
+
+const testWebhookSecret = "test-webhook-secret"
+
+// paymentFixture wires the usecase to the fake gateway, the gateway's webhooks go straight to HandlePaymentWebhook
+type paymentFixture struct {
+	u        *minderUsecaseImpl
+	store    *memoryStore
+	gateway  *httptest.Server
+	webhooks *webhookRecorder
+}
+
+func newPaymentFixture(t *testing.T) *paymentFixture {
+	t.Helper()
+	f := &paymentFixture{}
+	f.u, f.store = newMemoryUsecase()
+	f.webhooks = newWebhookRecorder(t, func(r *http.Request, body []byte) int {
+		res, _ := f.u.HandlePaymentWebhook(r.Context(), r.Header, body)
+		return res.HTTPStatus
+	})
+
+	f.gateway = httptest.NewUnstartedServer(nil)
+	f.gateway.Config.Handler = fake.NewServer("http://"+f.gateway.Listener.Addr().String(), f.webhooks.URL, testWebhookSecret)
+	f.gateway.Start()
+	t.Cleanup(f.gateway.Close)
+
+	f.u.Payments = payment.NewGatewayProvider(f.gateway.URL, "test-server-key", testWebhookSecret)
+	return f
+}
+
+// checkout starts a payment of the plan for the user
+func (f *paymentFixture) checkout(t *testing.T, userId uint64, plan string) *minder_model.CheckoutRes {
+	t.Helper()
+	res, err := f.u.UpgradeAccount(context.Background(), userId, &minder_model.UpgradeReq{Plan: plan})
+	if err != nil || res.HTTPStatus != http.StatusOK {
+		t.Fatalf("UpgradeAccount answered %+v, err %v", res, err)
+	}
+	return res.Data.(*minder_model.CheckoutRes)
+}
+
+// pay completes the checkout at the fake gateway, which reports the payment through the webhook
+func (f *paymentFixture) pay(t *testing.T, checkout *minder_model.CheckoutRes) minder_model.PaymentData {
+	t.Helper()
+	resp, err := http.Get(checkout.CheckoutURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("paying the checkout answered %d", resp.StatusCode)
+	}
+
+	data := f.store.payment(checkout.PaymentId)
+	if data.Status != minder_model.PaymentStatusPaid || data.SubscriptionId == nil {
+		t.Fatalf("payment is %s after paying, want %s with a subscription", data.Status, minder_model.PaymentStatusPaid)
+	}
+	return data
+}
+
+func (f *paymentFixture) webhook(t *testing.T, header http.Header, body []byte) int {
+	t.Helper()
+	res, err := f.u.HandlePaymentWebhook(context.Background(), header, body)
+	if err != nil && res == nil {
+		t.Fatal(err)
+	}
+	return res.HTTPStatus
+}
+
+func signedHeader(secret string, body []byte) http.Header {
+	return http.Header{payment.SignatureHeader: []string{payment.Sign(secret, body)}}
+}
+
+func TestPaymentWebhookSignature(t *testing.T) {
+	f := newPaymentFixture(t)
+	checkout := f.checkout(t, 1, minder_model.SubscriptionPlanMonthly)
+	body, _ := json.Marshal(&payment.Event{
+		EventId: "evt_signature",
+		Type:    payment.EventPaymentSucceeded,
+		OrderId: checkout.OrderId,
+		Amount:  checkout.Amount,
+	})
+
+	for name, header := range map[string]http.Header{
+		"unsigned":     {},
+		"wrong secret": signedHeader("not-the-secret", body),
+		"other body":   signedHeader(testWebhookSecret, append(bytes.Clone(body), ' ')),
+	} {
+		if status := f.webhook(t, header, body); status != http.StatusUnauthorized {
+			t.Errorf("%s webhook answered %d, want %d", name, status, http.StatusUnauthorized)
+		}
+	}
+	if data := f.store.payment(checkout.PaymentId); data.Status != minder_model.PaymentStatusPending {
+		t.Fatalf("payment is %s after forged webhooks, want %s", data.Status, minder_model.PaymentStatusPending)
+	}
+
+	if status := f.webhook(t, signedHeader(testWebhookSecret, body), body); status != http.StatusOK {
+		t.Fatalf("signed webhook answered %d, want %d", status, http.StatusOK)
+	}
+	if data := f.store.payment(checkout.PaymentId); data.Status != minder_model.PaymentStatusPaid {
+		t.Errorf("payment is %s after the signed webhook, want %s", data.Status, minder_model.PaymentStatusPaid)
+	}
+}
+
+func TestPaymentWebhookReplay(t *testing.T) {
+	f := newPaymentFixture(t)
+	paid := f.pay(t, f.checkout(t, 1, minder_model.SubscriptionPlanMonthly))
+	subscription := f.store.subscription(*paid.SubscriptionId)
+
+	header, body := f.webhooks.last()
+	for i := 0; i < 3; i++ {
+		if status := f.webhook(t, header, body); status != http.StatusOK {
+			t.Fatalf("replayed webhook answered %d, want %d", status, http.StatusOK)
+		}
+	}
+
+	if replayed := f.store.subscription(*paid.SubscriptionId); !replayed.CurrentPeriodEnd.Equal(subscription.CurrentPeriodEnd) {
+		t.Errorf("replays moved the period end from %v to %v", subscription.CurrentPeriodEnd, replayed.CurrentPeriodEnd)
+	}
+
+	// a payment replayed after its refund must not bring the subscription back
+	if res, err := f.u.RefundPayment(context.Background(), uint64(paid.PaymentId)); err != nil || res.HTTPStatus != http.StatusOK {
+		t.Fatalf("RefundPayment answered %+v, err %v", res, err)
+	}
+	if status := f.webhook(t, header, body); status != http.StatusOK {
+		t.Fatalf("replayed webhook answered %d, want %d", status, http.StatusOK)
+	}
+	if replayed := f.store.subscription(*paid.SubscriptionId); replayed.Status != minder_model.SubscriptionStatusRevoked {
+		t.Errorf("replay after the refund left the subscription %s, want %s", replayed.Status, minder_model.SubscriptionStatusRevoked)
+	}
+}
+
+func TestPaymentWebhookAmountMismatch(t *testing.T) {
+	f := newPaymentFixture(t)
+	checkout := f.checkout(t, 1, minder_model.SubscriptionPlanYearly)
+	body, _ := json.Marshal(&payment.Event{
+		EventId: "evt_underpaid",
+		Type:    payment.EventPaymentSucceeded,
+		OrderId: checkout.OrderId,
+		Amount:  checkout.Amount - 1,
+	})
+
+	// the event is acknowledged, retrying it could never succeed
+	if status := f.webhook(t, signedHeader(testWebhookSecret, body), body); status != http.StatusOK {
+		t.Errorf("underpaid webhook answered %d, want %d", status, http.StatusOK)
+	}
+	data := f.store.payment(checkout.PaymentId)
+	if data.Status != minder_model.PaymentStatusAmountMismatch || data.SubscriptionId != nil {
+		t.Errorf("underpaid payment is %s with subscription %v, want it %s without one", data.Status, data.SubscriptionId,
+			minder_model.PaymentStatusAmountMismatch)
+	}
+	f.store.mu.Lock()
+	recorded := f.store.paymentEvents["evt_underpaid"]
+	f.store.mu.Unlock()
+	if !recorded {
+		t.Error("underpaid event was not recorded")
+	}
+	if _, err := f.u.SubscriptionRepo.GetEntitledSubscription(context.Background(), 1, time.Now(), 0); err == nil {
+		t.Error("underpaid payment activated a subscription")
+	}
+
+	// the full amount still goes through afterwards
+	f.pay(t, checkout)
+}
+
+func TestPaymentRevocation(t *testing.T) {
+	for name, revoke := range map[string]func(t *testing.T, f *paymentFixture, paid minder_model.PaymentData){
+		minder_model.PaymentStatusRefunded: func(t *testing.T, f *paymentFixture, paid minder_model.PaymentData) {
+			res, err := f.u.RefundPayment(context.Background(), uint64(paid.PaymentId))
+			if err != nil || res.HTTPStatus != http.StatusOK {
+				t.Fatalf("RefundPayment answered %+v, err %v", res, err)
+			}
+		},
+		minder_model.PaymentStatusChargedBack: func(t *testing.T, f *paymentFixture, paid minder_model.PaymentData) {
+			resp, err := http.Post(f.gateway.URL+"/v1/orders/"+paid.OrderId+"/chargeback", "application/json", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("chargeback answered %d", resp.StatusCode)
+			}
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			f := newPaymentFixture(t)
+			paid := f.pay(t, f.checkout(t, 1, minder_model.SubscriptionPlanMonthly))
+			if _, err := f.u.SubscriptionRepo.GetEntitledSubscription(context.Background(), 1, time.Now(), 0); err != nil {
+				t.Fatalf("paid subscription is not entitled: %v", err)
+			}
+
+			revoke(t, f, paid)
+
+			if data := f.store.payment(paid.PaymentId); data.Status != name {
+				t.Errorf("payment is %s, want %s", data.Status, name)
+			}
+			if subscription := f.store.subscription(*paid.SubscriptionId); subscription.Status != minder_model.SubscriptionStatusRevoked {
+				t.Errorf("subscription is %s, want %s", subscription.Status, minder_model.SubscriptionStatusRevoked)
+			}
+			if _, err := f.u.SubscriptionRepo.GetEntitledSubscription(context.Background(), 1, time.Now(), 0); err == nil {
+				t.Error("revoked subscription is still entitled")
+			}
+		})
+	}
+}
+
+func TestPaymentRefundKeepsOtherPeriods(t *testing.T) {
+	f := newPaymentFixture(t)
+	first := f.pay(t, f.checkout(t, 1, minder_model.SubscriptionPlanMonthly))
+	second := f.pay(t, f.checkout(t, 1, minder_model.SubscriptionPlanMonthly))
+	if *second.SubscriptionId != *first.SubscriptionId {
+		t.Fatalf("second payment started subscription %d, want it to extend %d", *second.SubscriptionId, *first.SubscriptionId)
+	}
+	extended := f.store.subscription(*first.SubscriptionId)
+
+	// refunding the renewal takes back its month only
+	if res, err := f.u.RefundPayment(context.Background(), uint64(second.PaymentId)); err != nil || res.HTTPStatus != http.StatusOK {
+		t.Fatalf("RefundPayment answered %+v, err %v", res, err)
+	}
+	subscription := f.store.subscription(*first.SubscriptionId)
+	if want := extended.CurrentPeriodEnd.AddDate(0, -1, 0); subscription.Status != minder_model.SubscriptionStatusActive || !subscription.CurrentPeriodEnd.Equal(want) {
+		t.Errorf("refund left the subscription %s until %v, want %s until %v", subscription.Status, subscription.CurrentPeriodEnd,
+			minder_model.SubscriptionStatusActive, want)
+	}
+	if _, err := f.u.SubscriptionRepo.GetEntitledSubscription(context.Background(), 1, time.Now(), 0); err != nil {
+		t.Errorf("refunding the renewal took away the paid first month: %v", err)
+	}
+
+	// refunding the last paid payment revokes the subscription
+	if res, err := f.u.RefundPayment(context.Background(), uint64(first.PaymentId)); err != nil || res.HTTPStatus != http.StatusOK {
+		t.Fatalf("RefundPayment answered %+v, err %v", res, err)
+	}
+	if subscription := f.store.subscription(*first.SubscriptionId); subscription.Status != minder_model.SubscriptionStatusRevoked {
+		t.Errorf("refunding every payment left the subscription %s, want %s", subscription.Status, minder_model.SubscriptionStatusRevoked)
+	}
+}
@@ -0,0 +1,132 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/AlvinTendio/minder/common"
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+	"github.com/AlvinTendio/minder/minder/repository"
+)
+
+// CreatePromoCode creates a code granting one of the configured packages for a number of days,
+// codes are case insensitive
+func (u *minderUsecaseImpl) CreatePromoCode(ctx context.Context, req *minder_model.PromoCodeReq) (res *common.HTTPResponse, err error) {
+	if !u.Entitlements.HasPackage(req.Package) || !req.ExpiresAt.After(u.Clock.Now()) {
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusBadRequest,
+			ResponseCode:    common.StatusBadRequestErrorResponseCode,
+			ResponseMessage: common.StatusBadRequestErrorResponseMessage,
+		}
+		return
+	}
+
+	req.Code = strings.ToUpper(req.Code)
+	promoCodeId, err := u.PromoRepo.InsertPromoCode(ctx, req)
+
+	switch {
+	case errors.Is(err, repository.ErrPromoCodeExists):
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusConflict,
+			ResponseCode:    common.StatusConflictErrorResponseCode,
+			ResponseMessage: common.StatusConflictErrorResponseMessage,
+		}
+		return res, nil
+	case err != nil:
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+		Data: &minder_model.PromoCodeData{
+			PromoCodeId:    promoCodeId,
+			Code:           req.Code,
+			Package:        req.Package,
+			Days:           req.Days,
+			MaxRedemptions: req.MaxRedemptions,
+			ExpiresAt:      req.ExpiresAt,
+		},
+	}
+
+	return
+}
+
+// RedeemPromo grants the user the package of a promo code, in the same transaction that records the
+// redemption and counts it against the code's cap. Redeeming while a grant of the same package is
+// still running extends that grant. It answers 403 once the code expired or is used up and 409 when
+// the user already redeemed it.
+func (u *minderUsecaseImpl) RedeemPromo(ctx context.Context, req *minder_model.RedeemPromoReq) (res *common.HTTPResponse, err error) {
+	id := uint64(req.UserId)
+	code := strings.ToUpper(strings.TrimSpace(req.Code))
+
+	return u.atomically(ctx, []uint64{id}, func(ctx context.Context) (res *common.HTTPResponse, err error) {
+		promo, err := u.PromoRepo.LockPromoCode(ctx, code)
+		if err != nil {
+			return
+		}
+
+		now := u.Clock.Now()
+		if !promo.ExpiresAt.After(now) || promo.RedemptionCount >= promo.MaxRedemptions {
+			res = &common.HTTPResponse{
+				HTTPStatus:      http.StatusForbidden,
+				ResponseCode:    common.StatusForbiddenErrorResponseCode,
+				ResponseMessage: common.StatusForbiddenErrorResponseMessage,
+			}
+			return res, nil
+		}
+
+		_, err = u.PromoRepo.InsertRedemption(ctx, promo.PromoCodeId, id, now)
+		if errors.Is(err, repository.ErrPromoRedeemed) {
+			res = &common.HTTPResponse{
+				HTTPStatus:      http.StatusConflict,
+				ResponseCode:    common.StatusConflictErrorResponseCode,
+				ResponseMessage: common.StatusConflictErrorResponseMessage,
+			}
+			return res, nil
+		}
+		if err != nil {
+			return
+		}
+
+		grants, err := u.EntitlementRepo.GetActiveGrants(ctx, id, now)
+		if err != nil {
+			return
+		}
+		start := now
+		for _, grant := range grants {
+			if grant.Package == promo.Package && grant.ExpiresAt.After(start) {
+				start = grant.ExpiresAt
+			}
+		}
+
+		grant := &minder_model.EntitlementGrant{
+			UserId:    req.UserId,
+			Package:   promo.Package,
+			Source:    minder_model.GrantSourcePromo,
+			ExpiresAt: start.AddDate(0, 0, int(promo.Days)),
+		}
+		grant.GrantId, err = u.EntitlementRepo.InsertGrant(ctx, id, grant.Package, grant.Source, grant.ExpiresAt)
+		if err != nil {
+			return
+		}
+
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusOK,
+			ResponseCode:    common.StatusOKResponseCode,
+			ResponseMessage: common.StatusOKResponseMessage,
+			Data:            grant,
+		}
+		return
+	})
+}
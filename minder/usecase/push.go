@@ -0,0 +1,177 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/AlvinTendio/minder/common"
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+	"github.com/AlvinTendio/minder/minder/repository"
+)
+
+// ErrDeviceTokenInvalid is returned by a PushSender when the device token will never work again,
+// the token is then removed instead of retried
+var ErrDeviceTokenInvalid = errors.New("device token no longer valid")
+
+// PushSender delivers a push to one device
+type PushSender interface {
+	Send(ctx context.Context, device *minder_model.DeviceToken, push *minder_model.Push) error
+}
+
+type logPushSender struct{}
+
+// NewLogPushSender returns a PushSender that only logs the pushes, standing in for a real push provider
+func NewLogPushSender() PushSender {
+	return logPushSender{}
+}
+
+func (logPushSender) Send(ctx context.Context, device *minder_model.DeviceToken, push *minder_model.Push) error {
+	log.Println(ctx, "[usecase:push] push to", device.Platform, "device of user", device.UserId, ":", push.Title, "-", push.Body)
+	return nil
+}
+
+type filePushSender struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFilePushSender returns a PushSender appending every push to the file at path as one JSON line,
+// standing in for a real push provider
+func NewFilePushSender(path string) PushSender {
+	return &filePushSender{path: path}
+}
+
+func (s *filePushSender) Send(ctx context.Context, device *minder_model.DeviceToken, push *minder_model.Push) error {
+	line, err := json.Marshal(struct {
+		Device *minder_model.DeviceToken `json:"device"`
+		Push   *minder_model.Push        `json:"push"`
+		SentAt time.Time                 `json:"sentAt"`
+	}{device, push, time.Now()})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// RegisterDevice stores a device token the user's pushes are sent to
+func (u *minderUsecaseImpl) RegisterDevice(ctx context.Context, req *minder_model.DeviceTokenReq) (res *common.HTTPResponse, err error) {
+	_, err = u.PushRepo.SaveDeviceToken(ctx, req)
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+	}
+
+	return
+}
+
+// RemoveDevice stops pushes to a device token of the user, for instance on logout
+func (u *minderUsecaseImpl) RemoveDevice(ctx context.Context, req *minder_model.RemoveDeviceTokenReq) (res *common.HTTPResponse, err error) {
+	_, err = u.PushRepo.DeleteDeviceToken(ctx, uint64(req.UserId), req.Token)
+
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusNotFound,
+			ResponseCode:    common.StatusNotFoundErrorResponseCode,
+			ResponseMessage: common.StatusNotFoundErrorResponseMessage,
+		}
+		return res, nil
+	case err != nil:
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+	}
+
+	return
+}
+
+func (u *minderUsecaseImpl) GetNotificationPreferences(ctx context.Context, req *minder_model.NotificationPreferencesReq) (res *common.HTTPResponse, err error) {
+	data, err := u.PushRepo.GetNotificationPreferences(ctx, uint64(req.UserId))
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+		Data:            data,
+	}
+
+	return
+}
+
+// UpdateNotificationPreferences changes the preferences given in req and keeps the others
+func (u *minderUsecaseImpl) UpdateNotificationPreferences(ctx context.Context, req *minder_model.UpdateNotificationPreferencesReq) (res *common.HTTPResponse, err error) {
+	return u.atomically(ctx, []uint64{uint64(req.UserId)}, func(ctx context.Context) (res *common.HTTPResponse, err error) {
+		data, err := u.PushRepo.GetNotificationPreferences(ctx, uint64(req.UserId))
+		if err != nil {
+			log.Println(ctx, "Error ", err)
+			return
+		}
+		if req.Matches != nil {
+			data.Matches = *req.Matches
+		}
+		if req.Messages != nil {
+			data.Messages = *req.Messages
+		}
+
+		if _, err = u.PushRepo.SaveNotificationPreferences(ctx, uint64(req.UserId), data); err != nil {
+			log.Println(ctx, "Error ", err)
+			return
+		}
+
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusOK,
+			ResponseCode:    common.StatusOKResponseCode,
+			ResponseMessage: common.StatusOKResponseMessage,
+			Data:            data,
+		}
+		return
+	})
+}
@@ -0,0 +1,192 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	core_config "github.com/AlvinTendio/minder/config"
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+	"github.com/AlvinTendio/minder/minder/repository"
+)
+
+const (
+	pushDispatchInterval = "push.dispatch.interval.seconds"
+	pushBatchSize        = "push.batch.size"
+	pushMaxAttempts      = "push.max.attempts"
+	pushRetryBase        = "push.retry.base.seconds"
+	pushRetryMax         = "push.retry.max.seconds"
+	pushClaim            = "push.claim.seconds"
+
+	defaultPushDispatchInterval = 5
+	defaultPushBatchSize        = 100
+	defaultPushMaxAttempts      = 5
+	defaultPushRetryBase        = 30
+	defaultPushRetryMax         = 3600
+	defaultPushClaim            = 300
+)
+
+// PushDispatcher delivers the push outbox to the users' devices. A failed push is retried with
+// exponential backoff and dead-lettered once push.max.attempts is spent. Pushes the user turned
+// off in their notification preferences, or users without any device, are skipped.
+// Delivery is at least once: a retry goes to every device again, and so does an entry whose
+// claim ran out before its outcome was recorded.
+type PushDispatcher struct {
+	MinderRepo repository.MinderRepository
+	PushRepo   repository.PushRepository
+	Sender     PushSender
+	Clock      Clock
+	Config     core_config.Config
+}
+
+func NewPushDispatcher(minderRepo repository.MinderRepository, pushRepo repository.PushRepository, sender PushSender,
+	clock Clock, config core_config.Config) *PushDispatcher {
+	return &PushDispatcher{
+		MinderRepo: minderRepo,
+		PushRepo:   pushRepo,
+		Sender:     sender,
+		Clock:      clock,
+		Config:     config,
+	}
+}
+
+// Run dispatches due pushes every push.dispatch.interval.seconds until ctx is done
+func (d *PushDispatcher) Run(ctx context.Context) {
+	interval := time.Duration(configInt(d.Config, pushDispatchInterval, defaultPushDispatchInterval)) * time.Second
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := d.dispatch(ctx); err != nil {
+				log.Println(ctx, "[usecase:push] dispatch err", err)
+			}
+		case <-ctx.Done():
+			log.Println("[usecase:push] dispatcher stopped!")
+			return
+		}
+	}
+}
+
+// dispatch handles one batch of due pushes. The batch is claimed for push.claim.seconds in a short
+// transaction, then every entry is sent and its outcome recorded on its own, outside of it.
+func (d *PushDispatcher) dispatch(ctx context.Context) error {
+	now := d.Clock.Now()
+	var entries []*minder_model.PushOutboxEntry
+	err := d.MinderRepo.WithTx(ctx, func(ctx context.Context) (err error) {
+		entries, err = d.PushRepo.GetDuePushes(ctx, now, int(configInt(d.Config, pushBatchSize, defaultPushBatchSize)))
+		if err != nil {
+			return err
+		}
+
+		claimedUntil := now.Add(time.Duration(configInt(d.Config, pushClaim, defaultPushClaim)) * time.Second)
+		for _, entry := range entries {
+			if _, err := d.PushRepo.ClaimPush(ctx, entry.OutboxId, claimedUntil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := d.deliver(ctx, entry, now); err != nil {
+			log.Println(ctx, "[usecase:push] recording push", entry.OutboxId, "err", err)
+		}
+	}
+	return nil
+}
+
+// deliver sends the entry to every device of its user and records the outcome,
+// only failing to record it is returned as an error
+func (d *PushDispatcher) deliver(ctx context.Context, entry *minder_model.PushOutboxEntry, now time.Time) error {
+	prefs, err := d.PushRepo.GetNotificationPreferences(ctx, uint64(entry.UserId))
+	if err != nil {
+		return err
+	}
+	if (entry.PushType == minder_model.PushTypeMatch && !prefs.Matches) ||
+		(entry.PushType == minder_model.PushTypeMessage && !prefs.Messages) {
+		_, err = d.PushRepo.MarkPushProcessed(ctx, entry.OutboxId, minder_model.PushStatusSkipped, now)
+		return err
+	}
+
+	devices, err := d.PushRepo.GetDeviceTokens(ctx, uint64(entry.UserId))
+	if err != nil {
+		return err
+	}
+	if len(devices) == 0 {
+		_, err = d.PushRepo.MarkPushProcessed(ctx, entry.OutboxId, minder_model.PushStatusSkipped, now)
+		return err
+	}
+
+	push, sendErr := buildPush(entry)
+	if sendErr == nil {
+		for _, device := range devices {
+			err := d.Sender.Send(ctx, device, push)
+			if errors.Is(err, ErrDeviceTokenInvalid) {
+				if _, err := d.PushRepo.DeleteDeviceToken(ctx, uint64(device.UserId), device.Token); err != nil && !errors.Is(err, repository.ErrNotFound) {
+					return err
+				}
+				continue
+			}
+			if err != nil {
+				sendErr = err
+			}
+		}
+	}
+	if sendErr == nil {
+		_, err = d.PushRepo.MarkPushProcessed(ctx, entry.OutboxId, minder_model.PushStatusSent, now)
+		return err
+	}
+
+	attempts := entry.Attempts + 1
+	status := minder_model.PushStatusPending
+	if attempts >= configInt(d.Config, pushMaxAttempts, defaultPushMaxAttempts) {
+		status = minder_model.PushStatusDead
+		log.Println(ctx, "[usecase:push] dead-lettering push", entry.OutboxId, "after", attempts, "attempts:", sendErr)
+	}
+	_, err = d.PushRepo.MarkPushFailed(ctx, entry.OutboxId, status, attempts, now.Add(d.backoff(attempts)), sendErr.Error())
+	return err
+}
+
+// backoff doubles the wait after every failed attempt, up to push.retry.max.seconds
+func (d *PushDispatcher) backoff(attempts int64) time.Duration {
+	base := time.Duration(configInt(d.Config, pushRetryBase, defaultPushRetryBase)) * time.Second
+	limit := time.Duration(configInt(d.Config, pushRetryMax, defaultPushRetryMax)) * time.Second
+	wait := base
+	for i := int64(1); i < attempts && wait < limit; i++ {
+		wait *= 2
+	}
+	return min(wait, limit)
+}
+
+// buildPush turns an outbox entry into the text shown on the device
+func buildPush(entry *minder_model.PushOutboxEntry) (*minder_model.Push, error) {
+	push := &minder_model.Push{Type: entry.PushType, Data: entry.Payload}
+
+	switch entry.PushType {
+	case minder_model.PushTypeMatch:
+		push.Title = "It's a match!"
+		push.Body = "You have a new match, say hi."
+	case minder_model.PushTypeMessage:
+		var event minder_model.MessageStreamEvent
+		if err := json.Unmarshal(entry.Payload, &event); err != nil {
+			return nil, fmt.Errorf("invalid message push payload: %w", err)
+		}
+		if event.Message == nil {
+			return nil, errors.New("message push without a message")
+		}
+		push.Title = "New message"
+		push.Body = preview(event.Message.Body)
+	default:
+		return nil, fmt.Errorf("unknown push type %q", entry.PushType)
+	}
+
+	return push, nil
+}
@@ -0,0 +1,208 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/AlvinTendio/minder/common"
+	core_config "github.com/AlvinTendio/minder/config"
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+	"github.com/AlvinTendio/minder/minder/repository"
+)
+
+var quotaActions = []string{minder_model.QuotaActionView, minder_model.QuotaActionLike,
+	minder_model.QuotaActionSuperLike, minder_model.QuotaActionRewind}
+
+// QuotaService tells how much of an action a user may still use today.
+// Today is the user's local day in their own time zone.
+type QuotaService interface {
+	Status(ctx context.Context, id uint64, action string) (status *minder_model.QuotaStatus, err error)
+	Today(ctx context.Context, id uint64) (day minder_model.DayRange, err error)
+}
+
+type quotaServiceImpl struct {
+	MinderRepo   repository.MinderRepository
+	Entitlements EntitlementService
+	Clock        Clock
+
+	mu              sync.RWMutex
+	defaultLocation *time.Location
+}
+
+// NewQuotaService returns a QuotaService taking the daily limit of an action from the user's
+// entitlement to the feature of the same name, an active admin override for the user always wins.
+// Users without a time zone of their own get timezone.default, which is reloaded whenever it changes.
+func NewQuotaService(minderRepo repository.MinderRepository, entitlements EntitlementService, clock Clock, config core_config.Config) QuotaService {
+	q := &quotaServiceImpl{MinderRepo: minderRepo, Entitlements: entitlements, Clock: clock}
+	q.load(config)
+
+	changes := config.Watch(defaultTimeZoneKey)
+	go func() {
+		for keys := range changes {
+			log.Println("[usecase:quota] reloading default time zone, changed keys:", keys)
+			q.load(config)
+		}
+	}()
+
+	return q
+}
+
+func (q *quotaServiceImpl) load(config core_config.Config) {
+	fallback, err := time.LoadLocation(defaultTimeZone)
+	if err != nil {
+		fallback = time.UTC
+	}
+	defaultLocation := loadLocation(context.Background(), config.GetString(defaultTimeZoneKey), fallback)
+
+	q.mu.Lock()
+	q.defaultLocation = defaultLocation
+	q.mu.Unlock()
+}
+
+func (q *quotaServiceImpl) Status(ctx context.Context, id uint64, action string) (status *minder_model.QuotaStatus, err error) {
+	entitlements, err := q.Entitlements.Entitlements(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	day, err := q.Today(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := entitlements.Limit(action)
+	override, err := q.MinderRepo.GetQuotaOverride(ctx, id, action)
+	if err == nil {
+		limit = override
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+
+	var used int64
+	switch action {
+	case minder_model.QuotaActionView:
+		var viewCount *int64
+		viewCount, err = q.MinderRepo.GetUserViewCount(ctx, id, day)
+		if viewCount != nil {
+			used = *viewCount
+		}
+	case minder_model.QuotaActionLike:
+		used, err = q.MinderRepo.GetUserLikeCount(ctx, id, day)
+	case minder_model.QuotaActionSuperLike:
+		used, err = q.MinderRepo.GetUserSuperLikeCount(ctx, id, day)
+	case minder_model.QuotaActionRewind:
+		used, err = q.MinderRepo.GetUserRewindCount(ctx, id, day)
+	default:
+		err = fmt.Errorf("unknown quota action %q", action)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	status = &minder_model.QuotaStatus{
+		Action:    action,
+		Tier:      entitlements.Tier,
+		Limit:     limit,
+		Used:      used,
+		Remaining: minder_model.QuotaUnlimited,
+		ResetAt:   day.End,
+	}
+	if limit != minder_model.QuotaUnlimited {
+		status.Remaining = max(limit-used, 0)
+	}
+
+	return
+}
+
+func (q *quotaServiceImpl) Today(ctx context.Context, id uint64) (day minder_model.DayRange, err error) {
+	timeZone, err := q.MinderRepo.GetUserTimeZone(ctx, id)
+	if err != nil {
+		return
+	}
+
+	q.mu.RLock()
+	fallback := q.defaultLocation
+	q.mu.RUnlock()
+
+	return localDay(q.Clock.Now(), loadLocation(ctx, timeZone, fallback)), nil
+}
+
+// CreateQuotaOverride grants a user a temporary daily limit for one action
+func (u *minderUsecaseImpl) CreateQuotaOverride(ctx context.Context, req *minder_model.QuotaOverrideReq) (res *common.HTTPResponse, err error) {
+	_, err = u.MinderRepo.InsertQuotaOverride(ctx, req)
+
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+	}
+
+	return
+}
+
+// GetQuota returns what the user has left of every daily quota
+func (u *minderUsecaseImpl) GetQuota(ctx context.Context, req *minder_model.QuotaReq) (res *common.HTTPResponse, err error) {
+	data, err := u.quotaRes(ctx, uint64(req.UserId))
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return res, err
+	}
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+		Data:            data,
+	}
+
+	return
+}
+
+// quotaRes collects the user's quota of every action
+func (u *minderUsecaseImpl) quotaRes(ctx context.Context, id uint64) (*minder_model.QuotaRes, error) {
+	data := &minder_model.QuotaRes{}
+	for _, action := range quotaActions {
+		quota, err := u.Quota.Status(ctx, id, action)
+		if err != nil {
+			return nil, err
+		}
+		data.Tier = quota.Tier
+		data.ResetAt = quota.ResetAt
+		data.Quotas = append(data.Quotas, quota)
+	}
+	return data, nil
+}
+
+// quotaExceeded builds the 429 response for an exhausted quota, telling the client
+// through Retry-After when the quota resets
+func quotaExceeded(quota *minder_model.QuotaStatus) *common.HTTPResponse {
+	retryAfter := int64(time.Until(quota.ResetAt).Seconds()) + 1
+	return &common.HTTPResponse{
+		HTTPStatus:      http.StatusTooManyRequests,
+		ResponseCode:    common.QuotaExceededResponseCode,
+		ResponseMessage: common.QuotaExceededResponseMessage,
+		Data:            quota,
+		Header:          http.Header{"Retry-After": []string{strconv.FormatInt(max(retryAfter, 1), 10)}},
+	}
+}
@@ -0,0 +1,205 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AlvinTendio/minder/common"
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+	"github.com/AlvinTendio/minder/minder/repository"
+	"github.com/AlvinTendio/minder/stream"
+	stream_memory "github.com/AlvinTendio/minder/stream/memory"
+	gomysql "github.com/go-sql-driver/mysql"
+)
+
+// testMySQLDSN names the env var holding the DSN of a scratch database with every migration applied
+const testMySQLDSN = "MINDER_TEST_MYSQL_DSN"
+
+const (
+	testViewLimit  = 10
+	testLikeLimit  = 5
+	testCandidates = 30
+	testRequests   = 24
+)
+
+// openTestDB connects to the database of MINDER_TEST_MYSQL_DSN and skips the test when it is not set
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv(testMySQLDSN)
+	if dsn == "" {
+		t.Skip(testMySQLDSN + " is not set")
+	}
+
+	cfg, err := gomysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ParseTime = true
+	cfg.Loc = time.UTC
+
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+	})
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// insertTestUser creates a free user, names carry a per run suffix so runs can share a database
+func insertTestUser(t *testing.T, db *sql.DB, username, gender string) int64 {
+	t.Helper()
+	result, err := db.Exec(`INSERT INTO Users (username, email, phone_number, password, full_name, gender, date_of_birth, profile_picture)
+			VALUES (?,?,?,?,?,?,?,?)`,
+		username, username+"@minder.test", "620000000000", "secret", username, gender, "1995-01-01", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func newTestUsecase(db *sql.DB, config testConfig) *minderUsecaseImpl {
+	minderRepo := repository.NewMinderRepositoryImpl(db)
+	subscriptionRepo := repository.NewSubscriptionRepositoryImpl(db)
+	entitlementRepo := repository.NewEntitlementRepositoryImpl(db)
+	clock := NewSystemClock()
+	entitlements := NewEntitlementService(minderRepo, subscriptionRepo, entitlementRepo, clock, config)
+
+	return NewMinderUsecaseImpl(minderRepo, repository.NewChatRepositoryImpl(db), repository.NewPushRepositoryImpl(db),
+		subscriptionRepo, repository.NewPaymentRepositoryImpl(db), nil, entitlementRepo, repository.NewPromoRepositoryImpl(db), nil,
+		NewWeightedRanker(clock, config), NewQuotaService(minderRepo, entitlements, clock, config), entitlements,
+		NewDesirabilityWorker(minderRepo, config), stream.NewHub(stream_memory.NewPubSub()), clock, config).(*minderUsecaseImpl)
+}
+
+// TestQuotaHoldsUnderConcurrentRequests fires parallel discovery and swipe requests for one user
+// and checks no quota is overspent and no candidate is served twice
+func TestQuotaHoldsUnderConcurrentRequests(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	config := testConfig{
+		entitlementPackages:               minder_model.TierFree,
+		packageKey(minder_model.TierFree): fmt.Sprintf("view:%d/day,like:%d/day", testViewLimit, testLikeLimit),
+		defaultTimeZoneKey:                "UTC",
+	}
+	u := newTestUsecase(db, config)
+
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 36)
+	viewer := insertTestUser(t, db, "viewer_"+suffix, "male")
+	for i := 0; i < testCandidates; i++ {
+		insertTestUser(t, db, fmt.Sprintf("candidate_%d_%s", i, suffix), "female")
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	viewed := make(map[int64]int)
+	dealt := make(map[int64]bool)
+	for i := 0; i < testRequests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				res, err := u.GetTargetUser(ctx, uint64(viewer))
+				if !checkStatus(t, "GetTargetUser", res, err) || res.HTTPStatus != http.StatusOK {
+					return
+				}
+				mu.Lock()
+				viewed[res.Data.(*minder_model.TargetUserData).UserId]++
+				mu.Unlock()
+				return
+			}
+
+			res, err := u.GetDeck(ctx, &minder_model.DeckReq{UserId: viewer, Size: 5})
+			if !checkStatus(t, "GetDeck", res, err) || res.HTTPStatus != http.StatusOK {
+				return
+			}
+			mu.Lock()
+			for _, card := range res.Data.(*minder_model.DeckRes).Cards {
+				dealt[card.UserId] = true
+			}
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	for id, times := range viewed {
+		if times > 1 || dealt[id] {
+			t.Errorf("candidate %d served more than once", id)
+		}
+	}
+	served := len(viewed) + len(dealt)
+	if served > testViewLimit {
+		t.Errorf("served %d candidates, view limit is %d", served, testViewLimit)
+	}
+
+	var rows, duplicates int64
+	if err := db.QueryRow(`SELECT COUNT(1), COUNT(1) - COUNT(DISTINCT target_user_id) FROM Swipes WHERE user_id=?`, viewer).
+		Scan(&rows, &duplicates); err != nil {
+		t.Fatal(err)
+	}
+	if rows > testViewLimit {
+		t.Errorf("stored %d views, view limit is %d", rows, testViewLimit)
+	}
+	if duplicates > 0 {
+		t.Errorf("stored %d repeated views", duplicates)
+	}
+
+	// every served card is liked twice at once, the repeat must neither fail nor count
+	for id := range merge(viewed, dealt) {
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func(target int64) {
+				defer wg.Done()
+				res, err := u.Swipe(ctx, &minder_model.SwipeReq{Id: viewer, TargetId: target, Action: minder_model.SwipeActionLike})
+				checkStatus(t, "Swipe", res, err)
+			}(id)
+		}
+	}
+	wg.Wait()
+
+	var likes int64
+	if err := db.QueryRow(`SELECT COUNT(1) FROM Swipes WHERE user_id=? AND swipe_action='like'`, viewer).Scan(&likes); err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(min(served, testLikeLimit)); likes != want {
+		t.Errorf("stored %d likes, want %d with a like limit of %d", likes, want, testLikeLimit)
+	}
+}
+
+// checkStatus fails the test unless the request succeeded or hit its quota, it tells whether there is a response
+func checkStatus(t *testing.T, name string, res *common.HTTPResponse, err error) bool {
+	t.Helper()
+	if err != nil {
+		t.Errorf("%s err %v", name, err)
+		return false
+	}
+	if res.HTTPStatus != http.StatusOK && res.HTTPStatus != http.StatusTooManyRequests {
+		t.Errorf("%s answered %d", name, res.HTTPStatus)
+	}
+	return true
+}
+
+func merge(viewed map[int64]int, dealt map[int64]bool) map[int64]bool {
+	ids := make(map[int64]bool, len(viewed)+len(dealt))
+	for id := range viewed {
+		ids[id] = true
+	}
+	for id := range dealt {
+		ids[id] = true
+	}
+	return ids
+}
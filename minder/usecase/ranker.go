@@ -0,0 +1,211 @@
+package usecase
+
+import (
+	"context"
+	"log"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	core_config "github.com/AlvinTendio/minder/config"
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+)
+
+const (
+	rankerWeightRecency      = "ranker.weight.recency"
+	rankerWeightCompleteness = "ranker.weight.completeness"
+	rankerWeightDistance     = "ranker.weight.distance"
+	rankerWeightInterest     = "ranker.weight.interest"
+	rankerWeightRandom       = "ranker.weight.random"
+	rankerWeightBoost        = "ranker.weight.boost"
+	rankerRecencyHalfLife    = "ranker.recency.halflife.hours"
+	rankerDistanceScale      = "ranker.distance.scale.km"
+	rankerBoostRadius        = "boost.radius.km"
+
+	earthRadiusKm = 6371.0
+)
+
+// Ranker orders discovery candidates for a viewer, best candidate first
+type Ranker interface {
+	Rank(ctx context.Context, viewer *minder_model.RankingProfile, candidates []*minder_model.RankingProfile) []*minder_model.RankingProfile
+}
+
+type rankerWeights struct {
+	recency         float64
+	completeness    float64
+	distance        float64
+	interest        float64
+	random          float64
+	boost           float64
+	recencyHalfLife float64
+	distanceScale   float64
+	boostRadius     float64
+}
+
+var defaultRankerWeights = rankerWeights{
+	recency:         0.3,
+	completeness:    0.2,
+	distance:        0.25,
+	interest:        0.15,
+	random:          0.1,
+	boost:           1,
+	recencyHalfLife: 72,
+	distanceScale:   25,
+	boostRadius:     50,
+}
+
+type weightedRanker struct {
+	mu      sync.RWMutex
+	weights rankerWeights
+	clock   Clock
+	random  func() float64
+}
+
+// NewWeightedRanker returns a Ranker scoring candidates with a weighted sum of activity recency,
+// profile completeness, distance, shared interests and a random term, plus a bonus for boosted nearby users.
+// Weights are read from config and reloaded whenever they change.
+func NewWeightedRanker(clock Clock, config core_config.Config) Ranker {
+	r := &weightedRanker{
+		clock:  clock,
+		random: rand.Float64,
+	}
+	r.load(config)
+
+	changes := config.Watch(rankerWeightRecency, rankerWeightCompleteness, rankerWeightDistance,
+		rankerWeightInterest, rankerWeightRandom, rankerWeightBoost, rankerRecencyHalfLife, rankerDistanceScale,
+		rankerBoostRadius)
+	go func() {
+		for keys := range changes {
+			log.Println("[usecase:ranker] reloading weights, changed keys:", keys)
+			r.load(config)
+		}
+	}()
+
+	return r
+}
+
+func (r *weightedRanker) load(config core_config.Config) {
+	weights := rankerWeights{
+		recency:         configFloat(config, rankerWeightRecency, defaultRankerWeights.recency),
+		completeness:    configFloat(config, rankerWeightCompleteness, defaultRankerWeights.completeness),
+		distance:        configFloat(config, rankerWeightDistance, defaultRankerWeights.distance),
+		interest:        configFloat(config, rankerWeightInterest, defaultRankerWeights.interest),
+		random:          configFloat(config, rankerWeightRandom, defaultRankerWeights.random),
+		boost:           configFloat(config, rankerWeightBoost, defaultRankerWeights.boost),
+		recencyHalfLife: configFloat(config, rankerRecencyHalfLife, defaultRankerWeights.recencyHalfLife),
+		distanceScale:   configFloat(config, rankerDistanceScale, defaultRankerWeights.distanceScale),
+		boostRadius:     configFloat(config, rankerBoostRadius, defaultRankerWeights.boostRadius),
+	}
+
+	r.mu.Lock()
+	r.weights = weights
+	r.mu.Unlock()
+}
+
+func (r *weightedRanker) Rank(ctx context.Context, viewer *minder_model.RankingProfile, candidates []*minder_model.RankingProfile) []*minder_model.RankingProfile {
+	r.mu.RLock()
+	weights := r.weights
+	r.mu.RUnlock()
+
+	now := r.clock.Now()
+	scores := make(map[int64]float64, len(candidates))
+	for _, candidate := range candidates {
+		scores[candidate.UserId] = weights.recency*recencyScore(candidate, now, weights.recencyHalfLife) +
+			weights.completeness*completenessScore(candidate) +
+			weights.distance*distanceScore(viewer, candidate, weights.distanceScale) +
+			weights.interest*interestScore(viewer, candidate) +
+			weights.random*r.random() +
+			weights.boost*boostScore(viewer, candidate, weights.boostRadius)
+	}
+
+	ranked := make([]*minder_model.RankingProfile, len(candidates))
+	copy(ranked, candidates)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return scores[ranked[i].UserId] > scores[ranked[j].UserId]
+	})
+
+	return ranked
+}
+
+// recencyScore decays from 1 to 0 with the time since the candidate was last active
+func recencyScore(candidate *minder_model.RankingProfile, now time.Time, halfLifeHours float64) float64 {
+	if candidate.LastActiveAt == nil || halfLifeHours <= 0 {
+		return 0
+	}
+	hours := math.Max(now.Sub(*candidate.LastActiveAt).Hours(), 0)
+	return math.Pow(0.5, hours/halfLifeHours)
+}
+
+// completenessScore is the share of optional profile fields the candidate filled in
+func completenessScore(candidate *minder_model.RankingProfile) float64 {
+	filled := 0.0
+	if candidate.ProfilePicture != "" {
+		filled++
+	}
+	if candidate.Bio != "" {
+		filled++
+	}
+	if candidate.Latitude != nil && candidate.Longitude != nil {
+		filled++
+	}
+	if len(candidate.Interests) > 0 {
+		filled++
+	}
+	return filled / 4
+}
+
+// distanceScore is 1 for the same spot and halves at every scaleKm, 0 when a location is unknown
+func distanceScore(viewer, candidate *minder_model.RankingProfile, scaleKm float64) float64 {
+	if viewer == nil || viewer.Latitude == nil || viewer.Longitude == nil ||
+		candidate.Latitude == nil || candidate.Longitude == nil || scaleKm <= 0 {
+		return 0
+	}
+	km := haversineKm(*viewer.Latitude, *viewer.Longitude, *candidate.Latitude, *candidate.Longitude)
+	return scaleKm / (scaleKm + km)
+}
+
+// interestScore is the Jaccard similarity of both users' interests
+func interestScore(viewer, candidate *minder_model.RankingProfile) float64 {
+	if viewer == nil || len(viewer.Interests) == 0 || len(candidate.Interests) == 0 {
+		return 0
+	}
+	own := make(map[string]bool, len(viewer.Interests))
+	for _, interest := range viewer.Interests {
+		own[interest] = true
+	}
+	shared := 0
+	for _, interest := range candidate.Interests {
+		if own[interest] {
+			shared++
+		}
+	}
+	union := len(own) + len(candidate.Interests) - shared
+	return float64(shared) / float64(union)
+}
+
+// boostScore is 1 for a boosted candidate in the viewer's area, a candidate without a known
+// location is treated as being in the area
+func boostScore(viewer, candidate *minder_model.RankingProfile, radiusKm float64) float64 {
+	if !candidate.Boosted {
+		return 0
+	}
+	if viewer == nil || viewer.Latitude == nil || viewer.Longitude == nil ||
+		candidate.Latitude == nil || candidate.Longitude == nil {
+		return 1
+	}
+	if haversineKm(*viewer.Latitude, *viewer.Longitude, *candidate.Latitude, *candidate.Longitude) > radiusKm {
+		return 0
+	}
+	return 1
+}
+
+func haversineKm(lat1, lng1, lat2, lng2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRad(lat2 - lat1)
+	dLng := toRad(lng2 - lng1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
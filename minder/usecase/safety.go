@@ -0,0 +1,247 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/AlvinTendio/minder/common"
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+	"github.com/AlvinTendio/minder/minder/repository"
+)
+
+const (
+	reportActionTakenMessage = "Thanks for your report. We reviewed it and took action against the account."
+	reportDismissedMessage   = "Thanks for your report. We reviewed it and found no violation of our community guidelines."
+	warnMessage              = "Your account received a warning for violating our community guidelines."
+	suspendMessage           = "Your account is suspended for %d hours for violating our community guidelines."
+	banMessage               = "Your account is banned for violating our community guidelines."
+)
+
+// BlockUser hides both users from each other in discovery, likes and matches right away,
+// ending their match if they had one
+func (u *minderUsecaseImpl) BlockUser(ctx context.Context, blockedId uint64, req *minder_model.BlockReq) (res *common.HTTPResponse, err error) {
+	if uint64(req.UserId) == blockedId {
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusBadRequest,
+			ResponseCode:    common.StatusBadRequestErrorResponseCode,
+			ResponseMessage: common.StatusBadRequestErrorResponseMessage,
+		}
+		return
+	}
+
+	_, err = u.MinderRepo.Block(ctx, uint64(req.UserId), blockedId)
+
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusNotFound,
+			ResponseCode:    common.StatusNotFoundErrorResponseCode,
+			ResponseMessage: common.StatusNotFoundErrorResponseMessage,
+		}
+		return res, nil
+	case err != nil:
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+	}
+
+	return
+}
+
+// ReportUser puts a report about the user in the moderation queue
+func (u *minderUsecaseImpl) ReportUser(ctx context.Context, reportedId uint64, req *minder_model.ReportReq) (res *common.HTTPResponse, err error) {
+	if uint64(req.UserId) == reportedId {
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusBadRequest,
+			ResponseCode:    common.StatusBadRequestErrorResponseCode,
+			ResponseMessage: common.StatusBadRequestErrorResponseMessage,
+		}
+		return
+	}
+
+	_, err = u.MinderRepo.InsertReport(ctx, reportedId, req)
+
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+	}
+
+	return
+}
+
+// GetReports lists the moderation queue, oldest report first
+func (u *minderUsecaseImpl) GetReports(ctx context.Context, req *minder_model.ReportListReq) (res *common.HTTPResponse, err error) {
+	page, size := normalizePage(req.Page, req.Size)
+
+	total, err := u.MinderRepo.CountReports(ctx, req.Status)
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	data, err := u.MinderRepo.GetReports(ctx, req.Status, size, (page-1)*size)
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+		Data: &minder_model.ReportListRes{
+			Reports: data,
+			Pagination: minder_model.Pagination{
+				Page:  page,
+				Size:  size,
+				Total: total,
+			},
+		},
+	}
+
+	return
+}
+
+// ResolveReport applies a moderator's decision on a report and tells the reporter the outcome.
+// The reported user is told about any warning, suspension or ban too.
+func (u *minderUsecaseImpl) ResolveReport(ctx context.Context, reportId uint64, req *minder_model.ResolveReportReq) (res *common.HTTPResponse, err error) {
+	var report *minder_model.ReportData
+	err = u.MinderRepo.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		report, err = u.MinderRepo.ResolveReport(ctx, reportId, req, u.Clock.Now())
+		if err != nil {
+			return err
+		}
+
+		reporterMessage := reportActionTakenMessage
+		var reportedMessage string
+		switch req.Action {
+		case minder_model.ModerationDismiss:
+			reporterMessage = reportDismissedMessage
+		case minder_model.ModerationWarn:
+			reportedMessage = warnMessage
+		case minder_model.ModerationSuspend:
+			reportedMessage = fmt.Sprintf(suspendMessage, req.SuspendHours)
+		case minder_model.ModerationBan:
+			reportedMessage = banMessage
+		}
+
+		_, err = u.MinderRepo.InsertNotification(ctx, report.ReporterId, minder_model.NotificationReportResolved, reporterMessage)
+		if err != nil || reportedMessage == "" {
+			return err
+		}
+		_, err = u.MinderRepo.InsertNotification(ctx, report.ReportedId, minder_model.NotificationModeration, reportedMessage)
+		return err
+	})
+
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusNotFound,
+			ResponseCode:    common.StatusNotFoundErrorResponseCode,
+			ResponseMessage: common.StatusNotFoundErrorResponseMessage,
+		}
+		return res, nil
+	case errors.Is(err, repository.ErrReportResolved):
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusConflict,
+			ResponseCode:    common.StatusConflictErrorResponseCode,
+			ResponseMessage: common.StatusConflictErrorResponseMessage,
+		}
+		return res, nil
+	case err != nil:
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+		Data:            report,
+	}
+
+	return
+}
+
+func (u *minderUsecaseImpl) GetNotifications(ctx context.Context, req *minder_model.NotificationListReq) (res *common.HTTPResponse, err error) {
+	page, size := normalizePage(req.Page, req.Size)
+	id := uint64(req.UserId)
+
+	total, err := u.MinderRepo.CountNotifications(ctx, id)
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	data, err := u.MinderRepo.GetNotifications(ctx, id, size, (page-1)*size)
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+		Data: &minder_model.NotificationListRes{
+			Notifications: data,
+			Pagination: minder_model.Pagination{
+				Page:  page,
+				Size:  size,
+				Total: total,
+			},
+		},
+	}
+
+	return
+}
@@ -0,0 +1,122 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/AlvinTendio/minder/common"
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+	"github.com/AlvinTendio/minder/minder/repository"
+	"github.com/AlvinTendio/minder/stream"
+)
+
+// quotaRetryInterval is how long a stream waits before retrying a failed quota reset lookup
+const quotaRetryInterval = time.Minute
+
+// Stream opens the user's real-time stream: every event published to the user on any instance,
+// plus a quota_reset event carrying the fresh quota whenever the user's day rolls over.
+// The events channel closes once ctx is done.
+func (u *minderUsecaseImpl) Stream(ctx context.Context, req *minder_model.StreamReq) (events <-chan stream.Event, res *common.HTTPResponse, err error) {
+	id := uint64(req.UserId)
+
+	today, err := u.Quota.Today(ctx, id)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusNotFound,
+			ResponseCode:    common.StatusNotFoundErrorResponseCode,
+			ResponseMessage: common.StatusNotFoundErrorResponseMessage,
+		}
+		return nil, res, nil
+	case err != nil:
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	published, unsubscribe := u.Hub.Subscribe(req.UserId)
+	out := make(chan stream.Event)
+
+	go func() {
+		defer close(out)
+		defer unsubscribe()
+
+		reset := time.NewTimer(today.End.Sub(u.Clock.Now()))
+		defer reset.Stop()
+
+		for {
+			var event stream.Event
+			select {
+			case <-ctx.Done():
+				return
+			case event = <-published:
+			case <-reset.C:
+				var next time.Time
+				event, next = u.quotaResetEvent(ctx, id)
+				reset.Reset(next.Sub(u.Clock.Now()))
+				if event.Type == "" {
+					continue
+				}
+			}
+
+			select {
+			case out <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil, nil
+}
+
+// quotaResetEvent builds the quota_reset event of the user's new day and returns when the next
+// one is due. On failure the event is empty and the lookup is retried after quotaRetryInterval.
+func (u *minderUsecaseImpl) quotaResetEvent(ctx context.Context, id uint64) (event stream.Event, next time.Time) {
+	retry := u.Clock.Now().Add(quotaRetryInterval)
+
+	quota, err := u.quotaRes(ctx, id)
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		return event, retry
+	}
+	data, err := json.Marshal(quota)
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+		return event, retry
+	}
+
+	event = stream.Event{UserId: int64(id), Type: minder_model.StreamEventQuotaReset, Data: data}
+	return event, quota.ResetAt
+}
+
+// publishSwipe tells both users about a match, or the target about a like received otherwise
+func (u *minderUsecaseImpl) publishSwipe(ctx context.Context, userId, targetId int64, action string, data *minder_model.SwipeRes) {
+	if data.Matched {
+		u.Hub.Publish(ctx, userId, minder_model.StreamEventMatch, &minder_model.MatchStreamEvent{MatchId: data.MatchId, UserId: targetId})
+		u.Hub.Publish(ctx, targetId, minder_model.StreamEventMatch, &minder_model.MatchStreamEvent{MatchId: data.MatchId, UserId: userId})
+		return
+	}
+	if action == minder_model.SwipeActionPass {
+		return
+	}
+
+	seeLikes, err := u.Entitlements.Allowed(ctx, uint64(targetId), minder_model.FeatureSeeLikes)
+	if err != nil {
+		log.Println(ctx, "Error ", err)
+	}
+
+	event := &minder_model.LikeReceivedStreamEvent{Action: action, Blurred: !seeLikes}
+	if seeLikes {
+		event.UserId = userId
+	}
+	u.Hub.Publish(ctx, targetId, minder_model.StreamEventLikeReceived, event)
+}
@@ -0,0 +1,109 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/AlvinTendio/minder/common"
+	minder_model "github.com/AlvinTendio/minder/minder/model"
+	"github.com/AlvinTendio/minder/minder/repository"
+)
+
+// planPeriodStart returns when a period of the plan ending at end started
+func planPeriodStart(plan string, end time.Time) time.Time {
+	if plan == minder_model.SubscriptionPlanYearly {
+		return end.AddDate(-1, 0, 0)
+	}
+	return end.AddDate(0, -1, 0)
+}
+
+// planPeriodEnd returns when a period of the plan started at start ends
+func planPeriodEnd(plan string, start time.Time) time.Time {
+	if plan == minder_model.SubscriptionPlanYearly {
+		return start.AddDate(1, 0, 0)
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+// GetSubscription returns the user's latest subscription and whether it grants premium right now
+func (u *minderUsecaseImpl) GetSubscription(ctx context.Context, req *minder_model.SubscriptionReq) (res *common.HTTPResponse, err error) {
+	data, err := u.SubscriptionRepo.GetLatestSubscription(ctx, uint64(req.UserId))
+
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusNotFound,
+			ResponseCode:    common.StatusNotFoundErrorResponseCode,
+			ResponseMessage: common.StatusNotFoundErrorResponseMessage,
+		}
+		return res, nil
+	case err != nil:
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return
+	}
+
+	entitled, err := u.SubscriptionRepo.GetEntitledSubscription(ctx, uint64(req.UserId), u.Clock.Now(), gracePeriod(u.Config))
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		log.Println(ctx, "Error ", err)
+		res = &common.HTTPResponse{
+			HTTPStatus:      http.StatusInternalServerError,
+			ResponseCode:    common.StatusInternalServerErrorResponseCode,
+			ResponseMessage: common.StatusInternalServerErrorResponseMessage,
+		}
+		return res, err
+	}
+	data.Entitled = entitled != nil && entitled.SubscriptionId == data.SubscriptionId
+
+	res = &common.HTTPResponse{
+		HTTPStatus:      http.StatusOK,
+		ResponseCode:    common.StatusOKResponseCode,
+		ResponseMessage: common.StatusOKResponseMessage,
+		Data:            data,
+	}
+
+	return res, nil
+}
+
+// CancelSubscription stops the renewal of the user's subscription, premium lasts until the paid period ends.
+// A subscription bought through an app store can only be canceled in its store.
+func (u *minderUsecaseImpl) CancelSubscription(ctx context.Context, req *minder_model.CancelSubscriptionReq) (res *common.HTTPResponse, err error) {
+	id := uint64(req.UserId)
+
+	return u.atomically(ctx, []uint64{id}, func(ctx context.Context) (res *common.HTTPResponse, err error) {
+		subscription, err := u.SubscriptionRepo.GetEntitledSubscription(ctx, id, u.Clock.Now(), gracePeriod(u.Config))
+		if err == nil && subscription.Store != minder_model.SubscriptionStoreWeb {
+			res = &common.HTTPResponse{
+				HTTPStatus:      http.StatusConflict,
+				ResponseCode:    common.StatusConflictErrorResponseCode,
+				ResponseMessage: common.StatusConflictErrorResponseMessage,
+			}
+			return res, nil
+		}
+		if err == nil {
+			_, err = u.SubscriptionRepo.CancelSubscription(ctx, subscription.SubscriptionId, u.Clock.Now())
+		}
+
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			res = &common.HTTPResponse{
+				HTTPStatus:      http.StatusNotFound,
+				ResponseCode:    common.StatusNotFoundErrorResponseCode,
+				ResponseMessage: common.StatusNotFoundErrorResponseMessage,
+			}
+			return res, nil
+		case err != nil:
+			log.Println(ctx, "Error ", err)
+			return
+		}
+
+		return u.GetSubscription(ctx, &minder_model.SubscriptionReq{UserId: req.UserId})
+	})
+}
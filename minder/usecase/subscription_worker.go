@@ -0,0 +1,71 @@
+package usecase
+
+import (
+	"context"
+	"log"
+	"time"
+
+	core_config "github.com/AlvinTendio/minder/config"
+	"github.com/AlvinTendio/minder/minder/repository"
+)
+
+const (
+	subscriptionExpiryInterval = "subscription.expiry.interval.minutes"
+
+	defaultSubscriptionExpiryInterval = 5
+)
+
+// SubscriptionExpiryWorker moves lapsed subscriptions along: a renewing subscription past its
+// period enters its grace period, and canceled ones or ones whose grace period ran out expire.
+// Entitlement checks already treat lapsed subscriptions right between two runs.
+type SubscriptionExpiryWorker struct {
+	SubscriptionRepo repository.SubscriptionRepository
+	Clock            Clock
+	Config           core_config.Config
+}
+
+func NewSubscriptionExpiryWorker(subscriptionRepo repository.SubscriptionRepository, clock Clock, config core_config.Config) *SubscriptionExpiryWorker {
+	return &SubscriptionExpiryWorker{
+		SubscriptionRepo: subscriptionRepo,
+		Clock:            clock,
+		Config:           config,
+	}
+}
+
+// Run expires lapsed subscriptions every subscription.expiry.interval.minutes until ctx is done
+func (w *SubscriptionExpiryWorker) Run(ctx context.Context) {
+	interval := time.Duration(configInt(w.Config, subscriptionExpiryInterval, defaultSubscriptionExpiryInterval)) * time.Minute
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.expire(ctx); err != nil {
+			log.Println(ctx, "[usecase:subscription] expire subscriptions err", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			log.Println("[usecase:subscription] worker stopped!")
+			return
+		}
+	}
+}
+
+func (w *SubscriptionExpiryWorker) expire(ctx context.Context) error {
+	now := w.Clock.Now()
+
+	grace, err := w.SubscriptionRepo.StartGracePeriods(ctx, now, gracePeriod(w.Config))
+	if err != nil {
+		return err
+	}
+	expired, err := w.SubscriptionRepo.ExpireSubscriptions(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	if grace > 0 || expired > 0 {
+		log.Println(ctx, "[usecase:subscription]", grace, "subscriptions entered grace,", expired, "expired")
+	}
+	return nil
+}
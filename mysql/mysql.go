@@ -71,6 +71,7 @@ func mysqlDSN(config *Config, parseTime bool, location string) string {
 	}
 	if len(location) > 0 {
 		val.Add("loc", location)
+		val.Add("time_zone", sessionTimeZone(location))
 	}
 
 	if len(val) == 0 {
@@ -79,6 +80,15 @@ func mysqlDSN(config *Config, parseTime bool, location string) string {
 	return fmt.Sprintf("%s?%s", connection, val.Encode())
 }
 
+// sessionTimeZone keeps the MySQL session in the zone the driver reads and writes times in,
+// so TIMESTAMP columns compare correctly against time.Time arguments
+func sessionTimeZone(location string) string {
+	if location == "UTC" {
+		return "'+00:00'"
+	}
+	return "'" + location + "'"
+}
+
 type Option func(*Config)
 
 func defaults(config *Config) {
@@ -86,7 +96,7 @@ func defaults(config *Config) {
 	config.maxIdle = DefaultMaxIdle
 	config.maxLifetime = DefaultMaxLifetime
 	config.driverName = DriverMySQL
-	config.dsn = mysqlDSN(config, true, "Asia/Jakarta")
+	config.dsn = mysqlDSN(config, true, "UTC")
 }
 
 func WithConnection(maxOpen, maxIdle int, maxLifetime, maxIdleTime time.Duration) Option {
@@ -0,0 +1,174 @@
+// Package fake is a local stand-in for the payment gateway, speaking the same API as the gateway
+// provider and sending it signed webhooks, so payments can be exercised without a real gateway.
+package fake
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AlvinTendio/minder/payment"
+)
+
+const (
+	checkoutTTL    = time.Hour
+	webhookTimeout = 10 * time.Second
+)
+
+type order struct {
+	OrderId string
+	Amount  int64
+	Paid    bool
+}
+
+// Server serves the gateway API:
+//
+//	POST /v1/checkouts                    creates a checkout for an order
+//	POST /v1/orders/{orderId}/refunds     refunds a paid order
+//	POST /v1/orders/{orderId}/chargeback  charges a paid order back
+//	GET  /pay/{orderId}                   pays the order, ?outcome=fail makes the payment fail instead
+//
+// Every outcome is reported to webhookURL, signed with secret.
+type Server struct {
+	baseURL    string
+	webhookURL string
+	secret     string
+	client     *http.Client
+
+	mu     sync.Mutex
+	orders map[string]*order
+}
+
+// NewServer returns a fake gateway reachable at baseURL, which is only used to build checkout links
+func NewServer(baseURL, webhookURL, secret string) *Server {
+	return &Server{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		webhookURL: webhookURL,
+		secret:     secret,
+		client:     &http.Client{Timeout: webhookTimeout},
+		orders:     make(map[string]*order),
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case r.Method == http.MethodPost && len(parts) == 2 && parts[0] == "v1" && parts[1] == "checkouts":
+		s.createCheckout(w, r)
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[0] == "pay":
+		outcome := payment.EventPaymentSucceeded
+		if r.URL.Query().Get("outcome") == "fail" {
+			outcome = payment.EventPaymentFailed
+		}
+		s.settle(w, parts[1], outcome)
+	case r.Method == http.MethodPost && len(parts) == 4 && parts[0] == "v1" && parts[1] == "orders" && parts[3] == "refunds":
+		s.settle(w, parts[2], payment.EventRefundSucceeded)
+	case r.Method == http.MethodPost && len(parts) == 4 && parts[0] == "v1" && parts[1] == "orders" && parts[3] == "chargeback":
+		s.settle(w, parts[2], payment.EventChargeback)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) createCheckout(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		OrderId string `json:"order_id"`
+		Amount  int64  `json:"amount"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.OrderId == "" || req.Amount <= 0 {
+		http.Error(w, "invalid checkout", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.orders[req.OrderId] = &order{OrderId: req.OrderId, Amount: req.Amount}
+	s.mu.Unlock()
+
+	writeJSON(w, &payment.Checkout{
+		CheckoutId:  "chk_" + randomId(),
+		RedirectURL: s.baseURL + "/pay/" + req.OrderId,
+		ExpiresAt:   time.Now().Add(checkoutTTL),
+	})
+}
+
+// settle applies the outcome to the order and reports it through the webhook
+func (s *Server) settle(w http.ResponseWriter, orderId, eventType string) {
+	s.mu.Lock()
+	o, ok := s.orders[orderId]
+	valid := ok && (eventType == payment.EventPaymentSucceeded || eventType == payment.EventPaymentFailed) != o.Paid
+	if valid {
+		o.Paid = eventType == payment.EventPaymentSucceeded
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		http.Error(w, "unknown order", http.StatusNotFound)
+		return
+	}
+	if !valid {
+		http.Error(w, "order cannot be "+eventType, http.StatusConflict)
+		return
+	}
+
+	event := &payment.Event{
+		EventId:    "evt_" + randomId(),
+		Type:       eventType,
+		OrderId:    o.OrderId,
+		Amount:     o.Amount,
+		OccurredAt: time.Now(),
+	}
+	if err := s.sendWebhook(event); err != nil {
+		log.Println("[payment:fake] send webhook err", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, event)
+}
+
+func (s *Server) sendWebhook(event *payment.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(payment.SignatureHeader, payment.Sign(s.secret, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook answered %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("[payment:fake] write response err", err)
+	}
+}
+
+func randomId() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
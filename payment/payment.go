@@ -0,0 +1,79 @@
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+)
+
+const (
+	EventPaymentSucceeded = "payment.succeeded"
+	EventPaymentFailed    = "payment.failed"
+	EventRefundSucceeded  = "refund.succeeded"
+	EventChargeback       = "chargeback"
+
+	// SignatureHeader carries the hex HMAC-SHA256 of the webhook body under the webhook secret
+	SignatureHeader = "X-Signature"
+)
+
+// ErrInvalidSignature is returned for a webhook whose signature does not match its body
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+type CheckoutReq struct {
+	OrderId     string
+	Amount      int64
+	Currency    string
+	Description string
+	CustomerId  int64
+}
+
+// Checkout is where the user is sent to pay the order
+type Checkout struct {
+	CheckoutId  string    `json:"checkout_id"`
+	RedirectURL string    `json:"redirect_url"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// Event is a verified webhook event, EventId is unique per event and repeats when the provider retries it
+type Event struct {
+	EventId    string    `json:"event_id"`
+	Type       string    `json:"event_type"`
+	OrderId    string    `json:"order_id"`
+	Amount     int64     `json:"amount"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// Provider is a payment provider taking payments through a hosted checkout page and
+// reporting their outcome through signed webhooks
+type Provider interface {
+	CreateCheckout(ctx context.Context, req *CheckoutReq) (*Checkout, error)
+
+	// VerifyWebhook checks the webhook came from the provider and returns its event,
+	// ErrInvalidSignature when it did not
+	VerifyWebhook(header http.Header, body []byte) (*Event, error)
+
+	// Refund asks the provider to refund the order, the outcome arrives as a webhook
+	Refund(ctx context.Context, orderId string, amount int64) error
+}
+
+// Sign returns the signature of a webhook body under secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature compares signature to the body's signature in constant time
+func VerifySignature(secret string, body []byte, signature string) bool {
+	given, err := hex.DecodeString(signature)
+	if err != nil || secret == "" {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), given)
+}
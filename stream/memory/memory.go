@@ -0,0 +1,53 @@
+package memory
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/AlvinTendio/minder/stream"
+)
+
+const subscriberBufferSize = 1024
+
+type pubSub struct {
+	mu          sync.RWMutex
+	subscribers map[chan stream.Event]struct{}
+}
+
+// NewPubSub returns an in-process stream.PubSub, only fit for running a single instance
+func NewPubSub() stream.PubSub {
+	return &pubSub{subscribers: make(map[chan stream.Event]struct{})}
+}
+
+func (p *pubSub) Publish(ctx context.Context, event stream.Event) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for subscriber := range p.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+			log.Println(ctx, "[stream:memory] subscriber buffer full, dropping event", event.Type)
+		}
+	}
+	return nil
+}
+
+func (p *pubSub) Subscribe(ctx context.Context) (<-chan stream.Event, error) {
+	events := make(chan stream.Event, subscriberBufferSize)
+
+	p.mu.Lock()
+	p.subscribers[events] = struct{}{}
+	p.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		p.mu.Lock()
+		delete(p.subscribers, events)
+		p.mu.Unlock()
+		close(events)
+	}()
+
+	return events, nil
+}
@@ -0,0 +1,171 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/AlvinTendio/minder/stream"
+)
+
+const (
+	// created_at comes from the database clock, so the overlap window means the same on every instance
+	insertEvent = `INSERT INTO StreamEvents (user_id, event_type, payload) VALUES (?,?,?)`
+
+	getNow = `SELECT CURRENT_TIMESTAMP`
+
+	getEventsSince = `SELECT event_id, user_id, event_type, payload, created_at FROM StreamEvents
+			WHERE created_at >= ? AND event_id > ?
+			ORDER BY event_id
+			LIMIT ?`
+
+	deleteEventsBefore = `DELETE FROM StreamEvents WHERE created_at < CURRENT_TIMESTAMP - INTERVAL ? SECOND`
+
+	pollBatchSize = 500
+
+	// pollOverlap is how far back every poll reads again. Ids are given out when an insert starts
+	// but become visible when it commits, so a smaller id can show up after a larger one was read.
+	pollOverlap = 5 * time.Second
+)
+
+type pubSub struct {
+	db           *sql.DB
+	pollInterval time.Duration
+	retention    time.Duration
+}
+
+// cursor tracks what a subscriber has seen: every event created since since is read again on
+// each poll, and the ones in delivered are dropped
+type cursor struct {
+	since     time.Time
+	delivered map[int64]time.Time
+}
+
+// NewPubSub returns a stream.PubSub sharing events between instances through the StreamEvents table.
+// Every instance polls the table each pollInterval and events older than retention are deleted.
+func NewPubSub(db *sql.DB, pollInterval, retention time.Duration) stream.PubSub {
+	return &pubSub{db: db, pollInterval: pollInterval, retention: retention}
+}
+
+func (p *pubSub) Publish(ctx context.Context, event stream.Event) error {
+	_, err := p.db.ExecContext(ctx, insertEvent, event.UserId, event.Type, string(event.Data))
+	if err != nil {
+		log.Println(ctx, "[stream:mysql] Insert Event err", err)
+	}
+	return err
+}
+
+// Subscribe starts with the events stored from now on, earlier events are never replayed
+func (p *pubSub) Subscribe(ctx context.Context) (<-chan stream.Event, error) {
+	var now time.Time
+	if err := p.db.QueryRowContext(ctx, getNow).Scan(&now); err != nil {
+		log.Println(ctx, "[stream:mysql] Get Now err", err)
+		return nil, err
+	}
+
+	// events already in the overlap window were published before the subscription, mark them seen
+	c := &cursor{since: now.Add(-pollOverlap), delivered: make(map[int64]time.Time)}
+	if err := p.poll(ctx, c, nil); err != nil {
+		return nil, err
+	}
+
+	events := make(chan stream.Event)
+	go func() {
+		defer close(events)
+
+		poll := time.NewTicker(p.pollInterval)
+		defer poll.Stop()
+		cleanup := time.NewTicker(p.retention)
+		defer cleanup.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-cleanup.C:
+				if _, err := p.db.ExecContext(ctx, deleteEventsBefore, int64(p.retention.Seconds())); err != nil {
+					log.Println(ctx, "[stream:mysql] Delete Events err", err)
+				}
+			case <-poll.C:
+				if err := p.poll(ctx, c, events); err != nil {
+					log.Println(ctx, "[stream:mysql] Get Events err", err)
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// poll sends every event created since the cursor's window that was not delivered yet, then moves
+// the window to end pollOverlap before the newest event seen. A nil events only marks them delivered.
+func (p *pubSub) poll(ctx context.Context, c *cursor, events chan<- stream.Event) error {
+	newest := c.since.Add(pollOverlap)
+	var afterId int64
+	for {
+		batch, err := p.getEventsSince(ctx, c.since, afterId)
+		if err != nil {
+			return err
+		}
+
+		for _, row := range batch {
+			afterId = row.id
+			if row.createdAt.After(newest) {
+				newest = row.createdAt
+			}
+			if _, ok := c.delivered[row.id]; ok {
+				continue
+			}
+			c.delivered[row.id] = row.createdAt
+			if events == nil {
+				continue
+			}
+
+			select {
+			case events <- row.event:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+
+		if len(batch) < pollBatchSize {
+			break
+		}
+	}
+
+	c.since = newest.Add(-pollOverlap)
+	for id, createdAt := range c.delivered {
+		if createdAt.Before(c.since) {
+			delete(c.delivered, id)
+		}
+	}
+	return nil
+}
+
+type storedEvent struct {
+	id        int64
+	createdAt time.Time
+	event     stream.Event
+}
+
+func (p *pubSub) getEventsSince(ctx context.Context, since time.Time, afterId int64) (data []storedEvent, err error) {
+	rows, err := p.db.QueryContext(ctx, getEventsSince, since, afterId, pollBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row storedEvent
+		var payload string
+		if err := rows.Scan(&row.id, &row.event.UserId, &row.event.Type, &payload, &row.createdAt); err != nil {
+			log.Println(ctx, "[stream:mysql] Error scanning row:", err)
+			return nil, err
+		}
+		row.event.Data = json.RawMessage(payload)
+		data = append(data, row)
+	}
+	return data, rows.Err()
+}
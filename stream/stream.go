@@ -0,0 +1,117 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+)
+
+const clientBufferSize = 64
+
+// Event is pushed to every open stream of the user it is addressed to
+type Event struct {
+	UserId int64           `json:"-"`
+	Type   string          `json:"type"`
+	Data   json.RawMessage `json:"data,omitempty"`
+}
+
+// PubSub carries events between instances, so an event published on one instance
+// reaches streams held open by any other instance
+type PubSub interface {
+	// Publish hands the event to every subscriber, including the publishing instance
+	Publish(ctx context.Context, event Event) error
+
+	// Subscribe returns the events published from now on, the channel closes when ctx is done
+	Subscribe(ctx context.Context) (<-chan Event, error)
+}
+
+// Hub fans events out to the streams held open by users on this instance
+type Hub interface {
+	// Publish sends data as an event of the given type to the user, failures are only logged
+	Publish(ctx context.Context, userId int64, eventType string, data any)
+
+	// Subscribe registers a stream for the user until unsubscribe is called
+	Subscribe(userId int64) (events <-chan Event, unsubscribe func())
+
+	// Run delivers events from the pub/sub backend to the local streams until ctx is done
+	Run(ctx context.Context) error
+}
+
+type hub struct {
+	pubsub PubSub
+
+	mu      sync.RWMutex
+	clients map[int64]map[chan Event]struct{}
+}
+
+func NewHub(pubsub PubSub) Hub {
+	return &hub{
+		pubsub:  pubsub,
+		clients: make(map[int64]map[chan Event]struct{}),
+	}
+}
+
+func (h *hub) Publish(ctx context.Context, userId int64, eventType string, data any) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Println(ctx, "[stream:hub] marshal event err", err)
+		return
+	}
+
+	err = h.pubsub.Publish(ctx, Event{UserId: userId, Type: eventType, Data: payload})
+	if err != nil {
+		log.Println(ctx, "[stream:hub] publish event err", err)
+	}
+}
+
+func (h *hub) Subscribe(userId int64) (<-chan Event, func()) {
+	events := make(chan Event, clientBufferSize)
+
+	h.mu.Lock()
+	if h.clients[userId] == nil {
+		h.clients[userId] = make(map[chan Event]struct{})
+	}
+	h.clients[userId][events] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return events, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.clients[userId], events)
+			if len(h.clients[userId]) == 0 {
+				delete(h.clients, userId)
+			}
+			h.mu.Unlock()
+		})
+	}
+}
+
+func (h *hub) Run(ctx context.Context) error {
+	events, err := h.pubsub.Subscribe(ctx)
+	if err != nil {
+		return err
+	}
+
+	for event := range events {
+		h.deliver(event)
+	}
+
+	log.Println("[stream:hub] hub stopped!")
+	return nil
+}
+
+// deliver never blocks on a slow stream, the event is dropped for that stream instead
+func (h *hub) deliver(event Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for client := range h.clients[event.UserId] {
+		select {
+		case client <- event:
+		default:
+			log.Println("[stream:hub] stream buffer full, dropping event", event.Type, "for user", event.UserId)
+		}
+	}
+}